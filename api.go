@@ -0,0 +1,838 @@
+/*
+   Terminews is a terminal based (TUI) RSS feed manager.
+   Copyright (C) 2017  Alexandros Ntavelos, a[dot]ntavelos[at]gmail[dot]com
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"mime"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/antavelos/terminews/db"
+)
+
+// defaultPageLimit is the number of news returned per page unless a limit is
+// requested
+const defaultPageLimit = 50
+
+// apiSite is the JSON representation of a site in the API
+type apiSite struct {
+	Id              int    `json:"id"`
+	Name            string `json:"name"`
+	Url             string `json:"url"`
+	RefreshInterval int    `json:"refresh_interval"`
+	MaxItems        int    `json:"max_items"`
+	Extractor       string `json:"extractor,omitempty"`
+	UserAgent       string `json:"user_agent,omitempty"`
+	Headers         string `json:"headers,omitempty"`
+	OpenInBrowser   bool   `json:"open_in_browser"`
+	Proxy           string `json:"proxy,omitempty"`
+	Auth            string `json:"auth,omitempty"`
+	AuthUser        string `json:"auth_user,omitempty"`
+	AuthSecret      string `json:"auth_secret,omitempty"`
+	Failing         bool   `json:"failing"`
+}
+
+// apiItem is the JSON representation of a stored news or of a bookmark in the
+// API
+type apiItem struct {
+	Id         int            `json:"id"`
+	SiteId     int            `json:"site_id,omitempty"`
+	Title      string         `json:"title"`
+	Url        string         `json:"url"`
+	Author     string         `json:"author,omitempty"`
+	Published  string         `json:"published,omitempty"`
+	Summary    string         `json:"summary,omitempty"`
+	Read       bool           `json:"read"`
+	Tags       []string       `json:"tags,omitempty"`
+	Sources    []string       `json:"sources,omitempty"`
+	Enclosures []apiEnclosure `json:"enclosures,omitempty"`
+}
+
+type apiEnclosure struct {
+	Url      string `json:"url"`
+	Type     string `json:"type,omitempty"`
+	Length   int64  `json:"length,omitempty"`
+	Duration string `json:"duration,omitempty"`
+	Played   bool   `json:"played"`
+	Path     string `json:"path,omitempty"`
+}
+
+// apiPage is a page of news along with the total number of news selected
+type apiPage struct {
+	Items  []apiItem `json:"items"`
+	Total  int       `json:"total"`
+	Limit  int       `json:"limit"`
+	Offset int       `json:"offset"`
+}
+
+// apiRefresh is the outcome of the refresh of a site
+type apiRefresh struct {
+	SiteId int    `json:"site_id"`
+	Name   string `json:"name"`
+	Items  int    `json:"items"`
+	Error  string `json:"error,omitempty"`
+}
+
+// apiError is an error reported to the client with the given HTTP status
+type apiError struct {
+	status int
+	msg    string
+}
+
+func (e apiError) Error() string {
+	return e.msg
+}
+
+func badRequest(format string, args ...interface{}) error {
+	return apiError{http.StatusBadRequest, fmt.Sprintf(format, args...)}
+}
+
+func methodNotAllowed(r *http.Request) error {
+	return apiError{http.StatusMethodNotAllowed, fmt.Sprintf("method %v not allowed", r.Method)}
+}
+
+func newApiSite(site db.Site, health db.SiteHealth) apiSite {
+	return apiSite{
+		Id:              site.Id,
+		Name:            site.Name,
+		Url:             site.Url,
+		RefreshInterval: site.RefreshInterval,
+		MaxItems:        site.MaxItems,
+		Extractor:       site.Extractor,
+		UserAgent:       site.UserAgent,
+		Headers:         site.Headers,
+		OpenInBrowser:   site.OpenInBrowser,
+		Proxy:           site.Proxy,
+		Auth:            site.Auth,
+		AuthUser:        site.AuthUser,
+		AuthSecret:      maskSecret(site.AuthSecret),
+		Failing:         health.Failing(),
+	}
+}
+
+func newApiItem(e db.Event) apiItem {
+	item := apiItem{
+		Id:        e.Id,
+		SiteId:    e.SiteId,
+		Title:     e.Title,
+		Url:       e.Url,
+		Author:    e.Author,
+		Published: e.Published,
+		Summary:   e.Summary,
+		Read:      e.Read,
+		Tags:      e.TagList(),
+		Sources:   e.Sources,
+	}
+	for _, enc := range e.Enclosures {
+		item.Enclosures = append(item.Enclosures,
+			apiEnclosure{enc.Url, enc.Type, enc.Length, enc.Duration, enc.Played, enc.Path})
+	}
+	return item
+}
+
+func newApiItems(events []db.Event) []apiItem {
+	items := make([]apiItem, 0, len(events))
+	for _, e := range events {
+		items = append(items, newApiItem(e))
+	}
+	return items
+}
+
+// apiHandlerFunc handles an API request returning the error to report if any
+type apiHandlerFunc func(w http.ResponseWriter, r *http.Request) error
+
+func (f apiHandlerFunc) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	err := f(w, r)
+	if err == nil {
+		return
+	}
+	status := http.StatusInternalServerError
+	switch e := err.(type) {
+	case apiError:
+		status = e.status
+	case db.NotFound:
+		status = http.StatusNotFound
+	default:
+		log.Println("Error on", r.Method, r.URL.Path, err)
+	}
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) error {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	return json.NewEncoder(w).Encode(v)
+}
+
+func readJSON(r *http.Request, v interface{}) error {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		return badRequest("invalid JSON body: %v", err)
+	}
+	return nil
+}
+
+// newApiHandler returns the handler of the API. If a token is given, the
+// requests have to carry it as a bearer token, otherwise they have to come from
+// the local machine and be addressed to a loopback host so that the pages of
+// other sites cannot reach the API through DNS rebinding. The requests changing anything have to be
+// JSON ones, which browsers do not send cross-site without asking first.
+func newApiHandler(token string) http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/api/sites", apiHandlerFunc(handleSites))
+	mux.Handle("/api/sites/", apiHandlerFunc(handleSite))
+	mux.Handle("/api/items", apiHandlerFunc(handleItems))
+	mux.Handle("/api/items/", apiHandlerFunc(handleItem))
+	mux.Handle("/api/bookmarks", apiHandlerFunc(handleBookmarks))
+	mux.Handle("/api/bookmarks/", apiHandlerFunc(handleBookmark))
+	mux.Handle("/api/search", apiHandlerFunc(handleSearch))
+	mux.Handle("/api/refresh", apiHandlerFunc(handleRefresh))
+	mux.Handle("/api/feed", apiHandlerFunc(handleFeed))
+
+	expected := []byte("Bearer " + token)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(token) == 0 && !isLoopbackAddr(r.RemoteAddr) {
+			writeJSON(w, http.StatusForbidden, map[string]string{"error": fmt.Sprintf("client '%v' not allowed", r.RemoteAddr)})
+			return
+		}
+		if len(token) == 0 && !isLoopbackHost(r.Host) {
+			writeJSON(w, http.StatusForbidden, map[string]string{"error": fmt.Sprintf("host '%v' not allowed", r.Host)})
+			return
+		}
+		if len(token) > 0 && subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), expected) != 1 {
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+			return
+		}
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			if mt, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mt != "application/json" {
+				writeJSON(w, http.StatusUnsupportedMediaType, map[string]string{"error": "Content-Type must be application/json"})
+				return
+			}
+		}
+		mux.ServeHTTP(w, r)
+	})
+}
+
+// isLoopbackHost reports whether the given host, with or without a port, names
+// the local machine
+func isLoopbackHost(host string) bool {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.TrimSuffix(strings.Trim(host, "[]"), ".")
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// isLoopbackAddr reports whether the given client address, with or without a
+// port, is one of the local machine
+func isLoopbackAddr(addr string) bool {
+	if h, _, err := net.SplitHostPort(addr); err == nil {
+		addr = h
+	}
+	ip := net.ParseIP(strings.Trim(addr, "[]"))
+	return ip != nil && ip.IsLoopback()
+}
+
+// pathParts returns the parts of the request path following the given prefix,
+// the first one being the id of the requested resource
+func pathParts(r *http.Request, prefix string) (int, []string, error) {
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, prefix), "/"), "/")
+	id, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, nil, apiError{http.StatusNotFound, fmt.Sprintf("invalid id '%v'", parts[0])}
+	}
+	return id, parts[1:], nil
+}
+
+// handleSites lists the sites or adds a new one
+func handleSites(w http.ResponseWriter, r *http.Request) error {
+	switch r.Method {
+	case http.MethodGet:
+		sites, err := App.tdb.GetSites()
+		if err != nil {
+			return err
+		}
+		health := loadSitesHealth(sites)
+		result := make([]apiSite, 0, len(sites))
+		for _, site := range sites {
+			result = append(result, newApiSite(site, health[site.Id]))
+		}
+		return writeJSON(w, http.StatusOK, map[string][]apiSite{"sites": result})
+	case http.MethodPost:
+		return addApiSite(w, r)
+	}
+	return methodNotAllowed(r)
+}
+
+// addApiSite adds the feed of the requested URL as a new site. If several feeds
+// are discovered, they are returned so that the client requests one of them.
+func addApiSite(w http.ResponseWriter, r *http.Request) error {
+	var req struct {
+		Url        string `json:"url"`
+		Name       string `json:"name"`
+		Auth       string `json:"auth"`
+		AuthUser   string `json:"auth_user"`
+		AuthSecret string `json:"auth_secret"`
+	}
+	if err := readJSON(r, &req); err != nil {
+		return err
+	}
+	if len(req.Url) == 0 {
+		return badRequest("missing url")
+	}
+	if err := validateAuth(req.Auth); err != nil {
+		return badRequest("%v", err)
+	}
+	if err := validateApiSecret(req.AuthSecret, ""); err != nil {
+		return err
+	}
+
+	feeds, err := DiscoverFeeds(req.Url)
+	if feedUrl, ok := authRequired(err); ok && len(req.Auth) > 0 {
+		// the private feeds are added as is with the given credentials
+		feeds, err = []DiscoveredFeed{{Url: feedUrl}}, nil
+	}
+	if err != nil {
+		return badRequest("no feed found: %v", err)
+	}
+	if len(feeds) > 1 {
+		type feed struct {
+			Url   string `json:"url"`
+			Title string `json:"title,omitempty"`
+		}
+		choices := make([]feed, len(feeds))
+		for i, f := range feeds {
+			choices[i] = feed{f.Url, f.Title}
+		}
+		return writeJSON(w, http.StatusMultipleChoices, map[string]interface{}{
+			"error": "several feeds found, add one of them",
+			"feeds": choices,
+		})
+	}
+
+	if _, err := App.tdb.GetSiteByUrl(feeds[0].Url); err == nil {
+		return apiError{http.StatusConflict, "site already exists"}
+	} else if _, ok := err.(db.NotFound); !ok {
+		return err
+	}
+	name := req.Name
+	if len(name) == 0 {
+		name = feeds[0].Title
+	}
+	if len(name) == 0 {
+		name = feeds[0].Url
+	}
+	site := db.Site{
+		Name:       name,
+		Url:        feeds[0].Url,
+		Auth:       parseAuth(req.Auth),
+		AuthUser:   req.AuthUser,
+		AuthSecret: req.AuthSecret,
+	}
+	if err := App.tdb.AddSite(site); err != nil {
+		return err
+	}
+	if site, err = App.tdb.GetSiteByUrl(feeds[0].Url); err != nil {
+		return err
+	}
+	return writeJSON(w, http.StatusCreated, newApiSite(site, db.SiteHealth{}))
+}
+
+// handleSite serves a site, its news and the refresh of its news
+func handleSite(w http.ResponseWriter, r *http.Request) error {
+	id, parts, err := pathParts(r, "/api/sites/")
+	if err != nil {
+		return err
+	}
+	site, err := App.tdb.GetSiteById(id)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case len(parts) == 0:
+		switch r.Method {
+		case http.MethodGet:
+			health, err := App.tdb.GetSiteHealth(id)
+			if err != nil {
+				return err
+			}
+			return writeJSON(w, http.StatusOK, newApiSite(site, health))
+		case http.MethodPut, http.MethodPatch:
+			return updateApiSite(w, r, site)
+		case http.MethodDelete:
+			if err := App.tdb.SoftDeleteSite(id); err != nil {
+				return err
+			}
+			w.WriteHeader(http.StatusNoContent)
+			return nil
+		}
+	case len(parts) == 1 && parts[0] == "items":
+		if r.Method == http.MethodGet {
+			return writeItems(w, r, id)
+		}
+	case len(parts) == 1 && parts[0] == "refresh":
+		if r.Method == http.MethodPost {
+			return writeJSON(w, http.StatusOK, refreshApiSite(site))
+		}
+	default:
+		return apiError{http.StatusNotFound, "not found"}
+	}
+	return methodNotAllowed(r)
+}
+
+// validateApiSecret refuses the secrets printed by commands or read from the
+// environment unless they are the current one: only the TUI and the CLI set
+// them, otherwise anyone reaching the API would have its commands run, or the
+// variables of terminews sent to its own server, on the next fetch.
+func validateApiSecret(secret, current string) error {
+	if secret == current {
+		return nil
+	}
+	if strings.HasPrefix(secret, secretCmdPrefix) {
+		return badRequest("the secrets printed by commands (%v) can only be set from the terminews UI or CLI", secretCmdPrefix)
+	}
+	if strings.HasPrefix(secret, secretEnvPrefix) {
+		return badRequest("the secrets read from the environment (%v) can only be set from the terminews UI or CLI", secretEnvPrefix)
+	}
+	return nil
+}
+
+// updateApiSite changes the settings of a site given in the request, the
+// missing ones are kept
+func updateApiSite(w http.ResponseWriter, r *http.Request, site db.Site) error {
+	req := newApiSite(site, db.SiteHealth{})
+	if err := readJSON(r, &req); err != nil {
+		return err
+	}
+	if len(req.Name) == 0 {
+		return badRequest("empty name")
+	}
+	if len(req.Url) == 0 {
+		return badRequest("empty url")
+	}
+	if req.RefreshInterval < 0 || req.MaxItems < 0 {
+		return badRequest("negative refresh_interval or max_items")
+	}
+	if _, ok := extractors[req.Extractor]; !ok && len(req.Extractor) > 0 {
+		return badRequest("unknown extractor '%v'", req.Extractor)
+	}
+	for _, h := range strings.Split(req.Headers, "\n") {
+		if len(strings.TrimSpace(h)) > 0 && !strings.Contains(h, ":") {
+			return badRequest("invalid header '%v'", h)
+		}
+	}
+	if err := validateProxy(req.Proxy); err != nil {
+		return badRequest("%v", err)
+	}
+	if err := validateAuth(req.Auth); err != nil {
+		return badRequest("%v", err)
+	}
+	// the stored secret, masked or not, is not carried to another feed: a new
+	// one has to be given
+	current := site.AuthSecret
+	if req.Url != site.Url {
+		if req.AuthSecret == secretMask || req.AuthSecret == current {
+			req.AuthSecret = ""
+		}
+		current = ""
+	}
+	if err := validateApiSecret(req.AuthSecret, current); err != nil {
+		return err
+	}
+	if req.Url != site.Url {
+		if other, err := App.tdb.GetSiteByUrl(req.Url); err == nil && other.Id != site.Id {
+			return apiError{http.StatusConflict, "site already exists"}
+		}
+	}
+
+	site.Name = req.Name
+	site.Url = req.Url
+	site.RefreshInterval = req.RefreshInterval
+	site.MaxItems = req.MaxItems
+	site.Extractor = req.Extractor
+	site.UserAgent = req.UserAgent
+	site.Headers = req.Headers
+	site.OpenInBrowser = req.OpenInBrowser
+	site.Proxy = req.Proxy
+	site.Auth = parseAuth(req.Auth)
+	site.AuthUser = req.AuthUser
+	// the masked secret is kept
+	if req.AuthSecret != secretMask {
+		site.AuthSecret = req.AuthSecret
+	}
+	if err := App.tdb.UpdateSite(site); err != nil {
+		return err
+	}
+	health, err := App.tdb.GetSiteHealth(site.Id)
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, http.StatusOK, newApiSite(site, health))
+}
+
+// handleItems lists the stored news of all sites
+func handleItems(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != http.MethodGet {
+		return methodNotAllowed(r)
+	}
+	siteId := 0
+	if s := r.URL.Query().Get("site"); len(s) > 0 {
+		id, err := strconv.Atoi(s)
+		if err != nil {
+			return badRequest("invalid site '%v'", s)
+		}
+		siteId = id
+	}
+	return writeItems(w, r, siteId)
+}
+
+// writeItems writes the page of the stored news selected by the query
+// parameters: unread, q (space separated terms), limit and offset
+func writeItems(w http.ResponseWriter, r *http.Request, siteId int) error {
+	query := r.URL.Query()
+	filter := db.ItemFilter{
+		SiteId: siteId,
+		Terms:  strings.Fields(query.Get("q")),
+		Limit:  defaultPageLimit,
+	}
+	var err error
+	if s := query.Get("unread"); len(s) > 0 {
+		if filter.Unread, err = strconv.ParseBool(s); err != nil {
+			return badRequest("invalid unread '%v'", s)
+		}
+	}
+	if s := query.Get("limit"); len(s) > 0 {
+		if filter.Limit, err = parseNonNegative(s); err != nil || filter.Limit == 0 {
+			return badRequest("invalid limit '%v'", s)
+		}
+	}
+	if s := query.Get("offset"); len(s) > 0 {
+		if filter.Offset, err = parseNonNegative(s); err != nil {
+			return badRequest("invalid offset '%v'", s)
+		}
+	}
+
+	events, total, err := App.tdb.FindItems(filter)
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, http.StatusOK, apiPage{newApiItems(events), total, filter.Limit, filter.Offset})
+}
+
+// handleItem serves a stored news and marks it as read (PUT or POST) or unread
+// (DELETE)
+func handleItem(w http.ResponseWriter, r *http.Request) error {
+	id, parts, err := pathParts(r, "/api/items/")
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case len(parts) == 0:
+		if r.Method != http.MethodGet {
+			return methodNotAllowed(r)
+		}
+	case len(parts) == 1 && parts[0] == "read":
+		switch r.Method {
+		case http.MethodPut, http.MethodPost:
+			err = App.tdb.SetItemRead(id, true)
+		case http.MethodDelete:
+			err = App.tdb.SetItemRead(id, false)
+		default:
+			return methodNotAllowed(r)
+		}
+		if err != nil {
+			return err
+		}
+	default:
+		return apiError{http.StatusNotFound, "not found"}
+	}
+
+	e, err := App.tdb.GetItemById(id)
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, http.StatusOK, newApiItem(e))
+}
+
+// handleBookmarks lists the bookmarks, optionally the ones having a tag, or
+// bookmarks a stored news (item_id) or any news
+func handleBookmarks(w http.ResponseWriter, r *http.Request) error {
+	switch r.Method {
+	case http.MethodGet:
+		events, err := App.tdb.GetEvents()
+		if err != nil {
+			return err
+		}
+		if tag := r.URL.Query().Get("tag"); len(tag) > 0 {
+			events = eventsWithTag(events, tag)
+		}
+		if events, err = App.tdb.AttachEnclosures(events); err != nil {
+			return err
+		}
+		return writeJSON(w, http.StatusOK, map[string][]apiItem{"bookmarks": newApiItems(events)})
+	case http.MethodPost:
+		return addApiBookmark(w, r)
+	}
+	return methodNotAllowed(r)
+}
+
+func addApiBookmark(w http.ResponseWriter, r *http.Request) error {
+	var req struct {
+		ItemId int `json:"item_id"`
+		apiItem
+	}
+	if err := readJSON(r, &req); err != nil {
+		return err
+	}
+
+	e := db.Event{
+		Title:     req.Title,
+		Url:       req.Url,
+		Author:    req.Author,
+		Published: req.Published,
+		Summary:   req.Summary,
+	}
+	if req.ItemId != 0 {
+		item, err := App.tdb.GetItemById(req.ItemId)
+		if err != nil {
+			return err
+		}
+		e = db.Event{
+			Title:     item.Title,
+			Url:       item.Url,
+			Author:    item.Author,
+			Published: item.Published,
+			Summary:   item.Summary,
+		}
+	}
+	if len(e.Url) == 0 {
+		return badRequest("missing url or item_id")
+	}
+	if len(e.Title) == 0 {
+		e.Title = e.Url
+	}
+	e.Tags = joinTags(req.Tags...)
+
+	if _, err := App.tdb.GetEventByUrl(e.Url); err == nil {
+		return apiError{http.StatusConflict, "bookmark already exists"}
+	} else if _, ok := err.(db.NotFound); !ok {
+		return err
+	}
+	if err := App.tdb.AddEvent(e); err != nil {
+		return err
+	}
+	e, err := App.tdb.GetEventByUrl(e.Url)
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, http.StatusCreated, newApiItem(e))
+}
+
+// handleBookmark serves or deletes a bookmark
+func handleBookmark(w http.ResponseWriter, r *http.Request) error {
+	id, parts, err := pathParts(r, "/api/bookmarks/")
+	if err != nil {
+		return err
+	}
+	if len(parts) > 0 {
+		return apiError{http.StatusNotFound, "not found"}
+	}
+	e, err := App.tdb.GetEventById(id)
+	if err != nil {
+		return err
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		return writeJSON(w, http.StatusOK, newApiItem(e))
+	case http.MethodDelete:
+		if err := App.tdb.SoftDeleteEvent(id); err != nil {
+			return err
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+	return methodNotAllowed(r)
+}
+
+// handleSearch searches the stored news for the given terms or, with live set,
+// the news currently published by all sites
+func handleSearch(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != http.MethodGet {
+		return methodNotAllowed(r)
+	}
+	query := r.URL.Query()
+	terms := strings.Fields(query.Get("q"))
+	if len(terms) == 0 {
+		return badRequest("missing q")
+	}
+	if live, _ := strconv.ParseBool(query.Get("live")); !live {
+		return writeItems(w, r, 0)
+	}
+
+	events := searchEvents(terms)
+	return writeJSON(w, http.StatusOK, apiPage{newApiItems(events), len(events), len(events), 0})
+}
+
+// handleRefresh fetches the news of all sites, or of the one given with the
+// site parameter, storing them and running the hooks of the new ones
+func handleRefresh(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != http.MethodPost {
+		return methodNotAllowed(r)
+	}
+
+	var sites []db.Site
+	if s := r.URL.Query().Get("site"); len(s) > 0 {
+		id, err := strconv.Atoi(s)
+		if err != nil {
+			return badRequest("invalid site '%v'", s)
+		}
+		site, err := App.tdb.GetSiteById(id)
+		if err != nil {
+			return err
+		}
+		sites = []db.Site{site}
+	} else {
+		var err error
+		if sites, err = App.tdb.GetSites(); err != nil {
+			return err
+		}
+	}
+
+	result := make([]apiRefresh, 0, len(sites))
+	for _, site := range sites {
+		result = append(result, refreshApiSite(site))
+	}
+	return writeJSON(w, http.StatusOK, map[string][]apiRefresh{"sites": result})
+}
+
+func refreshApiSite(site db.Site) apiRefresh {
+	result := apiRefresh{SiteId: site.Id, Name: site.Name}
+	events, _, err := fetchSiteEvents(site)
+	if err != nil {
+		result.Error = err.Error()
+	}
+	result.Items = len(events)
+	return result
+}
+
+// handleFeed republishes as an Atom (default) or RSS feed the bookmarks or,
+// given a search (q) or a site, the stored news. The bookmarks can be filtered
+// by tag and both by category.
+func handleFeed(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != http.MethodGet {
+		return methodNotAllowed(r)
+	}
+	query := r.URL.Query()
+	format := query.Get("format")
+	if len(format) == 0 {
+		format = "atom"
+	}
+	if format != "atom" && format != "rss" {
+		return badRequest("unknown format '%v'", format)
+	}
+
+	var (
+		events []db.Event
+		title  string
+		err    error
+	)
+	if len(query.Get("q")) > 0 || len(query.Get("site")) > 0 {
+		filter := db.ItemFilter{
+			Terms:    strings.Fields(query.Get("q")),
+			Category: query.Get("category"),
+			Limit:    defaultPageLimit,
+		}
+		title = fmt.Sprintf("Search results for: %v", query.Get("q"))
+		if s := query.Get("site"); len(s) > 0 {
+			if filter.SiteId, err = strconv.Atoi(s); err != nil {
+				return badRequest("invalid site '%v'", s)
+			}
+			site, err := App.tdb.GetSiteById(filter.SiteId)
+			if err != nil {
+				return err
+			}
+			title = fmt.Sprintf("News from: %v", site.Name)
+			if len(filter.Terms) > 0 {
+				title = fmt.Sprintf("%v matching: %v", title, query.Get("q"))
+			}
+		}
+		if events, _, err = App.tdb.FindItems(filter); err != nil {
+			return err
+		}
+	} else {
+		if events, err = App.tdb.GetEvents(); err != nil {
+			return err
+		}
+		title = "My bookmarks"
+		if tag := query.Get("tag"); len(tag) > 0 {
+			events = eventsWithTag(events, tag)
+			title = fmt.Sprintf("%v tagged with: %v", title, tag)
+		}
+		if category := query.Get("category"); len(category) > 0 {
+			events = eventsWithCategory(events, category)
+			title = fmt.Sprintf("%v in category: %v", title, category)
+		}
+	}
+
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	self := fmt.Sprintf("%v://%v%v", scheme, r.Host, r.URL.RequestURI())
+	if format == "rss" {
+		w.Header().Set("Content-Type", "application/rss+xml; charset=utf-8")
+		return writeRSS(w, title, self, events)
+	}
+	w.Header().Set("Content-Type", "application/atom+xml; charset=utf-8")
+	return writeAtom(w, title, self, events)
+}
+
+func serveCommand(args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	addr := fs.String("addr", "127.0.0.1:8080", "address to listen on")
+	token := fs.String("token", "", "require the requests to carry this token (Authorization: Bearer TOKEN)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		fs.Usage()
+		return errors.New("unexpected arguments")
+	}
+	if len(*token) == 0 && !isLoopbackHost(*addr) {
+		return fmt.Errorf("serving on '%v' requires a token (-token)", *addr)
+	}
+
+	startMaintenance()
+
+	fmt.Printf("Serving the API on http://%v/api/\n", *addr)
+	return http.ListenAndServe(*addr, newApiHandler(*token))
+}
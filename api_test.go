@@ -0,0 +1,285 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mmcdole/gofeed"
+)
+
+// newApiRequest returns a request addressed to the API on the local machine
+func newApiRequest(method, path, body string) *http.Request {
+	var b io.Reader
+	if len(body) > 0 {
+		b = strings.NewReader(body)
+	}
+	r := httptest.NewRequest(method, path, b)
+	r.Host = "localhost:8080"
+	r.RemoteAddr = "127.0.0.1:40000"
+	if method != http.MethodGet {
+		r.Header.Set("Content-Type", "application/json")
+	}
+	return r
+}
+
+// apiRequest performs a request against the API handler and decodes the JSON
+// response into v if given
+func apiRequest(t *testing.T, h http.Handler, method, path, body string, v interface{}) int {
+	t.Helper()
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, newApiRequest(method, path, body))
+	if v != nil {
+		if err := json.Unmarshal(w.Body.Bytes(), v); err != nil {
+			t.Fatalf("%v %v: invalid JSON %q: %v", method, path, w.Body.String(), err)
+		}
+	}
+	return w.Code
+}
+
+func TestApi(t *testing.T) {
+	newTestDB(t)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<rss version="2.0"><channel><title>Test feed</title>`+
+			`<item><title>Go 1.16 released</title><link>https://example.org/1</link></item>`+
+			`<item><title>Rust 1.50 released</title><link>https://example.org/2</link></item>`+
+			`<item><title>Go modules by default</title><link>https://example.org/3</link></item>`+
+			`</channel></rss>`)
+	}))
+	defer ts.Close()
+
+	h := newApiHandler("")
+
+	// sites
+	var site apiSite
+	if code := apiRequest(t, h, "POST", "/api/sites", fmt.Sprintf(`{"url": %q}`, ts.URL), &site); code != http.StatusCreated {
+		t.Fatalf("POST /api/sites got %v", code)
+	}
+	if site.Name != "Test feed" || site.Url != ts.URL {
+		t.Errorf("POST /api/sites got %+v", site)
+	}
+	if code := apiRequest(t, h, "POST", "/api/sites", fmt.Sprintf(`{"url": %q}`, ts.URL), nil); code != http.StatusConflict {
+		t.Errorf("POST /api/sites of an existing site got %v, want %v", code, http.StatusConflict)
+	}
+	sitePath := fmt.Sprintf("/api/sites/%v", site.Id)
+	if code := apiRequest(t, h, "PUT", sitePath, `{"name": "Renamed", "max_items": 10}`, &site); code != http.StatusOK {
+		t.Fatalf("PUT %v got %v", sitePath, code)
+	}
+	if site.Name != "Renamed" || site.MaxItems != 10 || site.Url != ts.URL {
+		t.Errorf("PUT %v got %+v", sitePath, site)
+	}
+	if code := apiRequest(t, h, "PUT", sitePath, `{"extractor": "unknown"}`, nil); code != http.StatusBadRequest {
+		t.Errorf("PUT %v with an unknown extractor got %v, want %v", sitePath, code, http.StatusBadRequest)
+	}
+	var sites map[string][]apiSite
+	if apiRequest(t, h, "GET", "/api/sites", "", &sites); len(sites["sites"]) != 1 || sites["sites"][0].Name != "Renamed" {
+		t.Errorf("GET /api/sites got %+v", sites)
+	}
+	if code := apiRequest(t, h, "GET", "/api/sites/42", "", nil); code != http.StatusNotFound {
+		t.Errorf("GET /api/sites/42 got %v, want %v", code, http.StatusNotFound)
+	}
+
+	// refresh and items
+	var refreshed map[string][]apiRefresh
+	if code := apiRequest(t, h, "POST", "/api/refresh", "", &refreshed); code != http.StatusOK {
+		t.Fatalf("POST /api/refresh got %v", code)
+	}
+	if r := refreshed["sites"]; len(r) != 1 || r[0].Items != 3 || len(r[0].Error) > 0 {
+		t.Errorf("POST /api/refresh got %+v", refreshed)
+	}
+	if code := apiRequest(t, h, "GET", "/api/refresh", "", nil); code != http.StatusMethodNotAllowed {
+		t.Errorf("GET /api/refresh got %v, want %v", code, http.StatusMethodNotAllowed)
+	}
+
+	var page apiPage
+	apiRequest(t, h, "GET", sitePath+"/items?limit=2", "", &page)
+	if page.Total != 3 || len(page.Items) != 2 || page.Items[0].Title != "Go 1.16 released" || page.Items[0].SiteId != site.Id {
+		t.Fatalf("GET %v/items?limit=2 got %+v", sitePath, page)
+	}
+	apiRequest(t, h, "GET", "/api/items?limit=2&offset=2", "", &page)
+	if page.Total != 3 || len(page.Items) != 1 || page.Items[0].Title != "Go modules by default" {
+		t.Errorf("GET /api/items?limit=2&offset=2 got %+v", page)
+	}
+	if code := apiRequest(t, h, "GET", "/api/items?limit=-1", "", nil); code != http.StatusBadRequest {
+		t.Errorf("GET /api/items?limit=-1 got %v, want %v", code, http.StatusBadRequest)
+	}
+
+	var item apiItem
+	itemPath := fmt.Sprintf("/api/items/%v/read", page.Items[0].Id)
+	if apiRequest(t, h, "PUT", itemPath, "", &item); !item.Read {
+		t.Errorf("PUT %v got %+v", itemPath, item)
+	}
+	apiRequest(t, h, "GET", "/api/items?unread=true", "", &page)
+	if page.Total != 2 {
+		t.Errorf("GET /api/items?unread=true got %+v", page)
+	}
+	if apiRequest(t, h, "DELETE", itemPath, "", &item); item.Read {
+		t.Errorf("DELETE %v got %+v", itemPath, item)
+	}
+
+	apiRequest(t, h, "GET", "/api/search?q=go+released", "", &page)
+	if page.Total != 1 || page.Items[0].Url != "https://example.org/1" {
+		t.Errorf("GET /api/search?q=go+released got %+v", page)
+	}
+	if code := apiRequest(t, h, "GET", "/api/search", "", nil); code != http.StatusBadRequest {
+		t.Errorf("GET /api/search got %v, want %v", code, http.StatusBadRequest)
+	}
+
+	// bookmarks
+	var bookmark apiItem
+	body := fmt.Sprintf(`{"item_id": %v, "tags": ["go", "release"]}`, page.Items[0].Id)
+	if code := apiRequest(t, h, "POST", "/api/bookmarks", body, &bookmark); code != http.StatusCreated {
+		t.Fatalf("POST /api/bookmarks got %v", code)
+	}
+	if bookmark.Title != "Go 1.16 released" || strings.Join(bookmark.Tags, ",") != "go,release" {
+		t.Errorf("POST /api/bookmarks got %+v", bookmark)
+	}
+	if code := apiRequest(t, h, "POST", "/api/bookmarks", body, nil); code != http.StatusConflict {
+		t.Errorf("POST /api/bookmarks of an existing bookmark got %v, want %v", code, http.StatusConflict)
+	}
+	apiRequest(t, h, "POST", "/api/bookmarks", `{"url": "https://example.org/other", "title": "Other"}`, nil)
+	var bookmarks map[string][]apiItem
+	if apiRequest(t, h, "GET", "/api/bookmarks?tag=go", "", &bookmarks); len(bookmarks["bookmarks"]) != 1 {
+		t.Errorf("GET /api/bookmarks?tag=go got %+v", bookmarks)
+	}
+
+	// feeds
+	for _, test := range []struct {
+		path, feedType, title string
+		items                 int
+	}{
+		{"/api/feed?tag=go", "atom", "My bookmarks tagged with: go", 1},
+		{"/api/feed?format=rss", "rss", "My bookmarks", 2},
+		{"/api/feed?q=released", "atom", "Search results for: released", 2},
+	} {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, newApiRequest("GET", test.path, ""))
+		feed, err := gofeed.NewParser().Parse(w.Body)
+		if err != nil {
+			t.Fatalf("GET %v: invalid feed: %v", test.path, err)
+		}
+		if feed.FeedType != test.feedType || feed.Title != test.title || len(feed.Items) != test.items {
+			t.Errorf("GET %v got %v feed %q with %v items", test.path, feed.FeedType, feed.Title, len(feed.Items))
+		}
+	}
+	if code := apiRequest(t, h, "GET", "/api/feed?format=pdf", "", nil); code != http.StatusBadRequest {
+		t.Errorf("GET /api/feed?format=pdf got %v, want %v", code, http.StatusBadRequest)
+	}
+
+	bookmarkPath := fmt.Sprintf("/api/bookmarks/%v", bookmark.Id)
+	if code := apiRequest(t, h, "DELETE", bookmarkPath, "", nil); code != http.StatusNoContent {
+		t.Errorf("DELETE %v got %v", bookmarkPath, code)
+	}
+	if code := apiRequest(t, h, "GET", bookmarkPath, "", nil); code != http.StatusNotFound {
+		t.Errorf("GET %v of a deleted bookmark got %v, want %v", bookmarkPath, code, http.StatusNotFound)
+	}
+
+	if code := apiRequest(t, h, "DELETE", sitePath, "", nil); code != http.StatusNoContent {
+		t.Errorf("DELETE %v got %v", sitePath, code)
+	}
+	if apiRequest(t, h, "GET", "/api/items", "", &page); page.Total != 0 {
+		t.Errorf("GET /api/items after deleting the site got %+v", page)
+	}
+
+	// the deletions are soft ones, purged later like those of the UI
+	if purged, err := App.tdb.PurgeDeleted(time.Now().Add(time.Minute)); err != nil || purged != 2 {
+		t.Errorf("purged %v deleted sites and bookmarks (%v), want 2", purged, err)
+	}
+}
+
+func TestApiToken(t *testing.T) {
+	h := newApiHandler("secret")
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/api/sites", nil))
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("Request without token got %v, want %v", w.Code, http.StatusUnauthorized)
+	}
+
+	w = httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/api/unknown", nil)
+	r.Header.Set("Authorization", "Bearer secret")
+	h.ServeHTTP(w, r)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Request with token got %v, want %v", w.Code, http.StatusNotFound)
+	}
+}
+
+func TestApiCrossSite(t *testing.T) {
+	h := newApiHandler("")
+
+	for _, test := range []struct {
+		host, contentType string
+		want              int
+	}{
+		{"localhost:8080", "application/json", http.StatusNotFound},
+		{"127.0.0.1:8080", "application/json; charset=utf-8", http.StatusNotFound},
+		{"[::1]:8080", "application/json", http.StatusNotFound},
+		{"LOCALHOST", "application/json", http.StatusNotFound},
+		{"evil.example.org:8080", "application/json", http.StatusForbidden},
+		{"127.0.0.1.evil.example.org", "application/json", http.StatusForbidden},
+		{"localhost:8080", "text/plain", http.StatusUnsupportedMediaType},
+		{"localhost:8080", "application/x-www-form-urlencoded", http.StatusUnsupportedMediaType},
+		{"localhost:8080", "", http.StatusUnsupportedMediaType},
+	} {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("POST", "/api/unknown", strings.NewReader(`{}`))
+		r.Host = test.host
+		r.RemoteAddr = "[::1]:40000"
+		if len(test.contentType) > 0 {
+			r.Header.Set("Content-Type", test.contentType)
+		}
+		h.ServeHTTP(w, r)
+		if w.Code != test.want {
+			t.Errorf("POST to %v with %q got %v, want %v", test.host, test.contentType, w.Code, test.want)
+		}
+	}
+
+	// the reads do not need a content type
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/api/unknown", nil)
+	r.Host = "127.0.0.1:8080"
+	r.RemoteAddr = "127.0.0.1:40000"
+	h.ServeHTTP(w, r)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("GET got %v, want %v", w.Code, http.StatusNotFound)
+	}
+}
+
+func TestApiRemoteClient(t *testing.T) {
+	for _, test := range []struct {
+		token, remoteAddr string
+		want              int
+	}{
+		{"", "127.0.0.1:40000", http.StatusNotFound},
+		{"", "[::1]:40000", http.StatusNotFound},
+		// the Host header is set by the client, which cannot be trusted
+		{"", "192.0.2.1:40000", http.StatusForbidden},
+		{"", "[2001:db8::1]:40000", http.StatusForbidden},
+		{"secret", "192.0.2.1:40000", http.StatusNotFound},
+	} {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("GET", "/api/unknown", nil)
+		r.Host = "localhost:8080"
+		r.RemoteAddr = test.remoteAddr
+		r.Header.Set("Authorization", "Bearer "+test.token)
+		newApiHandler(test.token).ServeHTTP(w, r)
+		if w.Code != test.want {
+			t.Errorf("GET from %v with token %q got %v, want %v", test.remoteAddr, test.token, w.Code, test.want)
+		}
+	}
+}
+
+func TestServeCommandAddr(t *testing.T) {
+	for _, addr := range []string{"0.0.0.0:8080", ":8080", "192.0.2.1:8080", "[::]:8080"} {
+		if err := serveCommand([]string{"-addr", addr}); err == nil {
+			t.Errorf("Serving on %v without a token got no error", addr)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+/*
+   Terminews is a terminal based (TUI) RSS feed manager.
+   Copyright (C) 2017  Alexandros Ntavelos, a[dot]ntavelos[at]gmail[dot]com
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+package main
+
+import (
+	"time"
+
+	"github.com/antavelos/terminews/db"
+	"github.com/fatih/color"
+	c "github.com/jroimartin/gocui"
+)
+
+// appState holds the state of the app the handlers work on. The tests inject
+// a new one instead of resetting the state left by the previous tests.
+type appState struct {
+	tdb *db.TDB
+	// Web fetches the feeds and the articles
+	Web Fetcher
+	// Driver drives the GUI, through the terminal unless headless
+	Driver driver
+
+	SitesList    *List
+	NewsList     *List
+	ContentList  *List
+	SiteEditList *List
+	FeedsList    *List
+	RulesList    *List
+	MessagesList *List
+	Summary      *c.View
+
+	UI     uiState
+	Layout paneLayout
+	// relayout forces the lists to be redrawn by the next layout
+	relayout bool
+	// curW and curH are the size of the screen at the last layout
+	curW, curH int
+
+	Theme theme
+	// Colors256 indicates that the GUI runs in the 256 colors mode
+	Colors256 bool
+	Bold      *color.Color
+	Dim       *color.Color
+
+	CurrentSite      db.Site
+	CurrentContent   []string
+	CurrentBookmarks []db.Event
+	EditedSite       db.Site
+	// SearchTerms are the terms of the last search
+	SearchTerms []string
+
+	SitesHealth map[int]db.SiteHealth
+	// SitesSort is the current order of the sites list
+	SitesSort string
+	// SitesUnread holds the number of unread stored news per site
+	SitesUnread map[int]int
+	FetchError  string
+	LastRefresh time.Time
+	// fillingBookmarks indicates whether the missing details of imported
+	// bookmarks are currently being fetched
+	fillingBookmarks bool
+
+	Messages []statusMessage
+	// messagesFrom is the view focused before the messages view was opened
+	messagesFrom string
+	undoStack    []undoEntry
+}
+
+// newAppState returns the state of an app which has not started yet
+func newAppState() *appState {
+	return &appState{
+		Web:         defaultFetcher(),
+		Driver:      terminal,
+		Layout:      defaultLayout(),
+		Theme:       themes[0],
+		SitesHealth: map[int]db.SiteHealth{},
+		SitesSort:   sortManual,
+		SitesUnread: map[int]int{},
+	}
+}
+
+// App is the state of the running app
+var App = newAppState()
@@ -0,0 +1,13 @@
+package main
+
+import "testing"
+
+// newTestApp injects the state of an app which has not started yet, the
+// previous one being restored once the test completes
+func newTestApp(t *testing.T) *appState {
+	saved := App
+	App = newAppState()
+	t.Cleanup(func() { App = saved })
+
+	return App
+}
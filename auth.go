@@ -0,0 +1,252 @@
+/*
+   Terminews is a terminal based (TUI) RSS feed manager.
+   Copyright (C) 2017  Alexandros Ntavelos, a[dot]ntavelos[at]gmail[dot]com
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/antavelos/terminews/db"
+)
+
+const (
+	authNone   = "none"
+	authBasic  = "basic"
+	authBearer = "bearer"
+	authCookie = "cookie"
+	// authHeader sends the secret in the header named by the user, e.g.
+	// PRIVATE-TOKEN
+	authHeader = "header"
+)
+
+// authNames are the authentications of the sites, none being stored empty
+var authNames = []string{authNone, authBasic, authBearer, authCookie, authHeader}
+
+const (
+	// secretEnvPrefix prefixes the name of the environment variable holding
+	// a secret
+	secretEnvPrefix = "env:"
+	// secretCmdPrefix prefixes the shell command printing a secret on the
+	// first line of its output, e.g. cmd:pass show feeds/example
+	secretCmdPrefix = "cmd:"
+	// secretMask is displayed instead of a secret. Submitting it keeps the
+	// secret unchanged.
+	secretMask = "********"
+)
+
+// secretTimeout is the time a command printing a secret is given to complete
+const secretTimeout = 30 * time.Second
+
+var (
+	// secretsMu guards secrets
+	secretsMu sync.Mutex
+	// secrets caches the secrets printed by commands, so that e.g. a password
+	// manager is not run on every request
+	secrets = map[string]string{}
+)
+
+func validateAuth(value string) error {
+	if len(value) == 0 {
+		return nil
+	}
+	for _, name := range authNames {
+		if value == name {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown authentication '%v', expected one of %v", value, strings.Join(authNames, ", "))
+}
+
+// formatAuth returns the name of the authentication of a site
+func formatAuth(auth string) string {
+	if len(auth) == 0 {
+		return authNone
+	}
+	return auth
+}
+
+// parseAuth returns the stored value of an authentication
+func parseAuth(name string) string {
+	if name == authNone {
+		return ""
+	}
+	return name
+}
+
+// isSecretRef reports whether a secret refers to an environment variable or
+// a command instead of being stored as is
+func isSecretRef(secret string) bool {
+	return strings.HasPrefix(secret, secretEnvPrefix) || strings.HasPrefix(secret, secretCmdPrefix)
+}
+
+// maskSecret returns the secret as displayed, its references as is
+func maskSecret(secret string) string {
+	if len(secret) == 0 || isSecretRef(secret) {
+		return secret
+	}
+	return secretMask
+}
+
+// resolveSecret returns the secret referred to by the given value
+func resolveSecret(value string) (string, error) {
+	switch {
+	case strings.HasPrefix(value, secretEnvPrefix):
+		name := strings.TrimPrefix(value, secretEnvPrefix)
+		secret := os.Getenv(name)
+		if len(secret) == 0 {
+			return "", fmt.Errorf("the environment variable %v is not set", name)
+		}
+		return secret, nil
+	case strings.HasPrefix(value, secretCmdPrefix):
+		return commandSecret(strings.TrimPrefix(value, secretCmdPrefix))
+	}
+	return value, nil
+}
+
+// commandSecret runs the command printing a secret unless it already did
+func commandSecret(command string) (string, error) {
+	secretsMu.Lock()
+	defer secretsMu.Unlock()
+	if secret, ok := secrets[command]; ok {
+		return secret, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), secretTimeout)
+	defer cancel()
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("%v failed: %v %v", command, err, strings.TrimSpace(stderr.String()))
+	}
+	secret := strings.TrimSpace(strings.SplitN(string(out), "\n", 2)[0])
+	if len(secret) == 0 {
+		return "", fmt.Errorf("%v printed no secret", command)
+	}
+	secrets[command] = secret
+	return secret, nil
+}
+
+// siteAuthKey is the context key of the site whose authentication applies to
+// a request and to its redirections
+type siteAuthKey struct{}
+
+// authorize applies the authentication of the site to a request of the origin
+// (scheme, host and port) of its feed. The credentials are never sent to other
+// origins, e.g. the ones of the articles, nor downgraded to plain HTTP.
+func authorize(req *http.Request, site db.Site) error {
+	if len(site.Auth) == 0 {
+		return nil
+	}
+	feed, err := url.Parse(site.Url)
+	if err != nil || !sameOrigin(feed, req.URL) {
+		return nil
+	}
+
+	secret, err := resolveSecret(site.AuthSecret)
+	if err != nil {
+		return fmt.Errorf("No secret for the authentication of %v: %v", site.Name, err)
+	}
+	switch site.Auth {
+	case authBasic:
+		req.SetBasicAuth(site.AuthUser, secret)
+	case authBearer:
+		req.Header.Set("Authorization", "Bearer "+secret)
+	case authCookie:
+		req.Header.Add("Cookie", secret)
+	case authHeader:
+		if len(site.AuthUser) == 0 {
+			return fmt.Errorf("No header for the authentication of %v", site.Name)
+		}
+		req.Header.Set(site.AuthUser, secret)
+	default:
+		return fmt.Errorf("Unknown authentication '%v' of %v", site.Auth, site.Name)
+	}
+	return nil
+}
+
+// authHeaderName returns the name of the header carrying the credentials of
+// the site
+func authHeaderName(site db.Site) string {
+	switch site.Auth {
+	case authCookie:
+		return "Cookie"
+	case authHeader:
+		return site.AuthUser
+	}
+	return "Authorization"
+}
+
+// reauthorize is the redirect policy of the fetchers. The redirected request
+// carries the headers of the first one, so the credentials are removed and
+// applied again only if it is still addressed to the origin of the feed.
+func reauthorize(req *http.Request, via []*http.Request) error {
+	if len(via) >= 10 {
+		return errors.New("stopped after 10 redirects")
+	}
+	site, ok := req.Context().Value(siteAuthKey{}).(db.Site)
+	if !ok || len(site.Auth) == 0 {
+		return nil
+	}
+	name := http.CanonicalHeaderKey(authHeaderName(site))
+	req.Header.Del(name)
+	if values, ok := siteHeaders(site)[name]; ok {
+		req.Header[name] = values
+	}
+	return authorize(req, site)
+}
+
+// sameOrigin reports whether both URLs have the same scheme, host and port
+func sameOrigin(a, b *url.URL) bool {
+	return strings.EqualFold(a.Scheme, b.Scheme) &&
+		strings.EqualFold(a.Hostname(), b.Hostname()) &&
+		urlPort(a) == urlPort(b)
+}
+
+// urlPort returns the port of the URL, the default one of its scheme if none
+func urlPort(u *url.URL) string {
+	if port := u.Port(); len(port) > 0 {
+		return port
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "http":
+		return "80"
+	case "https":
+		return "443"
+	}
+	return ""
+}
+
+// authRequired returns the URL of a request refused for lacking credentials
+func authRequired(err error) (string, bool) {
+	serr, ok := err.(StatusError)
+	if !ok || (serr.Code != http.StatusUnauthorized && serr.Code != http.StatusForbidden) {
+		return "", false
+	}
+	return serr.Url, true
+}
@@ -0,0 +1,275 @@
+/*
+   Terminews is a terminal based (TUI) RSS feed manager.
+   Copyright (C) 2017  Alexandros Ntavelos, a[dot]ntavelos[at]gmail[dot]com
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/antavelos/terminews/db"
+)
+
+// command is a non interactive sub command of the app, e.g. `terminews export`
+type command struct {
+	name  string
+	usage string
+	run   func(args []string) error
+}
+
+var commands = []command{
+	{"export", "Exports bookmarks or news to Markdown, HTML, JSON lines, Netscape bookmarks, Atom or RSS", exportCommand},
+	{"import", "Imports bookmarks from a browser HTML export or a Pocket/Instapaper CSV export", importCommand},
+	{"refresh", "Fetches the news of all sites, storing them and running the hooks of the new ones", refreshCommand},
+	{"rules", "Lists, adds or removes the rules applied on the fetched news", rulesCommand},
+	{"config", "Lists or changes the settings of the app", configCommand},
+	{"db", "Reports the storage used per site, prunes the stored news or compacts the DB", dbCommand},
+	{"sync", "Synchronizes the sites, the read news and the bookmarks with a Google Reader API server", syncCommand},
+	{"serve", "Serves a local HTTP/JSON API over the sites, the news and the bookmarks", serveCommand},
+}
+
+// findCommand returns the command with the given name if any
+func findCommand(name string) (command, bool) {
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return cmd, true
+		}
+	}
+	return command{}, false
+}
+
+// printUsage prints the available commands of the app
+func printUsage() {
+	fmt.Fprintf(os.Stderr, "Usage: terminews [--version] [--profile NAME] [--db PATH] [command [options]]\n\n"+
+		"Options:\n"+
+		"  --profile NAME  use the sites, news and settings of the given profile\n"+
+		"  --db PATH       use the given DB file instead of the one of the profile\n"+
+		"  --version       display the version\n\n"+
+		"Commands:\n")
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %-10v %v\n", cmd.name, cmd.usage)
+	}
+}
+
+// searchEvents synchronously collects the events of all sites which match the
+// given terms collapsing the duplicates
+func searchEvents(terms []string) []db.Event {
+	done := make(chan bool)
+	cevent := make(chan db.Event)
+	go findEvents(terms, cevent, done)
+
+	var events []db.Event
+	for {
+		select {
+		case e := <-cevent:
+			events = append(events, e)
+		case <-done:
+			return collapseDuplicates(events)
+		}
+	}
+}
+
+// findSite looks up a site by its URL or, case insensitively, by its name
+func findSite(nameOrUrl string) (db.Site, error) {
+	sites, err := App.tdb.GetSites()
+	if err != nil {
+		return db.Site{}, err
+	}
+	for _, site := range sites {
+		if site.Url == nameOrUrl || strings.EqualFold(site.Name, nameOrUrl) {
+			return site, nil
+		}
+	}
+	return db.Site{}, db.NotFound(fmt.Sprintf("Site not found: %v", nameOrUrl))
+}
+
+func exportCommand(args []string) error {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	format := fs.String("format", "", "output format: md, html, json, netscape, atom or rss (default: guessed by the output file extension, md for stdout)")
+	output := fs.String("o", "", "output file (default: stdout)")
+	site := fs.String("site", "", "export the current news of the site with the given name or URL instead of the bookmarks")
+	search := fs.String("search", "", "export the news of all sites matching the given terms instead of the bookmarks")
+	stored := fs.Bool("stored", false, "search the stored news instead of fetching the news of all sites")
+	tag := fs.String("tag", "", "export only the bookmarks having the given tag")
+	category := fs.String("category", "", "export only the news filed under the given category (or tag)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	var (
+		events []db.Event
+		title  string
+		err    error
+	)
+	switch {
+	case len(*site) > 0:
+		s, err := findSite(*site)
+		if err != nil {
+			return err
+		}
+		if events, _, err = fetchSiteEvents(s); err != nil {
+			return err
+		}
+		title = fmt.Sprintf("News from: %v", s.Name)
+	case len(*search) > 0 && *stored:
+		if events, _, err = App.tdb.FindItems(db.ItemFilter{Terms: strings.Fields(*search)}); err != nil {
+			return err
+		}
+		title = fmt.Sprintf("Search results for: %v", *search)
+	case len(*search) > 0:
+		events = searchEvents(strings.Fields(*search))
+		title = fmt.Sprintf("Search results for: %v", *search)
+	default:
+		if events, err = App.tdb.GetEvents(); err != nil {
+			return err
+		}
+		title = "My bookmarks"
+	}
+
+	if len(*tag) > 0 {
+		events = eventsWithTag(events, *tag)
+		title = fmt.Sprintf("%v tagged with: %v", title, *tag)
+	}
+	if len(*category) > 0 {
+		events = eventsWithCategory(events, *category)
+		title = fmt.Sprintf("%v in category: %v", title, *category)
+	}
+
+	if len(*output) == 0 {
+		if len(*format) == 0 {
+			*format = "md"
+		}
+		return ExportEvents(os.Stdout, *format, title, events)
+	}
+	if err = ExportEventsToFile(*output, *format, title, events); err != nil {
+		return err
+	}
+	fmt.Printf("Exported %v item(s) to %v\n", len(events), *output)
+
+	return nil
+}
+
+// eventsWithTag filters the events having the given tag
+func eventsWithTag(events []db.Event, tag string) []db.Event {
+	var filtered []db.Event
+	for _, e := range events {
+		for _, t := range e.TagList() {
+			if strings.EqualFold(t, tag) {
+				filtered = append(filtered, e)
+				break
+			}
+		}
+	}
+	return filtered
+}
+
+func importCommand(args []string) error {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	format := fs.String("format", "", "input format: html (browser/Netscape bookmarks) or csv (default: guessed by the file extension)")
+	dryRun := fs.Bool("dry-run", false, "report what would be imported without storing anything")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: terminews import [options] FILE\n")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errors.New("exactly one FILE to import is expected")
+	}
+
+	path := fs.Arg(0)
+	if len(*format) == 0 {
+		*format = importFormatFromPath(path)
+	}
+	f, err := os.Open(expandPath(path))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	events, err := ParseBookmarks(f, *format)
+	if err != nil {
+		return err
+	}
+	report, err := ImportEvents(events, *dryRun)
+	if err != nil {
+		return err
+	}
+
+	if *dryRun {
+		for _, entry := range report.Entries {
+			switch entry.Status {
+			case importNew:
+				fmt.Printf("+ %v\n", entry.Event.Url)
+			default:
+				fmt.Printf("- %v (%v: %v)\n", entry.Event.Url, entry.Status, entry.Reason)
+			}
+		}
+		fmt.Println()
+	}
+	verb := "Imported"
+	if *dryRun {
+		verb = "Would import"
+	}
+	fmt.Printf("%v %v of %v bookmark(s), %v duplicate(s), %v invalid\n",
+		verb, report.New, len(report.Entries), report.Duplicates, report.Invalid)
+
+	return nil
+}
+
+func refreshCommand(args []string) error {
+	fs := flag.NewFlagSet("refresh", flag.ContinueOnError)
+	name := fs.String("site", "", "refresh only the site with the given name or URL")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	var (
+		sites []db.Site
+		err   error
+	)
+	if len(*name) > 0 {
+		site, err := findSite(*name)
+		if err != nil {
+			return err
+		}
+		sites = []db.Site{site}
+	} else if sites, err = App.tdb.GetSites(); err != nil {
+		return err
+	}
+
+	failed := 0
+	for _, site := range sites {
+		events, _, err := fetchSiteEvents(site)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v: %v\n", site.Name, err)
+			failed++
+			continue
+		}
+		fmt.Printf("%v: %v news\n", site.Name, len(events))
+	}
+	if failed > 0 {
+		return fmt.Errorf("%v of %v site(s) failed", failed, len(sites))
+	}
+
+	return nil
+}
@@ -0,0 +1,211 @@
+/*
+   Terminews is a terminal based (TUI) RSS feed manager.
+   Copyright (C) 2017  Alexandros Ntavelos, a[dot]ntavelos[at]gmail[dot]com
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/antavelos/terminews/db"
+)
+
+// setting is a configuration option of the app which is stored in the DB
+type setting struct {
+	name  string
+	def   string
+	usage string
+	// validate, if set, checks a new value of the setting
+	validate func(value string) error
+}
+
+var settings = []setting{
+	{"retention.max_age_days", "30", "days after which the stored news are pruned, 0 keeps them forever", validateNonNegative},
+	{"retention.max_items_per_site", "500", "number of the most recent news stored per site, 0 keeps all", validateNonNegative},
+	{"retention.prune_interval_hours", "24", "hours between the prunings while running, 0 prunes at startup only", validateNonNegative},
+	{"maintenance.vacuum_interval_days", "7", "days between the compactions of the DB file, 0 disables them", validateNonNegative},
+	{"player.command", "mpv --no-terminal", "command playing the enclosures, their URL or file is appended", validateNotEmpty},
+	{"hooks.command", "", "shell command run for every new news matching a notify rule, the news is passed as JSON on its standard input", nil},
+	{"hooks.file", "", "file or FIFO every new news matching a notify rule is appended to as a JSON line", nil},
+	{"download.directory", "~/Downloads/terminews", "directory the enclosures are downloaded to", validateNotEmpty},
+	{"sync.url", "", "URL of the Google Reader API of the sync server, e.g. https://example.org/api/greader.php for FreshRSS", nil},
+	{"sync.user", "", "user of the sync server", nil},
+	{"sync.password", "", "password (or API password) of the sync server, or env:VARIABLE holding it, or cmd:COMMAND printing it", nil},
+	{"ui.theme", themeAuto, "theme of the UI: dark, light, high-contrast, mono, dark-256 (256 colors terminals) or auto (mono if NO_COLOR is set, dark otherwise)", validateTheme},
+	{"ui.colors", colorsAuto, "colors of the terminal: 8, 256 or auto (guessed by TERM and COLORTERM)", validateColors},
+	{"ui.confirm_delete", "false", "asks for a confirmation before deleting a site or a bookmark", validateBool},
+	{"undo.keep_minutes", "60", "minutes the deleted sites and bookmarks can be restored with Ctrl+z before being purged", validateNonNegative},
+	{"sync.conflict", syncMerge, "resolution of the differences of the news never synchronized: merge (read or starred on either side wins), local or remote", validateSyncPolicy},
+	{"fetch.timeout_seconds", "30", "seconds a request of a feed or an article is given to complete, 0 waits forever", validateNonNegative},
+	{"fetch.user_agent", "", "user agent of the requests unless the site sets its own, terminews/VERSION if empty", nil},
+	{"fetch.insecure", "false", "skips the verification of the TLS certificates of the sites", validateBool},
+	{"fetch.ca_file", "", "PEM file of the certificate authorities trusted besides the ones of the system, e.g. of a corporate network", nil},
+	{"fetch.proxy", "", "proxy of the requests: an http://, https:// or socks5:// URL, tor (socks5://127.0.0.1:9050) or direct, the HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables are used if empty", validateProxy},
+}
+
+// secretSettings are the settings holding a secret, which is masked when
+// displayed
+var secretSettings = map[string]bool{"sync.password": true}
+
+// displayedSetting returns the value of a setting as displayed
+func displayedSetting(name, value string) string {
+	if secretSettings[name] {
+		return maskSecret(value)
+	}
+	return value
+}
+
+func validateNonNegative(value string) error {
+	_, err := parseNonNegative(value)
+	return err
+}
+
+func validateBool(value string) error {
+	if _, err := strconv.ParseBool(value); err != nil {
+		return fmt.Errorf("'%v' is neither true nor false", value)
+	}
+	return nil
+}
+
+func validateNotEmpty(value string) error {
+	if len(strings.TrimSpace(value)) == 0 {
+		return errors.New("empty value")
+	}
+	return nil
+}
+
+// findSetting returns the setting with the given name if any
+func findSetting(name string) (setting, bool) {
+	for _, s := range settings {
+		if s.name == name {
+			return s, true
+		}
+	}
+	return setting{}, false
+}
+
+// getSetting returns the stored value of a setting or its default value
+func getSetting(name string) string {
+	s, _ := findSetting(name)
+	value, err := App.tdb.GetSetting(name)
+	if err != nil {
+		if _, ok := err.(db.NotFound); !ok {
+			log.Println("Error on GetSetting", err)
+		}
+		return s.def
+	}
+	return value
+}
+
+// getIntSetting returns the value of a numeric setting falling back to its
+// default value if the stored one is invalid
+func getIntSetting(name string) int {
+	n, err := strconv.Atoi(getSetting(name))
+	if err != nil {
+		s, _ := findSetting(name)
+		n, _ = strconv.Atoi(s.def)
+	}
+	return n
+}
+
+// getBoolSetting returns the value of a boolean setting falling back to its
+// default value if the stored one is invalid
+func getBoolSetting(name string) bool {
+	b, err := strconv.ParseBool(getSetting(name))
+	if err != nil {
+		s, _ := findSetting(name)
+		b, _ = strconv.ParseBool(s.def)
+	}
+	return b
+}
+
+func configCommand(args []string) error {
+	fs := flag.NewFlagSet("config", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: terminews config [list | get NAME | set NAME VALUE | unset NAME]\n\nSettings:\n")
+		for _, s := range settings {
+			fmt.Fprintf(fs.Output(), "  %v (default: %v)\n    \t%v\n", s.name, s.def, s.usage)
+		}
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	action := "list"
+	if fs.NArg() > 0 {
+		action = fs.Arg(0)
+	}
+	expectArgs := map[string]int{"list": 1, "get": 2, "set": 3, "unset": 2}
+	n, ok := expectArgs[action]
+	if !ok || fs.NArg() > n || (fs.NArg() < n && action != "list") {
+		fs.Usage()
+		return errors.New("invalid arguments")
+	}
+
+	var s setting
+	if action != "list" {
+		if s, ok = findSetting(fs.Arg(1)); !ok {
+			return fmt.Errorf("unknown setting '%v'", fs.Arg(1))
+		}
+	}
+
+	switch action {
+	case "list":
+		stored, err := App.tdb.GetSettings()
+		if err != nil {
+			return err
+		}
+		names := make([]string, len(settings))
+		for i, s := range settings {
+			names[i] = s.name
+		}
+		sort.Strings(names)
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		for _, name := range names {
+			value, ok := stored[name]
+			if ok {
+				value = displayedSetting(name, value)
+			} else {
+				s, _ := findSetting(name)
+				value = s.def + " (default)"
+			}
+			fmt.Fprintf(w, "%v\t%v\n", name, value)
+		}
+		return w.Flush()
+	case "get":
+		fmt.Println(displayedSetting(s.name, getSetting(s.name)))
+	case "set":
+		value := fs.Arg(2)
+		if s.validate != nil {
+			if err := s.validate(value); err != nil {
+				return err
+			}
+		}
+		return App.tdb.SetSetting(s.name, value)
+	case "unset":
+		return App.tdb.DeleteSetting(s.name)
+	}
+
+	return nil
+}
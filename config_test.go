@@ -0,0 +1,19 @@
+package main
+
+import "testing"
+
+func TestDisplayedSetting(t *testing.T) {
+	for _, test := range []struct {
+		name, value, want string
+	}{
+		{"sync.password", "s3cr3t", secretMask},
+		{"sync.password", "env:SYNC_PASSWORD", "env:SYNC_PASSWORD"},
+		{"sync.password", "cmd:pass show sync", "cmd:pass show sync"},
+		{"sync.password", "", ""},
+		{"sync.user", "me", "me"},
+	} {
+		if got := displayedSetting(test.name, test.value); got != test.want {
+			t.Errorf("displayedSetting(%q, %q) = %q, want %q", test.name, test.value, got, test.want)
+		}
+	}
+}
@@ -18,82 +18,147 @@
 package main
 
 import (
-	// "fmt"
-	"github.com/advancedlogic/GoOse"
-	// "golang.org/x/net/html"
-	// "net/http"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
 	"strings"
+	"time"
+
+	"github.com/advancedlogic/GoOse"
+	"github.com/antavelos/terminews/db"
+	"golang.org/x/net/html"
 )
 
-// func ParseUrl(url string, ch chan string, done chan bool) {
-// 	resp, err := http.Get(url)
-// 	defer func() {
-// 		// Notify that we're done after this function
-// 		done <- true
-// 	}()
-// 	if err != nil {
-// 		return
-// 	}
-// 	b := resp.Body
-// 	defer b.Close() // close Body when the function returns
-
-// 	z := html.NewTokenizer(b)
-
-// 	inParagraph := false
-// 	for {
-// 		tt := z.Next()
-
-// 		switch tt {
-// 		case html.ErrorToken:
-// 			// End of the document, we're done
-// 			return
-// 		case html.StartTagToken:
-// 			t := z.Token()
-
-// 			// Check if the token is an <p> tag
-// 			isParagraph := t.Data == "p"
-// 			if isParagraph {
-// 				inParagraph = true
-// 			}
-// 		case html.EndTagToken:
-// 			t := z.Token()
-
-// 			isParagraph := t.Data == "p"
-// 			if isParagraph {
-// 				inParagraph = false
-// 			}
-// 		case html.TextToken:
-// 			if inParagraph {
-// 				t := fmt.Sprint(z.Token())
-// 				ch <- strings.TrimSpace(html.UnescapeString(t))
-// 			}
-// 		}
-// 	}
-// }
-
-// func GetContent(url string) []string {
-// 	ch := make(chan string)
-// 	done := make(chan bool)
-// 	go ParseUrl(url, ch, done)
-
-// 	content := []string{}
-// 	for {
-// 		select {
-// 		case text := <-ch:
-// 			content = append(content, text)
-// 		case <-done:
-// 			return content
-// 		}
-// 	}
-// }
-
-func GetContent(url string) ([]string, error) {
+// extractFunc extracts the text paragraphs of an article from its HTML
+type extractFunc func(rawHTML, url string) ([]string, error)
+
+const defaultExtractor = "goose"
+
+// extractors maps the names of the available content extractors to their
+// functions
+var extractors = map[string]extractFunc{
+	"goose":      extractGoose,
+	"paragraphs": extractParagraphs,
+}
+
+// extractorNames returns the sorted names of the available extractors
+func extractorNames() []string {
+	var names []string
+	for name := range extractors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// extractGoose uses GoOse to figure out the main text of the article
+func extractGoose(rawHTML, url string) ([]string, error) {
 	g := goose.New()
-	article, err := g.ExtractFromURL(url)
+	article, err := g.ExtractFromRawHTML(rawHTML, url)
+	if err != nil {
+		return nil, err
+	}
+	return strings.Split(article.CleanedText, "\n\n"), nil
+}
+
+// extractParagraphs keeps the text of every paragraph of the page. It suits
+// sites whose layout confuses GoOse.
+func extractParagraphs(rawHTML, url string) ([]string, error) {
+	var (
+		content     []string
+		paragraph   strings.Builder
+		inParagraph bool
+	)
+
+	z := html.NewTokenizer(strings.NewReader(rawHTML))
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			if z.Err() == io.EOF {
+				return content, nil
+			}
+			return nil, z.Err()
+		case html.StartTagToken:
+			if t := z.Token(); t.Data == "p" {
+				inParagraph = true
+				paragraph.Reset()
+			}
+		case html.EndTagToken:
+			if t := z.Token(); t.Data == "p" && inParagraph {
+				inParagraph = false
+				if text := strings.Join(strings.Fields(paragraph.String()), " "); len(text) > 0 {
+					content = append(content, text)
+				}
+			}
+		case html.TextToken:
+			if inParagraph {
+				paragraph.WriteString(html.UnescapeString(string(z.Text())))
+			}
+		}
+	}
+}
+
+// fetchHTML downloads the page at the given url with the settings of the site
+func fetchHTML(site db.Site, url string) (string, error) {
+	resp, err := fetch(site, url)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	rememberCanonicalLink(url, body)
+	return string(body), nil
+}
+
+// GetContent downloads the article at the given url and extracts its text
+// with the extractor preferred by the site
+func GetContent(site db.Site, url string) ([]string, error) {
+	name := site.Extractor
+	if len(name) == 0 {
+		name = defaultExtractor
+	}
+	extract, ok := extractors[name]
+	if !ok {
+		return nil, fmt.Errorf("Unknown content extractor: '%v'", name)
+	}
+
+	rawHTML, err := fetchHTML(site, url)
 	if err != nil {
 		return nil, err
 	}
-	lines := strings.Split(article.CleanedText, "\n\n")
+	return extract(rawHTML, url)
+}
+
+// FillEventDetails completes the missing title and summary of an event with
+// the ones extracted from its article
+func FillEventDetails(e db.Event) (db.Event, error) {
+	rawHTML, err := fetchHTML(db.Site{}, e.Url)
+	if err != nil {
+		return e, err
+	}
+	g := goose.New()
+	article, err := g.ExtractFromRawHTML(rawHTML, e.Url)
+	if err != nil {
+		return e, err
+	}
+
+	if (len(e.Title) == 0 || e.Title == e.Url) && len(article.Title) > 0 {
+		e.Title = article.Title
+	}
+	if len(e.Summary) == 0 {
+		e.Summary = article.MetaDescription
+		if len(e.Summary) == 0 {
+			e.Summary = strings.SplitN(article.CleanedText, "\n\n", 2)[0]
+		}
+	}
+	if len(e.Published) == 0 && article.PublishDate != nil {
+		e.Published = article.PublishDate.Format(time.RFC1123Z)
+	}
 
-	return lines, nil
+	return e, nil
 }
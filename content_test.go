@@ -0,0 +1,63 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/antavelos/terminews/db"
+)
+
+func TestExtractParagraphs(t *testing.T) {
+	page := `<html><body><nav><a href="/">Home</a></nav>
+<p>First   paragraph with <a href="#">a link</a>.</p>
+<div>Not a paragraph</div>
+<p>Second &amp; last</p><p> </p>
+</body></html>`
+
+	got, err := extractParagraphs(page, "https://example.org")
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	want := []string{"First paragraph with a link.", "Second & last"}
+	if len(got) != len(want) {
+		t.Fatalf("got %v paragraphs, want %v: %v", len(got), len(want), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("paragraph %v got '%v', want '%v'", i, got[i], want[i])
+		}
+	}
+}
+
+func TestSiteFields(t *testing.T) {
+	site := db.Site{Name: "Site"}
+	for _, test := range []struct {
+		field int
+		value string
+		valid bool
+	}{
+		{0, "", false},
+		{0, "Renamed", true},
+		{1, "15", true},
+		{1, "-1", false},
+		{2, "abc", false},
+		{3, "paragraphs", true},
+		{3, "unknown", false},
+		{6, "X-A: 1 | X-B: 2", true},
+		{6, "broken", false},
+		{7, "ftp://proxy.example.org", false},
+		{7, "socks5h://127.0.0.1:9050", true},
+	} {
+		err := siteFields[test.field].set(&site, test.value)
+		if (err == nil) != test.valid {
+			t.Errorf("setting '%v' to '%v' got error %v", siteFields[test.field].label, test.value, err)
+		}
+	}
+
+	want := db.Site{Name: "Renamed", RefreshInterval: 15, Extractor: "paragraphs", Headers: "X-A: 1\nX-B: 2", Proxy: "socks5h://127.0.0.1:9050"}
+	if site != want {
+		t.Errorf("got %#v, want %#v", site, want)
+	}
+	if next := siteFields[4].nextOption(site); next != "yes" {
+		t.Errorf("got next option %v, want yes", next)
+	}
+}
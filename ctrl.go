@@ -23,38 +23,56 @@ import (
 	"net/url"
 	"os/exec"
 	"strings"
+	"time"
 
 	"github.com/antavelos/terminews/db"
 	c "github.com/jroimartin/gocui"
 )
 
+// bookmarkMarker prefixes the titles of the bookmarked news
+const bookmarkMarker = "  "
+
 // UpdateSummary updates the summary View based on the currently selected
 // news item
 func UpdateSummary() error {
-	Summary.Clear()
+	App.Summary.Clear()
 
-	currItem := NewsList.CurrentItem()
+	currItem := App.NewsList.CurrentItem()
 	if currItem == nil {
+		if len(App.FetchError) > 0 {
+			_, err := fmt.Fprintf(App.Summary, "\n\n %v %v\n\n %v", App.Bold.Sprint("Error:"), App.FetchError,
+				"Press Ctrl+d on the Sites list to see the fetch history of the site")
+			return err
+		}
 		return nil
 	}
 	event := currItem.(db.Event)
 
-	authorLine := fmt.Sprintf("%v %v", Bold.Sprint("By:"), event.Author)
-	publishedLine := fmt.Sprintf("%v %v", Bold.Sprint("Published on:"), event.Published)
-	urlLine := fmt.Sprintf("%v %v", Bold.Sprint("URL:"), event.Url)
+	authorLine := fmt.Sprintf("%v %v", App.Bold.Sprint("By:"), event.Author)
+	publishedLine := fmt.Sprintf("%v %v", App.Bold.Sprint("Published on:"), event.Published)
+	urlLine := fmt.Sprintf("%v %v", App.Bold.Sprint("URL:"), event.Url)
+	if len(event.Sources) > 1 {
+		urlLine += fmt.Sprintf("\n %v %v", App.Bold.Sprint("Also published by:"), strings.Join(event.Sources[1:], ", "))
+	}
 
-	w, _ := Summary.Size()
+	w, _ := App.Summary.Size()
 	summaryLine := strings.Join(JustifiedLines(event.Summary, w-2), "\n ")
 
-	_, err := fmt.Fprintf(Summary, "\n\n %v\n %v\n %v\n\n\n %v",
-		authorLine, publishedLine, urlLine, Bold.Sprint(summaryLine))
+	_, err := fmt.Fprintf(App.Summary, "\n\n %v\n %v\n %v\n\n\n %v",
+		authorLine, publishedLine, urlLine, App.Bold.Sprint(summaryLine))
+	if err != nil || len(event.Enclosures) == 0 {
+		return err
+	}
+
+	_, err = fmt.Fprintf(App.Summary, "\n\n\n %v\n %v", App.Bold.Sprint("Attachments (Ctrl+p: play, Ctrl+g: download):"),
+		strings.Join(enclosureLines(event.Enclosures), "\n "))
 
 	return err
 }
 
 func eventInBookmarks(event db.Event) (db.Event, bool) {
-	for _, b := range CurrentBookmarks {
-		if event.Url == b.Url {
+	for _, b := range App.CurrentBookmarks {
+		if CanonicalUrl(event.Url) == CanonicalUrl(b.Url) {
 			return b, true
 		}
 	}
@@ -63,58 +81,69 @@ func eventInBookmarks(event db.Event) (db.Event, bool) {
 
 // UpdateNews updates the news list according to the given events
 func UpdateNews(events []db.Event, from string) error {
-	NewsList.Reset()
-	Summary.Clear()
+	App.NewsList.Reset()
+	App.Summary.Clear()
+	App.FetchError = ""
+
+	// the rules may have bookmarked some of the events
+	if bookmarks, err := App.tdb.GetEvents(); err == nil {
+		App.CurrentBookmarks = bookmarks
+	}
 
 	if len(events) == 0 {
-		NewsList.SetTitle(fmt.Sprintf("No news in %v", from))
+		App.NewsList.SetTitle(fmt.Sprintf("No news in %v", from))
 		return nil
 	}
-	NewsList.SetTitle(fmt.Sprintf("News from: %v", from))
+	App.NewsList.SetTitle(fmt.Sprintf("News from: %v", from))
 
 	data := make([]interface{}, len(events))
 	for i, e := range events {
-		if _, ok := eventInBookmarks(e); ok {
-			e.Title = fmt.Sprintf("  %v", e.Title)
-		}
 		data[i] = e
 	}
 
-	return NewsList.SetItems(data)
+	return App.NewsList.SetItems(data)
 }
 
 // LoadSites loads the sites from DB and displays them in the list
 func LoadSites() error {
-	SitesList.SetTitle("Sites")
+	App.SitesList.SetTitle(sitesTitle())
 
-	sites, err := tdb.GetSites()
+	sites, err := App.tdb.GetSites()
 	if err != nil {
-		fmt.Errorf("Failed to load sites: %v", err)
+		return fmt.Errorf("Failed to load sites: %v", err)
 	}
 	if len(sites) == 0 {
-		SitesList.SetTitle("No sites yet... (Ctrl-n to add)")
-		SitesList.Reset()
-		NewsList.Reset()
-		NewsList.SetTitle("No news yet...")
+		App.SitesList.SetTitle("No sites yet... (Ctrl-n to add)")
+		App.SitesList.Reset()
+		App.NewsList.Reset()
+		App.NewsList.SetTitle("No news yet...")
+		App.UI.showNews(newsNone)
 		return nil
 	}
+	App.SitesHealth = loadSitesHealth(sites)
+	if App.SitesSort == sortUnread {
+		if App.SitesUnread, err = App.tdb.GetUnreadCounts(); err != nil {
+			return fmt.Errorf("Failed to load the unread news: %v", err)
+		}
+	}
+	sortSites(sites, App.SitesSort, App.SitesHealth, App.SitesUnread)
 	data := make([]interface{}, len(sites))
 	for i, rr := range sites {
 		data[i] = rr
 	}
 
-	return SitesList.SetItems(data)
+	return App.SitesList.SetItems(data)
 }
 
 // createContentView creates a view where the contents of thecurrently selected
 // event will be displayed
 func createContentView(g *c.Gui) error {
-	tw, th := g.Size()
-	v, err := g.SetView(CONTENT_VIEW, tw/8, th/8, (tw*7)/8, (th*7)/8)
+	tw, th := App.Driver.size(g)
+	v, err := setView(g, CONTENT_VIEW, App.Layout.content(tw, th))
 	if err != nil && err != c.ErrUnknownView {
 		return err
 	}
-	ContentList = CreateList(v, false)
+	App.ContentList = CreateList(v, false)
 	setTopWindowTitle(g, CONTENT_VIEW, "")
 	_, err = g.SetCurrentView(CONTENT_VIEW)
 
@@ -124,7 +153,7 @@ func createContentView(g *c.Gui) error {
 // createPromptView creates a general purpose view to be used as input source
 // from the user
 func createPromptView(g *c.Gui, title string) error {
-	tw, th := g.Size()
+	tw, th := App.Driver.size(g)
 	v, err := g.SetView(PROMPT_VIEW, tw/6, (th/2)-1, (tw*5)/6, (th/2)+1)
 	if err != nil && err != c.ErrUnknownView {
 		return err
@@ -139,7 +168,7 @@ func createPromptView(g *c.Gui, title string) error {
 }
 
 func createHelpView(g *c.Gui, title string) error {
-	tw, th := g.Size()
+	tw, th := App.Driver.size(g)
 	v, err := g.SetView(HELP_VIEW, tw/6, th/5, (tw*5)/6, (th*4)/5)
 	if err != nil && err != c.ErrUnknownView {
 		return err
@@ -147,27 +176,143 @@ func createHelpView(g *c.Gui, title string) error {
 	v.Wrap = true
 	setTopWindowTitle(g, HELP_VIEW, title)
 
-	fmt.Fprintf(v, " %v: %v", Bold.Sprint("Tab"), "Focuses between the Sites list and the News list alternately\n\n")
-	fmt.Fprintf(v, " %v: %v", Bold.Sprint("Enter"), "Retrieves the news feed of the currently selected site or submits user input\n\n")
-	fmt.Fprintf(v, " %v: %v", Bold.Sprint("Ctrl+O"), "Downloads the content of the currently selected event.\n\n")
-	fmt.Fprintf(v, " %v: %v", Bold.Sprint("Ctrl+Alt+O"), "Opens the currently selected event using the default browser\n\n")
-	fmt.Fprintf(v, " %v: %v", Bold.Sprint("Ctrl+n"), "Prompts the user to add a new site (URL)\n\n")
-	fmt.Fprintf(v, " %v: %v", Bold.Sprint("Ctrl+f"), "Prompts the user to search among the existing sites. Multiple terms are allowed and they are used conjunctively\n\n")
-	fmt.Fprintf(v, " %v: %v", Bold.Sprint("Ctrl+q"), "Closes any window (input prompt, event content) displayed on top of the main windows\n\n")
-	fmt.Fprintf(v, " %v: %v", Bold.Sprint("Ctrl+b"), "Adds or removes the currently selected event in the bookmarks list\n\n")
-	fmt.Fprintf(v, " %v: %v", Bold.Sprint("Ctrl+Alt+b"), "Displays the bookmarked events\n\n")
-	fmt.Fprintf(v, " %v: %v", Bold.Sprint("Del"), "Deletes the selected site of the selected bookmarked event depending on which list is currently focused\n\n")
-	fmt.Fprintf(v, " %v: %v", Bold.Sprint("ArrowUp;"), "Moves to the previous list item circularly\n\n")
-	fmt.Fprintf(v, " %v: %v", Bold.Sprint("ArrowDown;"), "Moves to the next list item circularly\n\n")
-	fmt.Fprintf(v, " %v: %v", Bold.Sprint("PgUp"), "Moves to the previous list page circularly\n\n")
-	fmt.Fprintf(v, " %v: %v", Bold.Sprint("PgDn"), "Moves to the next list page circularly\n\n")
-	fmt.Fprintf(v, " %v: %v", Bold.Sprint("Ctrl+c"), "Exits the application\n\n")
-	fmt.Fprintf(v, " %v: %v", Bold.Sprint("Ctrl+h"), "Opens up the Help window\n\n")
+	fmt.Fprintf(v, " %v: %v", App.Bold.Sprint("Tab"), "Focuses between the Sites list and the News list alternately\n\n")
+	fmt.Fprintf(v, " %v: %v", App.Bold.Sprint("Enter"), "Retrieves the news feed of the currently selected site or submits user input\n\n")
+	fmt.Fprintf(v, " %v: %v", App.Bold.Sprint("Ctrl+O"), "Downloads the content of the currently selected event.\n\n")
+	fmt.Fprintf(v, " %v: %v", App.Bold.Sprint("Ctrl+Alt+O"), "Opens the currently selected event using the default browser\n\n")
+	fmt.Fprintf(v, " %v: %v", App.Bold.Sprint("Ctrl+n"), "Prompts the user to add a new site (URL of a feed or a website whose feeds are discovered)\n\n")
+	fmt.Fprintf(v, " %v: %v", App.Bold.Sprint("Ctrl+f"), "Prompts the user to search among the existing sites. Multiple terms are allowed and they are used conjunctively\n\n")
+	fmt.Fprintf(v, " %v: %v", App.Bold.Sprint("Ctrl+q"), "Closes any window (input prompt, event content) displayed on top of the main windows\n\n")
+	fmt.Fprintf(v, " %v: %v", App.Bold.Sprint("Ctrl+b"), "Adds or removes the currently selected event in the bookmarks list\n\n")
+	fmt.Fprintf(v, " %v: %v", App.Bold.Sprint("Ctrl+Alt+b"), "Displays the bookmarked events\n\n")
+	fmt.Fprintf(v, " %v: %v", App.Bold.Sprint("Ctrl+k/Ctrl+j"), "Moves the selected site up or down in the Sites list\n\n")
+	fmt.Fprintf(v, " %v: %v", App.Bold.Sprint("Ctrl+t"), "Sorts the Sites list manually, by name, by unread news, by last update or failing sites first\n\n")
+	fmt.Fprintf(v, " %v: %v", App.Bold.Sprint("Ctrl+e"), "Edits the settings of the selected site (name, refresh interval, max items, content extractor, User-Agent, headers, open in browser)\n\n")
+	fmt.Fprintf(v, " %v: %v", App.Bold.Sprint("Ctrl+d"), "Displays the fetch history of the selected site along with the last error. Sites failing to be fetched are marked with ✗\n\n")
+	fmt.Fprintf(v, " %v: %v", App.Bold.Sprint("Ctrl+r"), "Lists the rules hiding, dimming, marking as read or bookmarking the matching news. Ctrl+n adds a rule, Enter edits and Delete removes the selected one\n\n")
+	fmt.Fprintf(v, " %v: %v", App.Bold.Sprint("Ctrl+p"), "Plays the attachment (podcast episode) of the selected event with the configured player\n\n")
+	fmt.Fprintf(v, " %v: %v", App.Bold.Sprint("Ctrl+Alt+p"), "Marks the attachment of the selected event as played or unplayed\n\n")
+	fmt.Fprintf(v, " %v: %v", App.Bold.Sprint("Ctrl+g"), "Downloads the attachment of the selected event to the configured directory\n\n")
+	fmt.Fprintf(v, " %v: %v", App.Bold.Sprint("Ctrl+x"), "Exports the currently listed events to a file (Markdown, HTML, JSON lines, Netscape bookmarks, Atom or RSS)\n\n")
+	fmt.Fprintf(v, " %v: %v", App.Bold.Sprint("Del"), "Deletes the selected site of the selected bookmarked event depending on which list is currently focused\n\n")
+	fmt.Fprintf(v, " %v: %v", App.Bold.Sprint("Ctrl+z"), "Restores the last deleted site or bookmark\n\n")
+	fmt.Fprintf(v, " %v: %v", App.Bold.Sprint("</>"), "Shrinks or grows the Sites list, when it or the News list is focused\n\n")
+	fmt.Fprintf(v, " %v: %v", App.Bold.Sprint("-/+"), "Shrinks or grows the News list against the Summary\n\n")
+	fmt.Fprintf(v, " %v: %v", App.Bold.Sprint("s"), "Hides or shows the Sites list. Below 80 columns the lists are stacked vertically\n\n")
+	fmt.Fprintf(v, " %v: %v", App.Bold.Sprint("t"), "Switches to the next theme (dark, light, high-contrast, mono, dark-256)\n\n")
+	fmt.Fprintf(v, " %v: %v", App.Bold.Sprint("f"), "Toggles the event content between a window and the full screen\n\n")
+	fmt.Fprintf(v, " %v: %v", App.Bold.Sprint("ArrowUp;"), "Moves to the previous list item circularly\n\n")
+	fmt.Fprintf(v, " %v: %v", App.Bold.Sprint("ArrowDown;"), "Moves to the next list item circularly\n\n")
+	fmt.Fprintf(v, " %v: %v", App.Bold.Sprint("PgUp"), "Moves to the previous list page circularly\n\n")
+	fmt.Fprintf(v, " %v: %v", App.Bold.Sprint("PgDn"), "Moves to the next list page circularly\n\n")
+	fmt.Fprintf(v, " %v: %v", App.Bold.Sprint("Ctrl+c"), "Exits the application, the session is restored at the next start\n\n")
+	fmt.Fprintf(v, " %v: %v", App.Bold.Sprint("Ctrl+h"), "Opens up the Help window\n\n")
+	fmt.Fprintf(v, " %v: %v", App.Bold.Sprint("Ctrl+l"), "Displays the history of the messages (information, warnings and errors) shown in the status bar\n\n")
 
 	_, err = g.SetCurrentView(HELP_VIEW)
 	return err
 }
 
+// createSiteEditView creates a view listing the settings of the edited site
+func createSiteEditView(g *c.Gui) error {
+	tw, th := App.Driver.size(g)
+	v, err := g.SetView(SITE_EDIT_VIEW, tw/6, th/4, (tw*5)/6, (th*3)/4)
+	if err != nil && err != c.ErrUnknownView {
+		return err
+	}
+	App.SiteEditList = CreateList(v, false)
+	App.SiteEditList.SetTitle(fmt.Sprintf("Edit site: %v (Ctrl-q to close)", App.EditedSite.Name))
+	if err = App.SiteEditList.SetItems(siteFieldLines(App.EditedSite)); err != nil {
+		return err
+	}
+
+	return App.SiteEditList.Focus(g)
+}
+
+// createFeedsView creates a view listing the feeds discovered at a website so
+// that the user chooses the one to add
+func createFeedsView(g *c.Gui, feeds []DiscoveredFeed) error {
+	tw, th := App.Driver.size(g)
+	v, err := g.SetView(FEEDS_VIEW, tw/6, th/4, (tw*5)/6, (th*3)/4)
+	if err != nil && err != c.ErrUnknownView {
+		return err
+	}
+	App.FeedsList = CreateList(v, true)
+	App.FeedsList.SetTitle(fmt.Sprintf("%v feeds found, choose one: (Ctrl-q to close)", len(feeds)))
+
+	data := make([]interface{}, len(feeds))
+	for i, f := range feeds {
+		data[i] = f
+	}
+	if err = App.FeedsList.SetItems(data); err != nil {
+		return err
+	}
+
+	return App.FeedsList.Focus(g)
+}
+
+// createRulesView creates a view listing the rules applied on the news
+func createRulesView(g *c.Gui) error {
+	tw, th := App.Driver.size(g)
+	v, err := g.SetView(RULES_VIEW, tw/6, th/4, (tw*5)/6, (th*3)/4)
+	if err != nil && err != c.ErrUnknownView {
+		return err
+	}
+	App.RulesList = CreateList(v, true)
+	if err = loadRulesList(g); err != nil {
+		return err
+	}
+
+	return App.RulesList.Focus(g)
+}
+
+// loadRulesList displays the stored rules in the rules view
+func loadRulesList(g *c.Gui) error {
+	rules, err := App.tdb.GetRules()
+	if err != nil {
+		return err
+	}
+	App.RulesList.Reset()
+	App.RulesList.SetTitle(fmt.Sprintf("%v rule(s), Ctrl-n: new, Enter: edit, Delete: remove (Ctrl-q to close)", len(rules)))
+	data := make([]interface{}, len(rules))
+	for i, r := range rules {
+		data[i] = r
+	}
+
+	return App.RulesList.SetItems(data)
+}
+
+// createHealthView creates a view displaying the fetch history of a site
+func createHealthView(g *c.Gui, site db.Site, h db.SiteHealth) error {
+	tw, th := App.Driver.size(g)
+	v, err := g.SetView(HEALTH_VIEW, tw/8, th/8, (tw*7)/8, (th*7)/8)
+	if err != nil && err != c.ErrUnknownView {
+		return err
+	}
+	v.Clear()
+	v.Wrap = true
+	setTopWindowTitle(g, HEALTH_VIEW, fmt.Sprintf(" Health of %v", site.Name))
+	for _, line := range siteHealthLines(site, h) {
+		fmt.Fprintf(v, " %v\n", line)
+	}
+
+	_, err = g.SetCurrentView(HEALTH_VIEW)
+	return err
+}
+
+// deleteFeedsView deletes the feeds view if it exists
+func deleteFeedsView(g *c.Gui) error {
+	if _, err := g.View(FEEDS_VIEW); err != nil {
+		return nil
+	}
+	return g.DeleteView(FEEDS_VIEW)
+}
+
+// deleteSiteEditView deletes the site edit view
+func deleteSiteEditView(g *c.Gui) error {
+	return g.DeleteView(SITE_EDIT_VIEW)
+}
+
 // deleteContentView deletes the current prompt view
 func deleteContentView(g *c.Gui) error {
 	g.Cursor = false
@@ -195,16 +340,18 @@ func setTopWindowTitle(g *c.Gui, view_name, title string) {
 	v.Title = fmt.Sprintf("%v (Ctrl-q to close)", title)
 }
 
-func isNewSitePrompt(v *c.View) bool {
-	return strings.Contains(v.Title, "New site") || strings.Contains(v.Title, "try again")
-}
-
-func isFindPrompt(v *c.View) bool {
-	return strings.Contains(v.Title, "Search ")
-}
-
-func isBookmarksNews() bool {
-	return strings.Contains(NewsList.Title, "My bookmarks")
+// currentNewsEvents returns the events currently listed in the news list. The
+// bookmarked ones are replaced by their stored version.
+func currentNewsEvents() []db.Event {
+	events := make([]db.Event, 0, len(App.NewsList.items))
+	for _, item := range App.NewsList.items {
+		e := item.(db.Event)
+		if b, ok := eventInBookmarks(e); ok {
+			e = b
+		}
+		events = append(events, e)
+	}
+	return events
 }
 
 // eventSatisfiesSearch searches within thr title and the summary of an event
@@ -228,18 +375,20 @@ func findEvents(terms []string, c chan db.Event, done chan bool) {
 		done <- true
 	}()
 
-	sites, err := tdb.GetSites()
+	sites, err := App.tdb.GetSites()
 	if err != nil {
 		return
 	}
 
 	for _, site := range sites {
-		events, err := DownloadEvents(site.Url)
+		events, _, err := fetchSiteEvents(site)
 		if err != nil {
 			continue
 		}
 		for _, e := range events {
 			if eventSatisfiesSearch(terms, e) {
+				e.SiteId = site.Id
+				e.Sources = []string{site.Name}
 				c <- e
 			}
 		}
@@ -249,23 +398,25 @@ func findEvents(terms []string, c chan db.Event, done chan bool) {
 // Key binding functions
 
 func Quit(g *c.Gui, v *c.View) error {
+	if err := saveSession(g); err != nil {
+		log.Println("Error on saveSession", err)
+	}
 	return c.ErrQuit
 }
 
 func SwitchView(g *c.Gui, v *c.View) error {
 	switch v.Name() {
 	case SITES_VIEW:
-		g.SelFgColor = c.ColorGreen | c.AttrBold
-		if v == SitesList.View {
-			NewsList.Focus(g)
-			SitesList.Unfocus()
-			if strings.Contains(NewsList.Title, "bookmarks") {
-				g.SelFgColor = c.ColorMagenta | c.AttrBold
-			}
+		if v == App.SitesList.View {
+			App.NewsList.Focus(g)
+			App.SitesList.Unfocus()
 		}
 	case NEWS_VIEW:
-		SitesList.Focus(g)
-		NewsList.Unfocus()
+		if App.Layout.HideSites {
+			return nil
+		}
+		App.SitesList.Focus(g)
+		App.NewsList.Unfocus()
 	}
 
 	return nil
@@ -275,12 +426,12 @@ func ListUp(g *c.Gui, v *c.View) error {
 	switch v.Name() {
 
 	case SITES_VIEW:
-		if err := SitesList.MoveUp(); err != nil {
+		if err := App.SitesList.MoveUp(); err != nil {
 			log.Println("Error on SitesList.MoveUp()", err)
 			return err
 		}
 	case NEWS_VIEW:
-		if err := NewsList.MoveUp(); err != nil {
+		if err := App.NewsList.MoveUp(); err != nil {
 			log.Println("Error on NewsList.MoveUp()", err)
 			return err
 		}
@@ -289,10 +440,30 @@ func ListUp(g *c.Gui, v *c.View) error {
 			return err
 		}
 	case CONTENT_VIEW:
-		if err := ContentList.MoveUp(); err != nil {
+		if err := App.ContentList.MoveUp(); err != nil {
 			log.Println("Error on ContentList.MoveUp()", err)
 			return err
 		}
+	case SITE_EDIT_VIEW:
+		if err := App.SiteEditList.MoveUp(); err != nil {
+			log.Println("Error on SiteEditList.MoveUp()", err)
+			return err
+		}
+	case FEEDS_VIEW:
+		if err := App.FeedsList.MoveUp(); err != nil {
+			log.Println("Error on FeedsList.MoveUp()", err)
+			return err
+		}
+	case RULES_VIEW:
+		if err := App.RulesList.MoveUp(); err != nil {
+			log.Println("Error on RulesList.MoveUp()", err)
+			return err
+		}
+	case MESSAGES_VIEW:
+		if err := App.MessagesList.MoveUp(); err != nil {
+			log.Println("Error on MessagesList.MoveUp()", err)
+			return err
+		}
 	}
 	return nil
 }
@@ -301,12 +472,12 @@ func ListDown(g *c.Gui, v *c.View) error {
 	switch v.Name() {
 
 	case SITES_VIEW:
-		if err := SitesList.MoveDown(); err != nil {
+		if err := App.SitesList.MoveDown(); err != nil {
 			log.Println("Error on SitesList.MoveDown()", err)
 			return err
 		}
 	case NEWS_VIEW:
-		if err := NewsList.MoveDown(); err != nil {
+		if err := App.NewsList.MoveDown(); err != nil {
 			log.Println("Error on NewsList.MoveDown()", err)
 			return err
 		}
@@ -315,10 +486,30 @@ func ListDown(g *c.Gui, v *c.View) error {
 			return err
 		}
 	case CONTENT_VIEW:
-		if err := ContentList.MoveDown(); err != nil {
+		if err := App.ContentList.MoveDown(); err != nil {
 			log.Println("Error on ContentList.MoveDown()", err)
 			return err
 		}
+	case SITE_EDIT_VIEW:
+		if err := App.SiteEditList.MoveDown(); err != nil {
+			log.Println("Error on SiteEditList.MoveDown()", err)
+			return err
+		}
+	case FEEDS_VIEW:
+		if err := App.FeedsList.MoveDown(); err != nil {
+			log.Println("Error on FeedsList.MoveDown()", err)
+			return err
+		}
+	case RULES_VIEW:
+		if err := App.RulesList.MoveDown(); err != nil {
+			log.Println("Error on RulesList.MoveDown()", err)
+			return err
+		}
+	case MESSAGES_VIEW:
+		if err := App.MessagesList.MoveDown(); err != nil {
+			log.Println("Error on MessagesList.MoveDown()", err)
+			return err
+		}
 	}
 	return nil
 }
@@ -327,12 +518,12 @@ func ListPgDown(g *c.Gui, v *c.View) error {
 	switch v.Name() {
 
 	case SITES_VIEW:
-		if err := SitesList.MovePgDown(); err != nil {
+		if err := App.SitesList.MovePgDown(); err != nil {
 			log.Println("Error on SitesList.MovePgDown()", err)
 			return err
 		}
 	case NEWS_VIEW:
-		if err := NewsList.MovePgDown(); err != nil {
+		if err := App.NewsList.MovePgDown(); err != nil {
 			log.Println("Error on NewsList.MovePgDown()", err)
 			return err
 		}
@@ -341,10 +532,30 @@ func ListPgDown(g *c.Gui, v *c.View) error {
 			return err
 		}
 	case CONTENT_VIEW:
-		if err := ContentList.MovePgDown(); err != nil {
+		if err := App.ContentList.MovePgDown(); err != nil {
 			log.Println("Error on ContentList.MovePgDown()", err)
 			return err
 		}
+	case SITE_EDIT_VIEW:
+		if err := App.SiteEditList.MovePgDown(); err != nil {
+			log.Println("Error on SiteEditList.MovePgDown()", err)
+			return err
+		}
+	case FEEDS_VIEW:
+		if err := App.FeedsList.MovePgDown(); err != nil {
+			log.Println("Error on FeedsList.MovePgDown()", err)
+			return err
+		}
+	case RULES_VIEW:
+		if err := App.RulesList.MovePgDown(); err != nil {
+			log.Println("Error on RulesList.MovePgDown()", err)
+			return err
+		}
+	case MESSAGES_VIEW:
+		if err := App.MessagesList.MovePgDown(); err != nil {
+			log.Println("Error on MessagesList.MovePgDown()", err)
+			return err
+		}
 	}
 	return nil
 }
@@ -353,12 +564,12 @@ func ListPgUp(g *c.Gui, v *c.View) error {
 	switch v.Name() {
 
 	case SITES_VIEW:
-		if err := SitesList.MovePgUp(); err != nil {
+		if err := App.SitesList.MovePgUp(); err != nil {
 			log.Println("Error on SitesList.MovePgUp()", err)
 			return err
 		}
 	case NEWS_VIEW:
-		if err := NewsList.MovePgUp(); err != nil {
+		if err := App.NewsList.MovePgUp(); err != nil {
 			log.Println("Error on NewsList.MovePgUp()", err)
 			return err
 		}
@@ -367,10 +578,30 @@ func ListPgUp(g *c.Gui, v *c.View) error {
 			return err
 		}
 	case CONTENT_VIEW:
-		if err := ContentList.MovePgUp(); err != nil {
+		if err := App.ContentList.MovePgUp(); err != nil {
 			log.Println("Error on ContentList.MovePgUp()", err)
 			return err
 		}
+	case SITE_EDIT_VIEW:
+		if err := App.SiteEditList.MovePgUp(); err != nil {
+			log.Println("Error on SiteEditList.MovePgUp()", err)
+			return err
+		}
+	case FEEDS_VIEW:
+		if err := App.FeedsList.MovePgUp(); err != nil {
+			log.Println("Error on FeedsList.MovePgUp()", err)
+			return err
+		}
+	case RULES_VIEW:
+		if err := App.RulesList.MovePgUp(); err != nil {
+			log.Println("Error on RulesList.MovePgUp()", err)
+			return err
+		}
+	case MESSAGES_VIEW:
+		if err := App.MessagesList.MovePgUp(); err != nil {
+			log.Println("Error on MessagesList.MovePgUp()", err)
+			return err
+		}
 	}
 	return nil
 }
@@ -378,143 +609,356 @@ func ListPgUp(g *c.Gui, v *c.View) error {
 func OnEnter(g *c.Gui, v *c.View) error {
 	switch v.Name() {
 	case SITES_VIEW:
-		currItem := SitesList.CurrentItem()
+		currItem := App.SitesList.CurrentItem()
 		if currItem == nil {
 			return nil
 		}
 		site := currItem.(db.Site)
-
-		Summary.Clear()
-		NewsList.Clear()
-		NewsList.Focus(g)
-		g.SelFgColor = c.ColorGreen | c.AttrBold
-		NewsList.Title = " Fetching ... "
-		g.Update(func(g *c.Gui) error {
-			events, err := DownloadEvents(site.Url)
-			if err != nil {
-				NewsList.Title = fmt.Sprintf(" Failed to load news from: %v ", site.Name)
-				NewsList.Clear()
-			} else {
-				NewsList.Focus(g)
-				if err := UpdateNews(events, site.Name); err != nil {
-					log.Println("Error on UpdateNews", err)
-					return err
-				}
-				if err := UpdateSummary(); err != nil {
-					log.Println("Error on UpdateSummary", err)
-					return err
-				}
-			}
-			return nil
+		openSite(g, site)
+		update(g, func(g *c.Gui) error {
+			return showSiteNews(g, site)
 		})
-	case PROMPT_VIEW:
-		if isNewSitePrompt(v) {
-			url := strings.TrimSpace(v.ViewBuffer())
-			if len(url) == 0 {
+	case SITE_EDIT_VIEW:
+		idx := App.SiteEditList.CurrentIdx()
+		if idx < 0 {
+			return nil
+		}
+		field := siteFields[idx]
+		if field.options != nil {
+			if err := field.set(&App.EditedSite, field.nextOption(App.EditedSite)); err != nil {
+				log.Println("Error on setting site field", err)
 				return nil
 			}
-			g.Update(func(g *c.Gui) error {
-				feed, err := CheckUrl(url)
-				if err != nil {
-					setTopWindowTitle(g, PROMPT_VIEW, "Invalid URL, try again:")
-					g.SelFgColor = c.ColorRed | c.AttrBold
-					return nil
-				}
+			return saveEditedSite(g)
+		}
+		if err := openPrompt(g, editSitePrompt(idx), fmt.Sprintf("Edit %v:", field.label), field.get(App.EditedSite)); err != nil {
+			log.Println("Error on openPrompt", err)
+			return err
+		}
+	case FEEDS_VIEW:
+		currItem := App.FeedsList.CurrentItem()
+		if currItem == nil {
+			return nil
+		}
+		return addFeed(g, currItem.(DiscoveredFeed))
+	case RULES_VIEW:
+		currItem := App.RulesList.CurrentItem()
+		if currItem == nil {
+			return nil
+		}
+		rule := currItem.(db.Rule)
+		if err := openPrompt(g, rulePrompt(rule), fmt.Sprintf("Rule %v (%v):", rule.Id, ruleSyntax), rule.String()); err != nil {
+			log.Println("Error on openPrompt", err)
+			return err
+		}
+	case PROMPT_VIEW:
+		return submitPrompt(g, v.Buffer())
+	}
 
-				_, err = tdb.GetSiteByUrl(url)
-				if err != nil {
-					if _, ok := err.(db.NotFound); !ok {
-						setTopWindowTitle(g, PROMPT_VIEW, "Site already exists, try again:")
-						g.SelFgColor = c.ColorRed | c.AttrBold
-						return nil
-					}
-				} else {
-					log.Println("Error o GetSiteByUrl", err)
-					return err
-				}
+	return nil
+}
 
-				rr := db.Site{Name: feed.Title, Url: url}
-				if err := tdb.AddSite(rr); err != nil {
-					log.Println("Error on AddSite", err)
-					return err
-				}
-				deletePromptView(g)
-				g.SelFgColor = c.ColorGreen | c.AttrBold
-				SitesList.Focus(g)
+// editSitePrompt sets the field of the edited site with the given index
+func editSitePrompt(idx int) *prompt {
+	submit := func(g *c.Gui, input string) error {
+		field := siteFields[idx]
+		if err := field.set(&App.EditedSite, strings.TrimSpace(input)); err != nil {
+			retryPrompt(g, fmt.Sprintf("Edit %v (%v), retry:", field.label, err))
+			return nil
+		}
+		if err := closePrompt(g); err != nil {
+			return err
+		}
+		return saveEditedSite(g)
+	}
+	return &prompt{kind: promptEditSite, from: App.SiteEditList, submit: submit}
+}
 
-				if err = LoadSites(); err != nil {
-					log.Println("Error on LoadSites", err)
-					return err
-				}
+// rulePrompt adds a new rule or updates the given one
+func rulePrompt(rule db.Rule) *prompt {
+	submit := func(g *c.Gui, input string) error {
+		r, err := parseRule(input)
+		if err != nil {
+			retryPrompt(g, fmt.Sprintf("Rule (%v), retry:", err))
+			return nil
+		}
+		r.Id = rule.Id
+		if r.Id == 0 {
+			err = App.tdb.AddRule(r)
+		} else {
+			err = App.tdb.UpdateRule(r)
+		}
+		if err != nil {
+			log.Println("Error on saving rule", err)
+			return err
+		}
+		if err := closePrompt(g); err != nil {
+			return err
+		}
+		idx := App.RulesList.CurrentIdx()
+		if err := loadRulesList(g); err != nil {
+			log.Println("Error on loadRulesList", err)
+			return err
+		}
+		return App.RulesList.Select(idx)
+	}
+	return &prompt{kind: promptRule, from: App.RulesList, submit: submit}
+}
 
-				return nil
+// movedFeedPrompt updates the URL of a site whose feed was permanently moved
+func movedFeedPrompt(site db.Site) *prompt {
+	submit := func(g *c.Gui, input string) error {
+		newUrl := strings.TrimSpace(input)
+		if len(newUrl) == 0 {
+			return nil
+		}
+		if other, err := App.tdb.GetSiteByUrl(newUrl); err == nil && other.Id != site.Id {
+			retryPrompt(g, fmt.Sprintf("Feed moved permanently, URL used by '%v', edit:", other.Name))
+			return nil
+		}
+		site.Url = newUrl
+		if err := App.tdb.UpdateSite(site); err != nil {
+			log.Println("Error on UpdateSite", err)
+			return err
+		}
+		if App.CurrentSite.Id == site.Id {
+			App.CurrentSite = site
+		}
+		if err := closePrompt(g); err != nil {
+			return err
+		}
+		idx := App.SitesList.CurrentIdx()
+		if err := LoadSites(); err != nil {
+			log.Println("Error on LoadSites", err)
+			return err
+		}
+		return App.SitesList.Select(idx)
+	}
+	return &prompt{kind: promptMovedFeed, from: App.NewsList, submit: submit}
+}
+
+// newSitePrompt adds the feed found at the given URL as a new site letting
+// the user choose among several ones
+func newSitePrompt() *prompt {
+	submit := func(g *c.Gui, input string) error {
+		url := strings.TrimSpace(input)
+		if len(url) == 0 {
+			return nil
+		}
+		// the website is probed in the background, the UI remaining responsive
+		go func() {
+			feeds, err := DiscoverFeeds(url)
+			update(g, func(g *c.Gui) error {
+				return discoveredFeeds(g, url, feeds, err)
 			})
+		}()
+		return nil
+	}
+	return &prompt{kind: promptNewSite, from: App.SitesList, submit: submit}
+}
+
+// discoveredFeeds adds the feed discovered at the URL entered in the new site
+// prompt, or lists them if there are several
+func discoveredFeeds(g *c.Gui, url string, feeds []DiscoveredFeed, err error) error {
+	if feedUrl, ok := authRequired(err); ok {
+		// the private feeds are added as is, their credentials are
+		// set in the site edit window
+		showWarning(g, "%v requires an authentication, set it with Ctrl+e", feedUrl)
+		return addFeed(g, DiscoveredFeed{Url: feedUrl})
+	}
+	if err != nil {
+		showWarning(g, "No feed found at %v: %v", url, err)
+		retryPrompt(g, "Invalid URL, try again:")
+		return nil
+	}
+	if len(feeds) > 1 {
+		App.UI.closePrompt()
+		deletePromptView(g)
+		return createFeedsView(g, feeds)
+	}
+
+	return addFeed(g, feeds[0])
+}
+
+// exportPrompt exports the listed news to the given file
+func exportPrompt() *prompt {
+	submit := func(g *c.Gui, input string) error {
+		fields := strings.Fields(input)
+		if len(fields) == 0 {
+			return nil
 		}
-		if isFindPrompt(v) {
-			NewsList.Reset()
-			NewsList.Focus(g)
-			SitesList.Unfocus()
-			NewsList.Title = " Searching ... "
-			deletePromptView(g)
-			terms := strings.Split(strings.TrimSpace(v.ViewBuffer()), " ")
-			done := make(chan bool)
-			cevent := make(chan db.Event)
-			go findEvents(terms, cevent, done)
-			go func() {
-				ct := 0
-				for {
-					select {
-					case <-done:
-						g.Update(func(g *c.Gui) error {
-							NewsList.SetTitle(fmt.Sprintf("%v event(s) found", ct))
-							return nil
-						})
-						return
-					case event := <-cevent:
-						g.Update(func(g *c.Gui) error {
-							NewsList.AddItem(g, event)
-							NewsList.SetTitle(fmt.Sprintf("%v event(s) found so far...", ct))
-							return nil
-						})
-						ct++
-					}
-				}
-			}()
+		format := ""
+		if len(fields) > 1 {
+			format = fields[1]
+		}
+		title := strings.TrimSpace(App.NewsList.title)
+		events := currentNewsEvents()
+		if err := ExportEventsToFile(fields[0], format, title, events); err != nil {
+			showError(g, fmt.Errorf("Failed to export to %v: %v", fields[0], err))
+			retryPrompt(g, "Export to (path [md|html|json|netscape|atom|rss]) failed, retry:")
+			return nil
 		}
+		showInfo(g, "Exported %v item(s) to %v", len(events), fields[0])
+		return closePrompt(g)
 	}
+	return &prompt{kind: promptExport, from: App.NewsList, submit: submit}
+}
 
+// searchPrompt searches the news of all sites
+func searchPrompt() *prompt {
+	submit := func(g *c.Gui, input string) error {
+		terms := strings.Split(strings.TrimSpace(input), " ")
+		if err := closePrompt(g); err != nil {
+			return err
+		}
+		startSearch(g, terms)
+		return nil
+	}
+	return &prompt{kind: promptSearch, from: App.SitesList, submit: submit}
+}
+
+// openSite prepares the news list for the news of the given site
+func openSite(g *c.Gui, site db.Site) {
+	App.Summary.Clear()
+	App.NewsList.Clear()
+	App.UI.showNews(newsSite)
+	App.NewsList.Focus(g)
+	App.NewsList.Title = " Fetching ... "
+	App.FetchError = ""
+	App.CurrentSite = site
+	App.LastRefresh = time.Now()
+}
+
+// showSiteNews fetches and displays the news of the given site falling back
+// to the news stored by the previous fetches
+func showSiteNews(g *c.Gui, site db.Site) error {
+	events, info, err := fetchSiteEvents(site)
+	if err := updateSiteHealth(site); err != nil {
+		log.Println("Error on updateSiteHealth", err)
+	}
+	if err != nil {
+		// fall back to the news stored by the previous fetches
+		if stored, serr := App.tdb.GetItems(site.Id); serr == nil && len(stored) > 0 {
+			showWarning(g, "Failed to fetch %v, showing the stored news: %v", site.Name, err)
+			stored = filterEvents(site, stored)
+			App.NewsList.Focus(g)
+			if err := UpdateNews(stored, site.Name); err != nil {
+				log.Println("Error on UpdateNews", err)
+				return err
+			}
+			App.NewsList.SetTitle(fmt.Sprintf("Stored news from: %v (offline)", site.Name))
+			return UpdateSummary()
+		}
+		showError(g, fmt.Errorf("Failed to fetch %v: %v", site.Name, err))
+		App.NewsList.Title = fmt.Sprintf(" Failed to load news from: %v ", site.Name)
+		App.NewsList.Clear()
+		App.FetchError = err.Error()
+		if err := UpdateSummary(); err != nil {
+			log.Println("Error on UpdateSummary", err)
+			return err
+		}
+	} else {
+		App.NewsList.Focus(g)
+		if err := UpdateNews(events, site.Name); err != nil {
+			log.Println("Error on UpdateNews", err)
+			return err
+		}
+		if err := UpdateSummary(); err != nil {
+			log.Println("Error on UpdateSummary", err)
+			return err
+		}
+		// the prompt is focused over the displayed news
+		if len(info.MovedTo) > 0 {
+			if err := offerMovedFeed(g, site, info.MovedTo); err != nil {
+				log.Println("Error on offerMovedFeed", err)
+			}
+		}
+	}
 	return nil
 }
 
+// startSearch searches the news of all sites matching the given terms and
+// lists them as they are found
+func startSearch(g *c.Gui, terms []string) {
+	App.CurrentSite = db.Site{}
+	App.FetchError = ""
+	App.NewsList.Reset()
+	App.UI.showNews(newsSearch)
+	App.NewsList.Focus(g)
+	App.SitesList.Unfocus()
+	App.NewsList.Title = " Searching ... "
+	App.SearchTerms = terms
+	done := make(chan bool)
+	cevent := make(chan db.Event)
+	go findEvents(terms, cevent, done)
+	go func() {
+		ct := 0
+		// duplicates published by several sites are collapsed
+		var found []db.Event
+		for {
+			select {
+			case <-done:
+				update(g, func(g *c.Gui) error {
+					App.NewsList.SetTitle(fmt.Sprintf("%v event(s) found", ct))
+					idx := App.SitesList.CurrentIdx()
+					if err := LoadSites(); err != nil {
+						return err
+					}
+					return App.SitesList.Select(idx)
+				})
+				return
+			case event := <-cevent:
+				var idx int
+				if found, idx = mergeDuplicate(found, event); idx >= 0 {
+					merged := found[idx]
+					update(g, func(g *c.Gui) error {
+						// keep the item as displayed, e.g. bookmarked meanwhile
+						e, ok := App.NewsList.Item(idx).(db.Event)
+						if !ok || !isDuplicate(e, merged) {
+							return nil
+						}
+						e.Sources = merged.Sources
+						App.NewsList.UpdateItem(idx, e)
+						return App.NewsList.DrawCurrentPage()
+					})
+					continue
+				}
+				update(g, func(g *c.Gui) error {
+					App.NewsList.AddItem(g, event)
+					App.NewsList.SetTitle(fmt.Sprintf("%v event(s) found so far...", ct))
+					return nil
+				})
+				ct++
+			}
+		}
+	}()
+}
+
 func AddBookmark(g *c.Gui, v *c.View) error {
 	var err error
 	if v.Name() == NEWS_VIEW {
-		g.Update(func(g *c.Gui) error {
-			currItem := NewsList.CurrentItem()
+		update(g, func(g *c.Gui) error {
+			currItem := App.NewsList.CurrentItem()
 			if currItem == nil {
 				return nil
 			}
 			event := currItem.(db.Event)
 
 			if bookmark, ok := eventInBookmarks(event); ok {
-				if err := tdb.DeleteEvent(bookmark.Id); err != nil {
-					log.Println("Error on DeleteEvent", err)
-					return err
-				}
-				event.Title = event.Title[5:]
-			} else {
-				if err := tdb.AddEvent(event); err != nil {
-					log.Println("Error on AddEvent", err)
+				if err := deleteBookmark(g, bookmark); err != nil {
+					log.Println("Error on deleteBookmark", err)
 					return err
 				}
-				event.Title = fmt.Sprintf("  %v", event.Title)
+			} else if err := App.tdb.AddEvent(event); err != nil {
+				log.Println("Error on AddEvent", err)
+				return err
 			}
-			if CurrentBookmarks, err = tdb.GetEvents(); err != nil {
+			// the bookmarked news are marked by formatEvent
+			if App.CurrentBookmarks, err = App.tdb.GetEvents(); err != nil {
 				log.Println("Error on GetEvents", err)
 				return err
 			}
-			NewsList.UpdateCurrentItem(event)
-			if err := NewsList.DrawCurrentPage(); err != nil {
+			if err := App.NewsList.DrawCurrentPage(); err != nil {
 				log.Println("Error while updating event on bookmark", err)
 				return err
 			}
@@ -531,18 +975,24 @@ func LoadBookmarks(g *c.Gui, v *c.View) error {
 		return nil
 	}
 
-	CurrentBookmarks, err = tdb.GetEvents()
+	App.CurrentBookmarks, err = App.tdb.GetEvents()
 	if err != nil {
 		log.Println("Error on AddEvent", err)
 		return err
 	}
 	source := "My bookmarks"
+	App.CurrentSite = db.Site{}
 	if err != nil {
-		NewsList.Title = fmt.Sprintf(" Failed to load news from: %v ", source)
-		NewsList.Clear()
+		App.NewsList.Title = fmt.Sprintf(" Failed to load news from: %v ", source)
+		App.NewsList.Clear()
 	} else {
-		NewsList.Focus(g)
-		if err := UpdateNews(CurrentBookmarks, source); err != nil {
+		App.UI.showNews(newsBookmarks)
+		App.NewsList.Focus(g)
+		bookmarks, err := App.tdb.AttachEnclosures(App.CurrentBookmarks)
+		if err != nil {
+			log.Println("Error on AttachEnclosures", err)
+		}
+		if err := UpdateNews(bookmarks, source); err != nil {
 			log.Println("Error on UpdateNews", err)
 			return err
 		}
@@ -550,43 +1000,107 @@ func LoadBookmarks(g *c.Gui, v *c.View) error {
 			log.Println("Error on UpdateSummary", err)
 			return err
 		}
+		fillPendingBookmarks(g)
 	}
-	g.SelFgColor = c.ColorMagenta | c.AttrBold
 	return nil
 }
 
+// fillPendingBookmarks fetches in the background the missing details of the
+// imported bookmarks and redraws them if they are still displayed
+func fillPendingBookmarks(g *c.Gui) {
+	if App.fillingBookmarks {
+		return
+	}
+	var pending []db.Event
+	for _, b := range App.CurrentBookmarks {
+		if eventPendingDetails(b) {
+			pending = append(pending, b)
+		}
+	}
+	if len(pending) == 0 {
+		return
+	}
+
+	App.fillingBookmarks = true
+	go func() {
+		for _, b := range pending {
+			e, err := FillEventDetails(b)
+			if err != nil {
+				log.Println("Error on FillEventDetails", err)
+			}
+			if len(e.Summary) == 0 {
+				e.Summary = "No summary available"
+			}
+			if err := App.tdb.UpdateEvent(e); err != nil {
+				log.Println("Error on UpdateEvent", err)
+				continue
+			}
+			update(g, func(g *c.Gui) error {
+				for i, b := range App.CurrentBookmarks {
+					if b.Id == e.Id {
+						App.CurrentBookmarks[i] = e
+					}
+				}
+				if !isBookmarksNews() {
+					return nil
+				}
+				for i, item := range App.NewsList.items {
+					if item.(db.Event).Url == e.Url {
+						App.NewsList.items[i] = e
+					}
+				}
+				if err := App.NewsList.DrawCurrentPage(); err != nil {
+					log.Println("Error on DrawCurrentPage", err)
+					return err
+				}
+				return UpdateSummary()
+			})
+		}
+		update(g, func(g *c.Gui) error {
+			App.fillingBookmarks = false
+			return nil
+		})
+	}()
+}
+
 func DeleteEntry(g *c.Gui, v *c.View) error {
 	switch v.Name() {
 
 	case SITES_VIEW:
-		currItem := SitesList.CurrentItem()
+		currItem := App.SitesList.CurrentItem()
+		if currItem == nil {
+			return nil
+		}
+		site := currItem.(db.Site)
+		return confirmDeletion(g, fmt.Sprintf("site '%v'", site.Name), App.SitesList, func(g *c.Gui) error {
+			return deleteSite(g, site)
+		})
+	case RULES_VIEW:
+		currItem := App.RulesList.CurrentItem()
 		if currItem == nil {
 			return nil
 		}
-		rr := currItem.(db.Site)
-		if err := tdb.DeleteSite(rr.Id); err != nil {
-			log.Println("Error on DeleteSite", err)
+		if err := App.tdb.DeleteRule(currItem.(db.Rule).Id); err != nil {
+			log.Println("Error on DeleteRule", err)
 			return err
 		}
-		if err := LoadSites(); err != nil {
-			log.Println("Error on LoadSites", err)
+		if err := loadRulesList(g); err != nil {
+			log.Println("Error on loadRulesList", err)
 			return err
 		}
 	case NEWS_VIEW:
-		if strings.Contains(NewsList.Title, "My bookmarks") {
-			currItem := NewsList.CurrentItem()
+		if isBookmarksNews() {
+			currItem := App.NewsList.CurrentItem()
 			if currItem == nil {
 				return nil
 			}
 			event := currItem.(db.Event)
-			if err := tdb.DeleteEvent(event.Id); err != nil {
-				log.Println("Error on DeleteEvent", err)
-				return err
-			}
-			if err := LoadBookmarks(g, v); err != nil {
-				log.Println("Error on LoadBookmarks", err)
-				return err
-			}
+			return confirmDeletion(g, fmt.Sprintf("bookmark '%v'", event.Title), App.NewsList, func(g *c.Gui) error {
+				if err := deleteBookmark(g, event); err != nil {
+					return err
+				}
+				return LoadBookmarks(g, App.NewsList.View)
+			})
 		}
 	}
 	return nil
@@ -596,36 +1110,70 @@ func RemoveTopView(g *c.Gui, v *c.View) error {
 	switch v.Name() {
 
 	case PROMPT_VIEW:
-		SitesList.Focus(g)
-		if err := deletePromptView(g); err != nil {
-			log.Println("Error on deletePromptView", err)
+		if err := closePrompt(g); err != nil {
+			log.Println("Error on closePrompt", err)
+			return err
+		}
+	case HEALTH_VIEW:
+		App.SitesList.Focus(g)
+		if err := g.DeleteView(HEALTH_VIEW); err != nil {
+			log.Println("Error on deleting health view", err)
+			return err
+		}
+	case FEEDS_VIEW:
+		App.SitesList.Focus(g)
+		if err := deleteFeedsView(g); err != nil {
+			log.Println("Error on deleteFeedsView", err)
+			return err
+		}
+	case RULES_VIEW:
+		App.SitesList.Focus(g)
+		if err := g.DeleteView(RULES_VIEW); err != nil {
+			log.Println("Error on deleting rules view", err)
+			return err
+		}
+	case MESSAGES_VIEW:
+		if err := g.DeleteView(MESSAGES_VIEW); err != nil {
+			log.Println("Error on deleting messages view", err)
+			return err
+		}
+		if _, err := g.SetCurrentView(App.messagesFrom); err != nil {
+			App.SitesList.Focus(g)
+		}
+	case SITE_EDIT_VIEW:
+		App.SitesList.Focus(g)
+		if err := deleteSiteEditView(g); err != nil {
+			log.Println("Error on deleteSiteEditView", err)
 			return err
 		}
 	case CONTENT_VIEW:
-		NewsList.Focus(g)
+		App.NewsList.Focus(g)
 		if err := deleteContentView(g); err != nil {
 			log.Println("Error on deleteContentView", err)
 			return err
 		}
 	case HELP_VIEW:
-		NewsList.Focus(g)
+		App.NewsList.Focus(g)
 		if err := deleteHelpView(g); err != nil {
 			log.Println("Error on deleteHelpView", err)
 			return err
 		}
 	}
-	if isBookmarksNews() {
-		g.SelFgColor = c.ColorMagenta | c.AttrBold
-	} else {
-		g.SelFgColor = c.ColorGreen | c.AttrBold
-	}
+	refreshFocus(g)
 
 	return nil
 }
 
 func AddSite(g *c.Gui, v *c.View) error {
-	if err := createPromptView(g, "New site URL:"); err != nil {
-		log.Println("Error on createPromptView", err)
+	if v.Name() == RULES_VIEW {
+		if err := openPrompt(g, rulePrompt(db.Rule{}), fmt.Sprintf("Rule (%v):", ruleSyntax), ""); err != nil {
+			log.Println("Error on openPrompt", err)
+			return err
+		}
+		return nil
+	}
+	if err := openPrompt(g, newSitePrompt(), "New site URL:", ""); err != nil {
+		log.Println("Error on openPrompt", err)
 		return err
 	}
 
@@ -633,8 +1181,150 @@ func AddSite(g *c.Gui, v *c.View) error {
 }
 
 func Find(g *c.Gui, v *c.View) error {
-	if err := createPromptView(g, "Search with multiple terms:"); err != nil {
-		log.Println("Error on createPromptView", err)
+	if err := openPrompt(g, searchPrompt(), "Search with multiple terms:", ""); err != nil {
+		log.Println("Error on openPrompt", err)
+		return err
+	}
+
+	return nil
+}
+
+// addFeed stores the given feed as a new site unless it already exists
+func addFeed(g *c.Gui, feed DiscoveredFeed) error {
+	_, err := App.tdb.GetSiteByUrl(feed.Url)
+	if err == nil {
+		if App.UI.Prompt != nil {
+			retryPrompt(g, "Site already exists, try again:")
+		} else {
+			App.FeedsList.SetTitle("Site already exists, choose another feed: (Ctrl-q to close)")
+			g.SelFgColor = App.Theme.Alert
+		}
+		return nil
+	}
+	if _, ok := err.(db.NotFound); !ok {
+		log.Println("Error on GetSiteByUrl", err)
+		return err
+	}
+
+	name := feed.Title
+	if len(name) == 0 {
+		name = feed.Url
+	}
+	if err := App.tdb.AddSite(db.Site{Name: name, Url: feed.Url}); err != nil {
+		log.Println("Error on AddSite", err)
+		return err
+	}
+	App.UI.closePrompt()
+	deletePromptView(g)
+	deleteFeedsView(g)
+	App.SitesList.Focus(g)
+
+	if err = LoadSites(); err != nil {
+		log.Println("Error on LoadSites", err)
+		return err
+	}
+
+	return nil
+}
+
+// updateSiteHealth reloads the fetch history of the site and redraws the
+// sites list in case the site's marker changed
+func updateSiteHealth(site db.Site) error {
+	h, err := App.tdb.GetSiteHealth(site.Id)
+	if err != nil {
+		return err
+	}
+	if App.SitesHealth[site.Id].Failing() == h.Failing() {
+		App.SitesHealth[site.Id] = h
+		return nil
+	}
+	App.SitesHealth[site.Id] = h
+	return App.SitesList.DrawCurrentPage()
+}
+
+// offerMovedFeed prompts the user to update the URL of a feed which was
+// permanently moved
+func offerMovedFeed(g *c.Gui, site db.Site, movedTo string) error {
+	if App.UI.Prompt != nil {
+		return nil
+	}
+	return openPrompt(g, movedFeedPrompt(site), "Feed moved permanently, Enter to update its URL:", movedTo)
+}
+
+func SiteHealth(g *c.Gui, v *c.View) error {
+	currItem := App.SitesList.CurrentItem()
+	if currItem == nil {
+		return nil
+	}
+	site := currItem.(db.Site)
+	h, err := App.tdb.GetSiteHealth(site.Id)
+	if err != nil {
+		log.Println("Error on GetSiteHealth", err)
+		return err
+	}
+	if err := createHealthView(g, site, h); err != nil {
+		log.Println("Error on createHealthView", err)
+		return err
+	}
+
+	return nil
+}
+
+func EditSite(g *c.Gui, v *c.View) error {
+	currItem := App.SitesList.CurrentItem()
+	if currItem == nil {
+		return nil
+	}
+	App.EditedSite = currItem.(db.Site)
+	if err := createSiteEditView(g); err != nil {
+		log.Println("Error on createSiteEditView", err)
+		return err
+	}
+
+	return nil
+}
+
+// saveEditedSite stores the edited site and redraws the views displaying it
+func saveEditedSite(g *c.Gui) error {
+	if err := App.tdb.UpdateSite(App.EditedSite); err != nil {
+		log.Println("Error on UpdateSite", err)
+		return err
+	}
+	if App.CurrentSite.Id == App.EditedSite.Id {
+		App.CurrentSite = App.EditedSite
+	}
+
+	idx := App.SiteEditList.CurrentIdx()
+	if err := App.SiteEditList.SetItems(siteFieldLines(App.EditedSite)); err != nil {
+		log.Println("Error on SiteEditList.SetItems", err)
+		return err
+	}
+	App.SiteEditList.Select(idx)
+	App.SiteEditList.SetTitle(fmt.Sprintf("Edit site: %v (Ctrl-q to close)", App.EditedSite.Name))
+
+	idx = App.SitesList.CurrentIdx()
+	if err := LoadSites(); err != nil {
+		log.Println("Error on LoadSites", err)
+		return err
+	}
+	return App.SitesList.Select(idx)
+}
+
+func Rules(g *c.Gui, v *c.View) error {
+	if err := createRulesView(g); err != nil {
+		log.Println("Error on createRulesView", err)
+		return err
+	}
+
+	return nil
+}
+
+func Export(g *c.Gui, v *c.View) error {
+	if App.NewsList.IsEmpty() {
+		return nil
+	}
+	if err := openPrompt(g, exportPrompt(), "Export to (path [md|html|json|netscape|atom|rss]):", ""); err != nil {
+		log.Println("Error on openPrompt", err)
 		return err
 	}
 
@@ -643,31 +1333,41 @@ func Find(g *c.Gui, v *c.View) error {
 
 func LoadContent(g *c.Gui, v *c.View) error {
 	if v.Name() == NEWS_VIEW {
+		if e, ok := App.NewsList.CurrentItem().(db.Event); ok && contentSite(e).OpenInBrowser {
+			return OpenBrowser(g, v)
+		}
 		if err := createContentView(g); err != nil {
 			log.Println("Error on createContentView", err)
 			return err
 		}
-		g.SelFgColor = c.ColorGreen | c.AttrBold
+		refreshFocus(g)
 		cv, _ := g.View(CONTENT_VIEW)
 		cv.Title = "Fetching..."
-		g.Update(func(g *c.Gui) error {
-			ContentList.Focus(g)
-			currItem := NewsList.CurrentItem()
+		update(g, func(g *c.Gui) error {
+			App.ContentList.Focus(g)
+			currItem := App.NewsList.CurrentItem()
 			if currItem == nil {
 				return nil
 			}
 
-			site := SitesList.CurrentItem().(db.Site)
 			event := currItem.(db.Event)
+			site := contentSite(event)
 
-			CurrentContent, _ = GetContent(getContentURL(site, event.Url))
-			if err := UpdateContent(g, CurrentContent); err != nil {
+			content, err := GetContent(site, getContentURL(site, event.Url))
+			if err != nil {
+				App.CurrentContent = []string{fmt.Sprintf("Failed to load the content of %v: %v", event.Url, err)}
+				App.ContentList.SetTitle(fmt.Sprintf("%v - failed to load (Ctrl-q to close)", event.Title))
+				showError(g, fmt.Errorf("Failed to load the content of %v: %v", event.Title, err))
+				return UpdateContent(g, App.CurrentContent)
+			}
+			App.CurrentContent = content
+			if err := UpdateContent(g, App.CurrentContent); err != nil {
 				log.Println("Error on UpdateContent", err)
 				return err
 			}
-			ContentList.SetTitle(fmt.Sprintf("%v (Ctrl-q to close)", event.Title))
+			App.ContentList.SetTitle(fmt.Sprintf("%v (Ctrl-q to close)", event.Title))
 
-			return nil
+			return markCurrentRead()
 		})
 
 	}
@@ -675,24 +1375,24 @@ func LoadContent(g *c.Gui, v *c.View) error {
 }
 
 func UpdateContent(g *c.Gui, content []string) error {
-	w, _ := ContentList.Size()
-	ContentList.AddItem(g, "")
+	w, _ := App.ContentList.Size()
+	App.ContentList.AddItem(g, "")
 	for _, text := range content {
 		lines := JustifiedLines(text, w-2)
 		for _, l := range lines {
-			err := ContentList.AddItem(g, l)
+			err := App.ContentList.AddItem(g, l)
 			if err != nil {
 				log.Println("Error on ContentList.AddItem", err)
 				return err
 			}
 		}
-		ContentList.AddItem(g, "")
+		App.ContentList.AddItem(g, "")
 	}
 	return nil
 }
 
 func OpenBrowser(g *c.Gui, v *c.View) error {
-	currItem := NewsList.CurrentItem()
+	currItem := App.NewsList.CurrentItem()
 	if currItem == nil {
 		return nil
 	}
@@ -704,10 +1404,137 @@ func OpenBrowser(g *c.Gui, v *c.View) error {
 			log.Println("Error on opening browser", err)
 			return err
 		}
+		return markCurrentRead()
 	}
 	return nil
 }
 
+// markCurrentRead marks the current event of the news list as read
+func markCurrentRead() error {
+	currItem := App.NewsList.CurrentItem()
+	if currItem == nil {
+		return nil
+	}
+	event := currItem.(db.Event)
+	if event.Read {
+		return nil
+	}
+	if err := App.tdb.MarkItemRead(event.Url); err != nil {
+		log.Println("Error on MarkItemRead", err)
+		return err
+	}
+	event.Read = true
+	App.NewsList.UpdateCurrentItem(event)
+
+	return App.NewsList.DrawCurrentPage()
+}
+
+// currentEnclosure returns the first enclosure of the current event of the
+// news list, if any
+func currentEnclosure() (db.Enclosure, bool) {
+	currItem := App.NewsList.CurrentItem()
+	if currItem == nil {
+		return db.Enclosure{}, false
+	}
+	event := currItem.(db.Event)
+	if len(event.Enclosures) == 0 {
+		return db.Enclosure{}, false
+	}
+	return event.Enclosures[0], true
+}
+
+// updateEnclosure applies a change on the enclosure with the given URL of the
+// listed events
+func updateEnclosure(url string, update func(enc *db.Enclosure)) error {
+	for i := 0; ; i++ {
+		item := App.NewsList.Item(i)
+		if item == nil {
+			break
+		}
+		event := item.(db.Event)
+		for j := range event.Enclosures {
+			if event.Enclosures[j].Url == url {
+				encs := append([]db.Enclosure(nil), event.Enclosures...)
+				update(&encs[j])
+				event.Enclosures = encs
+				App.NewsList.UpdateItem(i, event)
+			}
+		}
+	}
+	return UpdateSummary()
+}
+
+func PlayEnclosure(g *c.Gui, v *c.View) error {
+	enc, ok := currentEnclosure()
+	if !ok {
+		return nil
+	}
+	name := enclosureFileName(enc)
+	if err := playEnclosure(enc); err != nil {
+		return fmt.Errorf("Failed to play %v: %v", name, err)
+	}
+	// the player is running anyway
+	if err := App.tdb.SetEnclosurePlayed(enc.Url, true); err != nil {
+		log.Println("Error on SetEnclosurePlayed", err)
+		showWarning(g, "Playing %v, failed to mark it played: %v", name, err)
+		return nil
+	}
+	showInfo(g, "Playing %v", name)
+
+	return updateEnclosure(enc.Url, func(e *db.Enclosure) { e.Played = true })
+}
+
+func TogglePlayed(g *c.Gui, v *c.View) error {
+	enc, ok := currentEnclosure()
+	if !ok {
+		return nil
+	}
+	if err := App.tdb.SetEnclosurePlayed(enc.Url, !enc.Played); err != nil {
+		log.Println("Error on SetEnclosurePlayed", err)
+		return err
+	}
+
+	return updateEnclosure(enc.Url, func(e *db.Enclosure) { e.Played = !enc.Played })
+}
+
+func DownloadEnclosure(g *c.Gui, v *c.View) error {
+	enc, ok := currentEnclosure()
+	if !ok {
+		return nil
+	}
+	event, _ := App.NewsList.CurrentItem().(db.Event)
+	site := contentSite(event)
+	name := enclosureFileName(enc)
+	App.Summary.Title = fmt.Sprintf(" Summary - downloading %v ", name)
+
+	go func() {
+		percent := -1
+		path, err := downloadEnclosure(site, enc, func(written, total int64) {
+			if total <= 0 {
+				return
+			}
+			// redraw only when the displayed percentage changes
+			if p := int(written * 100 / total); p != percent {
+				percent = p
+				update(g, func(g *c.Gui) error {
+					App.Summary.Title = fmt.Sprintf(" Summary - downloading %v: %v%% ", name, p)
+					return nil
+				})
+			}
+		})
+		update(g, func(g *c.Gui) error {
+			App.Summary.Title = " Summary "
+			if err != nil {
+				return fmt.Errorf("Failed to download %v: %v", name, err)
+			}
+			showInfo(g, "Downloaded %v to %v", name, path)
+			return updateEnclosure(enc.Url, func(e *db.Enclosure) { e.Path = path })
+		})
+	}()
+
+	return nil
+}
+
 func Help(g *c.Gui, v *c.View) error {
 	if err := createHelpView(g, " Help "); err != nil {
 		log.Println("Error on createHelpView", err)
@@ -717,6 +1544,127 @@ func Help(g *c.Gui, v *c.View) error {
 	return nil
 }
 
+func ShowMessages(g *c.Gui, v *c.View) error {
+	name := v.Name()
+	if name == PROMPT_VIEW || name == MESSAGES_VIEW {
+		return nil
+	}
+	App.messagesFrom = name
+	if err := createMessagesView(g); err != nil {
+		log.Println("Error on createMessagesView", err)
+		return err
+	}
+
+	return nil
+}
+
+// contentSite returns the site whose settings apply to the given news: the
+// displayed site or the one the news was found on, none for the bookmarks
+func contentSite(event db.Event) db.Site {
+	if App.CurrentSite.Id != 0 {
+		return App.CurrentSite
+	}
+	if event.SiteId == 0 {
+		return db.Site{}
+	}
+	site, err := App.tdb.GetSiteById(event.SiteId)
+	if err != nil {
+		log.Println("Error on GetSiteById", err)
+		return db.Site{}
+	}
+	return site
+}
+
+// autoRefresh fetches again the news of the displayed site whenever its
+// refresh interval elapses
+func autoRefresh(g *c.Gui) {
+	for range time.Tick(time.Minute) {
+		update(g, func(g *c.Gui) error {
+			refreshBackgroundSites(g)
+
+			site := App.CurrentSite
+			interval := time.Duration(site.RefreshInterval) * time.Minute
+			if site.Id == 0 || interval == 0 || time.Since(App.LastRefresh) < interval {
+				return nil
+			}
+			App.LastRefresh = time.Now()
+			go func() {
+				events, _, err := fetchSiteEvents(site)
+				if err != nil {
+					update(g, func(g *c.Gui) error {
+						showWarning(g, "Failed to refresh %v: %v", site.Name, err)
+						return updateSiteHealth(site)
+					})
+					return
+				}
+				update(g, func(g *c.Gui) error {
+					if err := updateSiteHealth(site); err != nil {
+						log.Println("Error on updateSiteHealth", err)
+					}
+					if App.CurrentSite.Id != site.Id {
+						return nil
+					}
+					return refreshNews(events, site.Name)
+				})
+			}()
+			return nil
+		})
+	}
+}
+
+// refreshBackgroundSites fetches in the background the sites which are not
+// displayed and whose refresh interval has elapsed since their last fetch, so
+// that their new news are stored and notified
+func refreshBackgroundSites(g *c.Gui) {
+	for i := 0; ; i++ {
+		item := App.SitesList.Item(i)
+		if item == nil {
+			break
+		}
+		site := item.(db.Site)
+		interval := time.Duration(site.RefreshInterval) * time.Minute
+		if site.Id == App.CurrentSite.Id || interval == 0 {
+			continue
+		}
+		if fetches := App.SitesHealth[site.Id].Fetches; len(fetches) > 0 && time.Since(fetches[0].FetchedAt) < interval {
+			continue
+		}
+		// the health is updated at once so that the site is not fetched
+		// again before the fetch completes
+		h := App.SitesHealth[site.Id]
+		h.Fetches = append([]db.Fetch{{SiteId: site.Id, FetchedAt: time.Now()}}, h.Fetches...)
+		App.SitesHealth[site.Id] = h
+
+		go func() {
+			_, _, err := fetchSiteEvents(site)
+			update(g, func(g *c.Gui) error {
+				if err != nil {
+					showWarning(g, "Failed to refresh %v: %v", site.Name, err)
+				}
+				return updateSiteHealth(site)
+			})
+		}()
+	}
+}
+
+// refreshNews updates the news list keeping the current item selected
+func refreshNews(events []db.Event, from string) error {
+	var selected string
+	if currItem := App.NewsList.CurrentItem(); currItem != nil {
+		selected = currItem.(db.Event).Url
+	}
+	if err := UpdateNews(events, from); err != nil {
+		return err
+	}
+	for i, e := range events {
+		if e.Url == selected {
+			App.NewsList.Select(i)
+			break
+		}
+	}
+	return UpdateSummary()
+}
+
 func getContentURL(site db.Site, contentUrl string) string {
 	if !strings.HasPrefix(contentUrl, "http") {
 		u, err := url.Parse(site.Url)
@@ -728,4 +1676,4 @@ func getContentURL(site db.Site, contentUrl string) string {
 	}
 
 	return contentUrl
-}
\ No newline at end of file
+}
@@ -42,3 +42,29 @@ func TestGetContentURL(t *testing.T) {
 		}
 	}
 }
+
+func TestContentSite(t *testing.T) {
+	newTestApp(t)
+	newTestDB(t)
+	App.tdb.AddSite(db.Site{Name: "Go", Url: "https://blog.golang.org/feed.atom", OpenInBrowser: true})
+	App.tdb.AddSite(db.Site{Name: "Rust", Url: "https://blog.rust-lang.org/feed.xml"})
+	goSite, _ := App.tdb.GetSiteByUrl("https://blog.golang.org/feed.atom")
+	rustSite, _ := App.tdb.GetSiteByUrl("https://blog.rust-lang.org/feed.xml")
+
+	// the bookmarks and the search results do not take the settings of an
+	// unrelated site
+	if site := contentSite(db.Event{Url: "https://example.org/news/1"}); site.Id != 0 {
+		t.Errorf("got site %v for a bookmark, want none", site.Name)
+	}
+	if site := contentSite(db.Event{SiteId: goSite.Id}); site.Id != goSite.Id || !site.OpenInBrowser {
+		t.Errorf("got site %+v for a search result, want %+v", site, goSite)
+	}
+	if site := contentSite(db.Event{SiteId: 1000}); site.Id != 0 {
+		t.Errorf("got site %v for a deleted site, want none", site.Name)
+	}
+
+	App.CurrentSite = rustSite
+	if site := contentSite(db.Event{}); site.Id != rustSite.Id {
+		t.Errorf("got site %v, want the displayed one", site.Name)
+	}
+}
@@ -19,6 +19,7 @@ package db
 
 import (
 	"database/sql"
+	"fmt"
 	"path"
 
 	_ "github.com/mattn/go-sqlite3"
@@ -28,9 +29,21 @@ type TDB struct {
 	*sql.DB
 }
 
+// Column describes a column which was added to a table after the table's
+// first release and therefore has to be created in already existing databases
+type Column struct {
+	Table      string
+	Name       string
+	Definition string
+}
+
+// InitDB opens the DB file of the given app directory
 func InitDB(appDir string) (*TDB, error) {
+	return Open(path.Join(appDir, "terminews.db"))
+}
 
-	dbpath := path.Join(appDir, "terminews.db")
+// Open opens the given DB file creating it and its tables if needed
+func Open(dbpath string) (*TDB, error) {
 	db, err := sql.Open("sqlite3", dbpath)
 	if err != nil || db == nil {
 		return nil, err
@@ -48,6 +61,12 @@ func (tdb *TDB) CreateTables() error {
 	ssql := []string{
 		GetSiteSql(),
 		GetEventSql(),
+		GetFetchSql(),
+		GetItemSql(),
+		GetSettingSql(),
+		GetRuleSql(),
+		GetEnclosureSql(),
+		GetSyncItemSql(),
 	}
 	for _, s := range ssql {
 		_, err := tdb.Exec(s)
@@ -56,13 +75,68 @@ func (tdb *TDB) CreateTables() error {
 		}
 	}
 
+	for _, cols := range [][]Column{GetSiteColumns(), GetEventColumns(), GetItemColumns()} {
+		if err := tdb.AddColumns(cols); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
+// AddColumns creates the given columns unless they already exist
+func (tdb *TDB) AddColumns(cols []Column) error {
+	for _, col := range cols {
+		exists, err := tdb.hasColumn(col.Table, col.Name)
+		if err != nil {
+			return err
+		}
+		if exists {
+			continue
+		}
+		sql_alter := fmt.Sprintf("ALTER TABLE %v ADD COLUMN %v %v", col.Table, col.Name, col.Definition)
+		if _, err = tdb.Exec(sql_alter); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// hasColumn checks whether the given table has a column with the given name
+func (tdb *TDB) hasColumn(table, name string) (bool, error) {
+	rows, err := tdb.Query(fmt.Sprintf("PRAGMA table_info(%v)", table))
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			cid, notnull, pk int
+			cname, ctype     string
+			dflt             sql.NullString
+		)
+		if err := rows.Scan(&cid, &cname, &ctype, &notnull, &dflt, &pk); err != nil {
+			return false, err
+		}
+		if cname == name {
+			return true, nil
+		}
+	}
+	return false, rows.Err()
+}
+
 func (tdb *TDB) DropTables() error {
 	ssql := []string{
 		"DROP TABLE site;",
 		"DROP TABLE event;",
+		"DROP TABLE fetch;",
+		"DROP TABLE item;",
+		"DROP TABLE setting;",
+		"DROP TABLE rule;",
+		"DROP TABLE enclosure;",
+		"DROP TABLE sync_item;",
 	}
 	for _, s := range ssql {
 		_, err := tdb.Exec(s)
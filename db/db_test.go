@@ -1,26 +1,28 @@
 /*
-   Terminews is a terminal based (TUI) RSS feed manager.
-   Copyright (C) 2017  Alexandros Ntavelos, a[dot]ntavelos[at]gmail[dot]com
+Terminews is a terminal based (TUI) RSS feed manager.
+Copyright (C) 2017  Alexandros Ntavelos, a[dot]ntavelos[at]gmail[dot]com
 
-   This program is free software: you can redistribute it and/or modify
-   it under the terms of the GNU General Public License as published by
-   the Free Software Foundation, either version 3 of the License, or
-   (at your option) any later version.
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
 
-   This program is distributed in the hope that it will be useful,
-   but WITHOUT ANY WARRANTY; without even the implied warranty of
-   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
-   GNU General Public License for more details.
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
 
-   You should have received a copy of the GNU General Public License
-   along with this program.  If not, see <http://www.gnu.org/licenses/>.
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
 */
 package db
 
 import (
 	"database/sql"
 	"os"
+	"reflect"
 	"testing"
+	"time"
 
 	_ "github.com/mattn/go-sqlite3"
 )
@@ -65,7 +67,7 @@ func TestSite(t *testing.T) {
 	result, _ := tdb.GetSites()
 	t.Log(result)
 	if len(result) != 2 {
-		t.Errorf("Found %v Site records %v, want %v",
+		t.Errorf("Found %v Site records, want %v",
 			len(result), len(items))
 	}
 	for i, res := range result {
@@ -122,7 +124,7 @@ func TestEvent(t *testing.T) {
 	result, _ := tdb.GetEvents()
 	t.Log(result)
 	if len(result) != 2 {
-		t.Errorf("Found %v Event records %v, want %v",
+		t.Errorf("Found %v Event records, want %v",
 			len(result), len(items))
 	}
 	if result[1].Title != items[0].Title {
@@ -157,3 +159,390 @@ func TestEvent(t *testing.T) {
 		t.Errorf("Expected NotFound error for id 12345")
 	}
 }
+
+func TestEventUpdate(t *testing.T) {
+	tdb.AddEvent(Event{Title: "imported", Url: "www.news.com/imported"})
+
+	e, err := tdb.GetEventByUrl("www.news.com/imported")
+	if err != nil {
+		t.Fatalf("Expected event for url www.news.com/imported, got %v", err)
+	}
+
+	e.Title = "updated"
+	e.Tags = "news,world"
+	if err = tdb.UpdateEvent(e); err != nil {
+		t.Fatalf("Failed to update event %v: %v", e.Id, err)
+	}
+	record, _ := tdb.GetEventById(e.Id)
+	if record.Title != "updated" || record.Tags != "news,world" {
+		t.Errorf("Event record %v has title %v and tags %v, want updated and news,world",
+			record.Id, record.Title, record.Tags)
+	}
+
+	_, err = tdb.GetEventByUrl("www.news.com/unknown")
+	if _, ok := err.(NotFound); !ok {
+		t.Errorf("Expected NotFound error for url www.news.com/unknown")
+	}
+
+	err = tdb.UpdateEvent(Event{Id: 12345})
+	if _, ok := err.(NotFound); !ok {
+		t.Errorf("Expected NotFound error for id 12345")
+	}
+}
+
+func TestSiteUpdate(t *testing.T) {
+	tdb.AddSite(Site{Name: "Reuters", Url: "www.reuters.com", RefreshInterval: 5})
+
+	site, err := tdb.GetSiteByUrl("www.reuters.com")
+	if err != nil {
+		t.Fatalf("Expected site for url www.reuters.com, got %v", err)
+	}
+	if site.RefreshInterval != 5 {
+		t.Errorf("Site record %v has refresh interval %v, want 5", site.Id, site.RefreshInterval)
+	}
+
+	site.Name = "Reuters World"
+	site.MaxItems = 20
+	site.OpenInBrowser = true
+	site.Proxy = "tor"
+	site.Auth = "basic"
+	site.AuthUser = "reader"
+	site.AuthSecret = "env:READER_PASSWORD"
+	if err = tdb.UpdateSite(site); err != nil {
+		t.Fatalf("Failed to update site %v: %v", site.Id, err)
+	}
+	record, _ := tdb.GetSiteById(site.Id)
+	if record != site {
+		t.Errorf("Site record %v is %v, want %v", record.Id, record, site)
+	}
+
+	err = tdb.UpdateSite(Site{Id: 12345})
+	if _, ok := err.(NotFound); !ok {
+		t.Errorf("Expected NotFound error for id 12345")
+	}
+}
+
+func TestAddColumns(t *testing.T) {
+	tdb.Exec(`CREATE TABLE legacy(Id INTEGER NOT NULL PRIMARY KEY ASC, Name TEXT)`)
+	tdb.Exec(`INSERT INTO legacy(Name) values('old')`)
+	defer tdb.Exec(`DROP TABLE legacy`)
+
+	cols := []Column{{"legacy", "Extra", "TEXT NOT NULL DEFAULT 'default'"}}
+	for i := 0; i < 2; i++ {
+		if err := tdb.AddColumns(cols); err != nil {
+			t.Fatalf("Failed to add columns: %v", err)
+		}
+	}
+
+	var extra string
+	if err := tdb.QueryRow(`SELECT Extra FROM legacy`).Scan(&extra); err != nil || extra != "default" {
+		t.Errorf("Found extra column %v (%v), want default", extra, err)
+	}
+}
+
+func TestSiteHealth(t *testing.T) {
+	const siteId = 42
+	now := time.Now()
+	fetches := []Fetch{
+		{SiteId: siteId, FetchedAt: now.Add(-3 * time.Hour), Status: 200, Latency: 100 * time.Millisecond},
+		{SiteId: siteId, FetchedAt: now.Add(-2 * time.Hour), Status: 500, Latency: 200 * time.Millisecond, Error: "500 Internal Server Error"},
+		{SiteId: siteId, FetchedAt: now.Add(-1 * time.Hour), Error: "connection refused"},
+	}
+	for _, f := range fetches {
+		if err := tdb.AddFetch(f); err != nil {
+			t.Fatalf("Failed to add fetch: %v", err)
+		}
+	}
+
+	h, err := tdb.GetSiteHealth(siteId)
+	if err != nil {
+		t.Fatalf("Failed to get site health: %v", err)
+	}
+	if !h.Failing() || h.ConsecutiveFailures != 2 {
+		t.Errorf("Found %v consecutive failures, want 2", h.ConsecutiveFailures)
+	}
+	if h.LastError != "connection refused" || h.LastStatus != 0 {
+		t.Errorf("Found last error '%v' with status %v, want 'connection refused' with 0", h.LastError, h.LastStatus)
+	}
+	if !h.LastSuccess.Equal(fetches[0].FetchedAt) {
+		t.Errorf("Found last success %v, want %v", h.LastSuccess, fetches[0].FetchedAt)
+	}
+	if h.AverageLatency != 100*time.Millisecond {
+		t.Errorf("Found average latency %v, want 100ms", h.AverageLatency)
+	}
+
+	for i := 0; i < maxFetchesPerSite; i++ {
+		tdb.AddFetch(Fetch{SiteId: siteId, FetchedAt: now, Status: 200})
+	}
+	if h, _ = tdb.GetSiteHealth(siteId); len(h.Fetches) != maxFetchesPerSite || h.Failing() {
+		t.Errorf("Found %v fetches (failing: %v), want %v", len(h.Fetches), h.Failing(), maxFetchesPerSite)
+	}
+
+	tdb.DeleteFetches(siteId)
+	if h, _ = tdb.GetSiteHealth(siteId); len(h.Fetches) != 0 {
+		t.Errorf("Found %v fetches after deletion, want 0", len(h.Fetches))
+	}
+}
+
+func TestPruneItems(t *testing.T) {
+	tdb.AddSite(Site{Name: "Prune", Url: "www.prune.com"})
+	sites, _ := tdb.GetSites()
+	siteId := sites[len(sites)-1].Id
+
+	var events []Event
+	for _, u := range []string{"a", "b", "c", "d"} {
+		events = append(events, Event{Title: u, Url: "http://www.prune.com/" + u})
+	}
+	added, err := tdb.SaveItems(siteId, events)
+	if err != nil || len(added) != 4 {
+		t.Fatalf("Saved %v items (%v), want 4", len(added), err)
+	}
+	if added, _ = tdb.SaveItems(siteId, events[:2]); len(added) != 0 {
+		t.Errorf("Saved %v already stored items, want 0", len(added))
+	}
+	// the bookmarked items are never pruned
+	tdb.AddEvent(Event{Title: "d", Url: events[3].Url, Tags: "keep"})
+	tdb.Exec(`UPDATE item SET FetchedAt = ? WHERE Url IN (?, ?)`,
+		time.Now().Add(-48*time.Hour), events[0].Url, events[3].Url)
+
+	pruned, err := tdb.PruneItems(RetentionPolicy{MaxAge: 24 * time.Hour})
+	if err != nil || pruned != 1 {
+		t.Errorf("Pruned %v items by age (%v), want 1", pruned, err)
+	}
+	pruned, err = tdb.PruneItems(RetentionPolicy{MaxItemsPerSite: 1})
+	if err != nil || pruned != 1 {
+		t.Errorf("Pruned %v items by count (%v), want 1", pruned, err)
+	}
+	items, _ := tdb.GetItems(siteId)
+	if len(items) != 2 || items[0].Url != events[1].Url || items[1].Url != events[3].Url {
+		t.Errorf("Found items %v, want [b d]", items)
+	}
+
+	st, err := tdb.GetStats()
+	if err != nil {
+		t.Fatalf("Failed to get stats: %v", err)
+	}
+	if st.Size == 0 || st.Sites[len(st.Sites)-1].Items != 2 {
+		t.Errorf("Found stats %+v, want 2 items for the last site", st)
+	}
+
+	tdb.DeleteSite(siteId)
+	if items, _ = tdb.GetItems(siteId); len(items) != 0 {
+		t.Errorf("Found %v items of a deleted site, want 0", len(items))
+	}
+	if err = tdb.Vacuum(); err != nil {
+		t.Errorf("Failed to vacuum: %v", err)
+	}
+}
+
+func TestFindItems(t *testing.T) {
+	tdb.AddSite(Site{Name: "Find", Url: "www.find.com"})
+	site, _ := tdb.GetSiteByUrl("www.find.com")
+	tdb.SaveItems(site.Id, []Event{
+		{Title: "Go 1.16", Summary: "released", Url: "http://www.find.com/1"},
+		{Title: "Rust 1.50", Summary: "released", Url: "http://www.find.com/2", Categories: []string{"Rust", "Release"}},
+		{Title: "Go modules", Url: "http://www.find.com/3"},
+	})
+
+	items, total, err := tdb.FindItems(ItemFilter{SiteId: site.Id, Terms: []string{"go"}, Limit: 1})
+	if err != nil || total != 2 || len(items) != 1 || items[0].Title != "Go 1.16" || items[0].SiteId != site.Id {
+		t.Fatalf("Found %v of %v items (%v), want the first of 2", items, total, err)
+	}
+	if err = tdb.SetItemRead(items[0].Id, true); err != nil {
+		t.Fatal(err)
+	}
+	items, total, _ = tdb.FindItems(ItemFilter{SiteId: site.Id, Unread: true, Terms: []string{"released"}})
+	if total != 1 || items[0].Title != "Rust 1.50" {
+		t.Errorf("Found %v unread released items, want Rust 1.50", items)
+	}
+	items, total, _ = tdb.FindItems(ItemFilter{Category: "release"})
+	if total != 1 || !reflect.DeepEqual(items[0].Categories, []string{"Rust", "Release"}) {
+		t.Errorf("Found %v items of the release category, want Rust 1.50", items)
+	}
+	// the commas and the wildcards of the categories are matched as is
+	tdb.SaveItems(site.Id, []Event{
+		{Title: "Zig 0.7", Url: "http://www.find.com/4", Categories: []string{"Zig, Nim", "100%_fun", `a\b`}},
+	})
+	for category, want := range map[string]int{
+		"Zig, Nim": 1, "zig": 0, "nim": 0, "100%_fun": 1, "100%": 0, "1%": 0, "_": 0, `a\b`: 1, "%": 0, "rust": 1,
+	} {
+		if _, total, _ = tdb.FindItems(ItemFilter{Category: category}); total != want {
+			t.Errorf("Found %v items of the %q category, want %v", total, category, want)
+		}
+	}
+	items, _, _ = tdb.FindItems(ItemFilter{Url: "http://www.find.com/4"})
+	if len(items) != 1 || !reflect.DeepEqual(items[0].Categories, []string{"Zig, Nim", "100%_fun", `a\b`}) {
+		t.Errorf("Found %v, want the categories as saved", items)
+	}
+	if err = tdb.SetItemRead(0, true); err == nil {
+		t.Errorf("Expected NotFound error for unknown item")
+	}
+	if err = tdb.SetItemsCanonicalUrl("http://www.find.com/3", "http://www.find.com/go-modules"); err != nil {
+		t.Fatal(err)
+	}
+	if urls, err := tdb.GetCanonicalUrls(); err != nil || !reflect.DeepEqual(urls, map[string]string{"http://www.find.com/3": "http://www.find.com/go-modules"}) {
+		t.Errorf("Found canonical URLs %v (%v)", urls, err)
+	}
+	if _, err = tdb.GetItemById(0); err == nil {
+		t.Errorf("Expected NotFound error for unknown item")
+	}
+}
+
+func TestSetting(t *testing.T) {
+	if _, err := tdb.GetSetting("missing"); err == nil {
+		t.Errorf("Expected NotFound error for a missing setting")
+	}
+	tdb.SetSetting("name", "1")
+	tdb.SetSetting("name", "2")
+	if value, _ := tdb.GetSetting("name"); value != "2" {
+		t.Errorf("Found setting value '%v', want '2'", value)
+	}
+	tdb.DeleteSetting("name")
+	if settings, _ := tdb.GetSettings(); len(settings) != 0 {
+		t.Errorf("Found settings %v after deletion, want none", settings)
+	}
+}
+
+func TestRule(t *testing.T) {
+	tdb.AddRule(Rule{Action: "hide", Field: "keyword", Pattern: "sports"})
+	rules, _ := tdb.GetRules()
+	if len(rules) != 1 || rules[0].String() != "hide keyword sports" {
+		t.Fatalf("Found rules %v, want [hide keyword sports]", rules)
+	}
+
+	r := rules[0]
+	r.Action = "dim"
+	if err := tdb.UpdateRule(r); err != nil {
+		t.Errorf("Failed to update rule: %v", err)
+	}
+	if r, _ = tdb.GetRuleById(r.Id); r.Action != "dim" {
+		t.Errorf("Found action %v, want dim", r.Action)
+	}
+	if err := tdb.UpdateRule(Rule{Id: 12345}); err == nil {
+		t.Errorf("Expected NotFound error for id 12345")
+	}
+
+	tdb.DeleteRule(r.Id)
+	if _, err := tdb.GetRuleById(r.Id); err == nil {
+		t.Errorf("Expected NotFound error for id %v", r.Id)
+	}
+}
+
+func TestEnclosure(t *testing.T) {
+	const itemUrl = "https://podcast.example.org/1"
+	enc := Enclosure{Url: "https://podcast.example.org/1.mp3", Type: "audio/mpeg", Length: 42}
+	tdb.SaveEnclosures(itemUrl, []Enclosure{enc})
+	tdb.SetEnclosurePlayed(enc.Url, true)
+
+	// the played state survives the next fetches
+	enc.Duration = "1:00"
+	if err := tdb.SaveEnclosures(itemUrl, []Enclosure{enc}); err != nil {
+		t.Fatalf("Failed to save enclosures: %v", err)
+	}
+	events, err := tdb.AttachEnclosures([]Event{{Url: itemUrl}, {Url: "https://podcast.example.org/2"}})
+	if err != nil {
+		t.Fatalf("Failed to attach enclosures: %v", err)
+	}
+	if encs := events[0].Enclosures; len(encs) != 1 || !encs[0].Played || encs[0].Duration != "1:00" {
+		t.Errorf("Found enclosures %+v, want the played one", encs)
+	}
+	if len(events[1].Enclosures) != 0 {
+		t.Errorf("Found enclosures %+v, want none", events[1].Enclosures)
+	}
+
+	// enclosures of news which are neither stored nor bookmarked are pruned
+	tdb.PruneItems(RetentionPolicy{})
+	if encs, _ := tdb.GetEnclosures(itemUrl); len(encs) != 0 {
+		t.Errorf("Found enclosures %+v after pruning, want none", encs)
+	}
+}
+
+func TestSoftDelete(t *testing.T) {
+	tdb.AddSite(Site{Name: "Trash", Url: "www.trash.com"})
+	site, _ := tdb.GetSiteByUrl("www.trash.com")
+	tdb.SaveItems(site.Id, []Event{{Title: "a", Url: "http://www.trash.com/a"}})
+	tdb.AddEvent(Event{Title: "b", Url: "http://www.trash.com/b"})
+	bookmark, _ := tdb.GetEventByUrl("http://www.trash.com/b")
+
+	if err := tdb.SoftDeleteSite(site.Id); err != nil {
+		t.Fatalf("Failed to soft delete site: %v", err)
+	}
+	if err := tdb.SoftDeleteEvent(bookmark.Id); err != nil {
+		t.Fatalf("Failed to soft delete event: %v", err)
+	}
+	if _, err := tdb.GetSiteById(site.Id); err == nil {
+		t.Errorf("Found soft deleted site %v", site.Id)
+	}
+	if _, err := tdb.GetEventByUrl(bookmark.Url); err == nil {
+		t.Errorf("Found soft deleted event %v", bookmark.Id)
+	}
+	if items, _, _ := tdb.FindItems(ItemFilter{Url: "http://www.trash.com/a"}); len(items) != 0 {
+		t.Errorf("Found items %v of a soft deleted site, want none", items)
+	}
+	if _, ok := tdb.SoftDeleteSite(site.Id).(NotFound); !ok {
+		t.Errorf("Expected NotFound error when deleting twice site %v", site.Id)
+	}
+
+	if err := tdb.RestoreSite(site.Id); err != nil {
+		t.Errorf("Failed to restore site: %v", err)
+	}
+	if restored, _ := tdb.GetSiteById(site.Id); restored != site {
+		t.Errorf("Found restored site %+v, want %+v", restored, site)
+	}
+	if items, _ := tdb.GetItems(site.Id); len(items) != 1 {
+		t.Errorf("Found %v items of the restored site, want 1", len(items))
+	}
+
+	// the bookmark cannot be restored once bookmarked again
+	tdb.AddEvent(Event{Title: "b again", Url: bookmark.Url})
+	if err := tdb.RestoreEvent(bookmark.Id); err == nil {
+		t.Errorf("Restored event %v bookmarked again", bookmark.Id)
+	}
+
+	tdb.SoftDeleteSite(site.Id)
+	purged, err := tdb.PurgeDeleted(time.Now().Add(-time.Hour))
+	if err != nil || purged != 0 {
+		t.Errorf("Purged %v (%v) recently deleted records, want 0", purged, err)
+	}
+	purged, err = tdb.PurgeDeleted(time.Now().Add(time.Minute))
+	if err != nil || purged != 2 {
+		t.Errorf("Purged %v (%v) deleted records, want 2", purged, err)
+	}
+	if _, ok := tdb.RestoreSite(site.Id).(NotFound); !ok {
+		t.Errorf("Expected NotFound error when restoring purged site %v", site.Id)
+	}
+	if items, _ := tdb.GetItems(site.Id); len(items) != 0 {
+		t.Errorf("Found %v items of a purged site, want 0", len(items))
+	}
+}
+
+func TestSitePositions(t *testing.T) {
+	for _, name := range []string{"First", "Second", "Third"} {
+		tdb.AddSite(Site{Name: name, Url: "www." + name + ".com"})
+	}
+	sites, _ := tdb.GetSites()
+	n := len(sites)
+	if n < 3 || sites[n-3].Name != "First" || sites[n-1].Name != "Third" {
+		t.Fatalf("Found sites %v, want the new ones appended", sites)
+	}
+
+	ids := make([]int, n)
+	for i, s := range sites {
+		ids[i] = s.Id
+	}
+	ids[n-1], ids[0] = ids[0], ids[n-1]
+	if err := tdb.SetSitePositions(ids); err != nil {
+		t.Fatalf("Failed to set site positions: %v", err)
+	}
+	reordered, _ := tdb.GetSites()
+	if reordered[0] != sites[n-1] || reordered[n-1] != sites[0] {
+		t.Errorf("Found sites %v, want the first and the last swapped", reordered)
+	}
+
+	tdb.SaveItems(sites[n-1].Id, []Event{{Url: "http://www.third.com/1"}, {Url: "http://www.third.com/2"}})
+	tdb.MarkItemRead("http://www.third.com/1")
+	counts, err := tdb.GetUnreadCounts()
+	if err != nil || counts[sites[n-1].Id] != 1 || counts[sites[n-2].Id] != 0 {
+		t.Errorf("Found unread counts %v (%v), want 1 for the last site", counts, err)
+	}
+}
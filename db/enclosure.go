@@ -0,0 +1,163 @@
+/*
+   Terminews is a terminal based (TUI) RSS feed manager.
+   Copyright (C) 2017  Alexandros Ntavelos, a[dot]ntavelos[at]gmail[dot]com
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+package db
+
+import (
+	"database/sql"
+	"strings"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// Enclosure is a media file attached to a news, e.g. the episode of a podcast
+type Enclosure struct {
+	Url  string
+	Type string
+	// Length is the size in bytes advertised by the feed, zero if unknown
+	Length int64
+	// Duration is the playing time advertised by the feed, e.g. "1:02:03"
+	Duration string
+	Played   bool
+	// Path is the file the enclosure was downloaded to, if any
+	Path string
+}
+
+func GetEnclosureSql() string {
+	return `
+    CREATE TABLE IF NOT EXISTS enclosure(
+        Url TEXT NOT NULL PRIMARY KEY,
+        ItemUrl TEXT NOT NULL,
+        Type TEXT NOT NULL DEFAULT '',
+        Length INTEGER NOT NULL DEFAULT 0,
+        Duration TEXT NOT NULL DEFAULT '',
+        Played INTEGER NOT NULL DEFAULT 0,
+        Path TEXT NOT NULL DEFAULT ''
+    );`
+}
+
+// execer is implemented by both sql.DB and sql.Tx
+type execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+// SaveEnclosures stores the enclosures of a news keeping the played state and
+// the downloaded file of the already stored ones
+func (tdb *TDB) SaveEnclosures(itemUrl string, encs []Enclosure) error {
+	return saveEnclosures(tdb, itemUrl, encs)
+}
+
+func saveEnclosures(ex execer, itemUrl string, encs []Enclosure) error {
+	sql_upsert := `
+    INSERT INTO enclosure(
+        Url,
+        ItemUrl,
+        Type,
+        Length,
+        Duration
+    ) values(?, ?, ?, ?, ?)
+    ON CONFLICT(Url) DO UPDATE SET
+        ItemUrl = excluded.ItemUrl,
+        Type = excluded.Type,
+        Length = excluded.Length,
+        Duration = excluded.Duration
+    `
+
+	for _, enc := range encs {
+		if _, err := ex.Exec(sql_upsert, enc.Url, itemUrl, enc.Type, enc.Length, enc.Duration); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetEnclosures returns the stored enclosures of the news with the given URLs
+// by news URL
+func (tdb *TDB) GetEnclosures(itemUrls ...string) (map[string][]Enclosure, error) {
+	// stay below the maximum number of parameters of a statement
+	const batch = 500
+
+	result := map[string][]Enclosure{}
+	for len(itemUrls) > 0 {
+		n := len(itemUrls)
+		if n > batch {
+			n = batch
+		}
+		args := make([]interface{}, n)
+		for i, u := range itemUrls[:n] {
+			args[i] = u
+		}
+		itemUrls = itemUrls[n:]
+
+		sql_readall := `
+        SELECT ItemUrl, Url, Type, Length, Duration, Played, Path FROM enclosure
+        WHERE ItemUrl IN (?` + strings.Repeat(", ?", n-1) + `)
+        ORDER BY rowid ASC
+        `
+		rows, err := tdb.Query(sql_readall, args...)
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			var (
+				itemUrl string
+				enc     Enclosure
+			)
+			if err := rows.Scan(&itemUrl, &enc.Url, &enc.Type, &enc.Length, &enc.Duration, &enc.Played, &enc.Path); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			result[itemUrl] = append(result[itemUrl], enc)
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return result, nil
+}
+
+// AttachEnclosures sets the stored enclosures of the given events
+func (tdb *TDB) AttachEnclosures(events []Event) ([]Event, error) {
+	urls := make([]string, len(events))
+	for i, e := range events {
+		urls[i] = e.Url
+	}
+	encs, err := tdb.GetEnclosures(urls...)
+	if err != nil {
+		return events, err
+	}
+	for i := range events {
+		if stored, ok := encs[events[i].Url]; ok {
+			events[i].Enclosures = stored
+		}
+	}
+	return events, nil
+}
+
+func (tdb *TDB) SetEnclosurePlayed(url string, played bool) error {
+	_, err := tdb.Exec(`UPDATE enclosure SET Played = ? WHERE Url = ?`, played, url)
+	return err
+}
+
+// SetEnclosurePath records the file an enclosure was downloaded to
+func (tdb *TDB) SetEnclosurePath(url, path string) error {
+	_, err := tdb.Exec(`UPDATE enclosure SET Path = ? WHERE Url = ?`, path, url)
+	return err
+}
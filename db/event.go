@@ -21,6 +21,7 @@ import (
 	"database/sql"
 	"fmt"
 	"net/url"
+	"strings"
 
 	_ "github.com/mattn/go-sqlite3"
 )
@@ -32,6 +33,21 @@ type Event struct {
 	Url       string
 	Summary   string
 	Published string
+	Tags      string
+	// Sources are the names of the sites publishing the event, they are not
+	// stored
+	Sources []string
+	// Categories are the categories of the event in its feed, they are not
+	// stored
+	Categories []string
+	// Read indicates whether the stored news was read
+	Read bool
+	// Dimmed is set by the rules dimming the event
+	Dimmed bool
+	// Enclosures are the media files attached to the event
+	Enclosures []Enclosure
+	// SiteId is the site of a stored news, it is not set for the bookmarks
+	SiteId int
 }
 
 func GetEventSql() string {
@@ -42,13 +58,25 @@ func GetEventSql() string {
         Author TEXT,
         Url TEXT,
         Summary TEXT,
-        Published TEXT
+        Published TEXT,
+        Tags TEXT NOT NULL DEFAULT '',
+        DeletedAt DATETIME
     );`
 }
 
+// GetEventColumns returns the columns added to the event table after its
+// creation
+func GetEventColumns() []Column {
+	return []Column{
+		{"event", "Tags", "TEXT NOT NULL DEFAULT ''"},
+		{"event", "DeletedAt", "DATETIME"},
+	}
+}
+
 func (tdb *TDB) GetEvents() ([]Event, error) {
 	sql_readall := `
-    SELECT Id, Title, Author, Url, Summary, Published FROM event
+    SELECT Id, Title, Author, Url, Summary, Published, Tags FROM event
+    WHERE DeletedAt IS NULL
     ORDER BY id DESC
     `
 
@@ -61,7 +89,7 @@ func (tdb *TDB) GetEvents() ([]Event, error) {
 	var result []Event
 	for rows.Next() {
 		e := Event{}
-		if err := rows.Scan(&e.Id, &e.Title, &e.Author, &e.Url, &e.Summary, &e.Published); err != nil {
+		if err := rows.Scan(&e.Id, &e.Title, &e.Author, &e.Url, &e.Summary, &e.Published, &e.Tags); err != nil {
 			return nil, err
 		}
 		result = append(result, e)
@@ -70,7 +98,7 @@ func (tdb *TDB) GetEvents() ([]Event, error) {
 }
 
 func (tdb *TDB) GetEventById(id int) (Event, error) {
-	sql_readone := `SELECT Id, Title, Author, Url, Summary, Published FROM event WHERE id = ?`
+	sql_readone := `SELECT Id, Title, Author, Url, Summary, Published, Tags FROM event WHERE id = ? AND DeletedAt IS NULL`
 
 	stmt, err := tdb.Prepare(sql_readone)
 	defer stmt.Close()
@@ -79,7 +107,7 @@ func (tdb *TDB) GetEventById(id int) (Event, error) {
 	}
 
 	var e Event
-	if err = stmt.QueryRow(id).Scan(&e.Id, &e.Title, &e.Author, &e.Url, &e.Summary, &e.Published); err != nil {
+	if err = stmt.QueryRow(id).Scan(&e.Id, &e.Title, &e.Author, &e.Url, &e.Summary, &e.Published, &e.Tags); err != nil {
 		if err == sql.ErrNoRows {
 			return Event{}, NotFound(fmt.Sprintf("Event not found for id: %v", id))
 		}
@@ -89,6 +117,26 @@ func (tdb *TDB) GetEventById(id int) (Event, error) {
 	return e, nil
 }
 
+func (tdb *TDB) GetEventByUrl(url string) (Event, error) {
+	sql_readone := `SELECT Id, Title, Author, Url, Summary, Published, Tags FROM event WHERE Url = ? AND DeletedAt IS NULL`
+
+	stmt, err := tdb.Prepare(sql_readone)
+	if err != nil {
+		return Event{}, err
+	}
+	defer stmt.Close()
+
+	var e Event
+	if err = stmt.QueryRow(url).Scan(&e.Id, &e.Title, &e.Author, &e.Url, &e.Summary, &e.Published, &e.Tags); err != nil {
+		if err == sql.ErrNoRows {
+			return Event{}, NotFound(fmt.Sprintf("Event not found for url: %v", url))
+		}
+		return Event{}, err
+	}
+
+	return e, nil
+}
+
 func (tdb *TDB) AddEvent(e Event) error {
 	sql_additem := `
     INSERT OR REPLACE INTO event(
@@ -96,8 +144,9 @@ func (tdb *TDB) AddEvent(e Event) error {
         Author,
         Url,
         Summary,
-        Published
-    ) values(?, ?, ?, ?, ?)
+        Published,
+        Tags
+    ) values(?, ?, ?, ?, ?, ?)
     `
 
 	stmt, err := tdb.Prepare(sql_additem)
@@ -106,9 +155,39 @@ func (tdb *TDB) AddEvent(e Event) error {
 		return err
 	}
 
-	if _, err = stmt.Exec(e.Title, e.Author, e.Url, e.Summary, e.Published); err != nil {
+	if _, err = stmt.Exec(e.Title, e.Author, e.Url, e.Summary, e.Published, e.Tags); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// UpdateEvent stores all the fields of an existing event
+func (tdb *TDB) UpdateEvent(e Event) error {
+	sql_update := `
+    UPDATE event SET
+        Title = ?,
+        Author = ?,
+        Url = ?,
+        Summary = ?,
+        Published = ?,
+        Tags = ?
+    WHERE id = ?
+    `
+
+	stmt, err := tdb.Prepare(sql_update)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	res, err := stmt.Exec(e.Title, e.Author, e.Url, e.Summary, e.Published, e.Tags, e.Id)
+	if err != nil {
 		return err
 	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return NotFound(fmt.Sprintf("Event not found for id: %v", e.Id))
+	}
 
 	return nil
 }
@@ -133,10 +212,56 @@ func (tdb *TDB) DeleteEvent(id int) error {
 	return nil
 }
 
+// SoftDeleteEvent marks a bookmark as deleted so that it is not listed any
+// more but can be restored until it is purged
+func (tdb *TDB) SoftDeleteEvent(id int) error {
+	sql_delete := `UPDATE event SET DeletedAt = CURRENT_TIMESTAMP WHERE id = ? AND DeletedAt IS NULL`
+
+	res, err := tdb.Exec(sql_delete, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return NotFound(fmt.Sprintf("Event not found for id: %v", id))
+	}
+
+	return nil
+}
+
+// RestoreEvent restores a soft deleted bookmark unless the same URL was
+// bookmarked meanwhile
+func (tdb *TDB) RestoreEvent(id int) error {
+	var url string
+	sql_readone := `SELECT Url FROM event WHERE id = ? AND DeletedAt IS NOT NULL`
+	if err := tdb.QueryRow(sql_readone, id).Scan(&url); err != nil {
+		if err == sql.ErrNoRows {
+			return NotFound(fmt.Sprintf("Deleted event not found for id: %v", id))
+		}
+		return err
+	}
+	if _, err := tdb.GetEventByUrl(url); err == nil {
+		return fmt.Errorf("%v is already bookmarked", url)
+	}
+
+	_, err := tdb.Exec(`UPDATE event SET DeletedAt = NULL WHERE id = ?`, id)
+	return err
+}
+
 func (e Event) String() string {
 	return string(e.Title)
 }
 
+// TagList splits the comma separated tags of the event
+func (e Event) TagList() []string {
+	var tags []string
+	for _, t := range strings.Split(e.Tags, ",") {
+		if t = strings.TrimSpace(t); len(t) > 0 {
+			tags = append(tags, t)
+		}
+	}
+	return tags
+}
+
 func (e Event) Host() string {
 	u, err := url.Parse(e.Url)
 	if err != nil {
@@ -0,0 +1,171 @@
+/*
+   Terminews is a terminal based (TUI) RSS feed manager.
+   Copyright (C) 2017  Alexandros Ntavelos, a[dot]ntavelos[at]gmail[dot]com
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+package db
+
+import (
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// maxFetchesPerSite is the number of fetches kept in the history of a site
+const maxFetchesPerSite = 50
+
+// Fetch is an entry of the fetch history of a site
+type Fetch struct {
+	Id        int
+	SiteId    int
+	FetchedAt time.Time
+	// Status is the HTTP status of the response, zero if none was received
+	Status int
+	// Error is empty on success
+	Error   string
+	Latency time.Duration
+	// MovedTo is the new URL of a permanently moved feed
+	MovedTo string
+}
+
+// SiteHealth summarizes the fetch history of a site
+type SiteHealth struct {
+	LastSuccess         time.Time
+	LastFailure         time.Time
+	LastError           string
+	LastStatus          int
+	ConsecutiveFailures int
+	AverageLatency      time.Duration
+	MovedTo             string
+	Fetches             []Fetch
+}
+
+// Failing indicates whether the last fetch of the site failed
+func (h SiteHealth) Failing() bool {
+	return h.ConsecutiveFailures > 0
+}
+
+func GetFetchSql() string {
+	return `
+    CREATE TABLE IF NOT EXISTS fetch(
+        Id INTEGER NOT NULL PRIMARY KEY ASC,
+        SiteId INTEGER NOT NULL,
+        FetchedAt DATETIME,
+        Status INTEGER NOT NULL DEFAULT 0,
+        Error TEXT NOT NULL DEFAULT '',
+        Latency INTEGER NOT NULL DEFAULT 0,
+        MovedTo TEXT NOT NULL DEFAULT ''
+    );`
+}
+
+// AddFetch appends a fetch in the history of its site dropping the oldest
+// ones beyond the history size
+func (tdb *TDB) AddFetch(f Fetch) error {
+	sql_additem := `
+    INSERT INTO fetch(
+        SiteId,
+        FetchedAt,
+        Status,
+        Error,
+        Latency,
+        MovedTo
+    ) values(?, ?, ?, ?, ?, ?)
+    `
+	if _, err := tdb.Exec(sql_additem, f.SiteId, f.FetchedAt, f.Status, f.Error,
+		int64(f.Latency/time.Millisecond), f.MovedTo); err != nil {
+		return err
+	}
+
+	sql_prune := `
+    DELETE FROM fetch WHERE SiteId = ? AND Id NOT IN (
+        SELECT Id FROM fetch WHERE SiteId = ? ORDER BY Id DESC LIMIT ?
+    )`
+	_, err := tdb.Exec(sql_prune, f.SiteId, f.SiteId, maxFetchesPerSite)
+
+	return err
+}
+
+// GetFetches returns the fetch history of a site, most recent first
+func (tdb *TDB) GetFetches(siteId int) ([]Fetch, error) {
+	sql_readall := `
+    SELECT Id, SiteId, FetchedAt, Status, Error, Latency, MovedTo FROM fetch
+    WHERE SiteId = ?
+    ORDER BY Id DESC
+    `
+
+	rows, err := tdb.Query(sql_readall, siteId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []Fetch
+	for rows.Next() {
+		var (
+			f       Fetch
+			latency int64
+		)
+		if err := rows.Scan(&f.Id, &f.SiteId, &f.FetchedAt, &f.Status, &f.Error, &latency, &f.MovedTo); err != nil {
+			return nil, err
+		}
+		f.Latency = time.Duration(latency) * time.Millisecond
+		result = append(result, f)
+	}
+	return result, rows.Err()
+}
+
+// GetSiteHealth summarizes the fetch history of a site
+func (tdb *TDB) GetSiteHealth(siteId int) (SiteHealth, error) {
+	fetches, err := tdb.GetFetches(siteId)
+	if err != nil {
+		return SiteHealth{}, err
+	}
+
+	h := SiteHealth{Fetches: fetches}
+	var total time.Duration
+	failing := true
+	for i, f := range fetches {
+		if i == 0 {
+			h.LastStatus = f.Status
+			h.MovedTo = f.MovedTo
+		}
+		if len(f.Error) == 0 {
+			failing = false
+			if h.LastSuccess.IsZero() {
+				h.LastSuccess = f.FetchedAt
+			}
+		} else {
+			if failing {
+				h.ConsecutiveFailures++
+			}
+			if h.LastFailure.IsZero() {
+				h.LastFailure = f.FetchedAt
+				h.LastError = f.Error
+			}
+		}
+		total += f.Latency
+	}
+	if len(fetches) > 0 {
+		h.AverageLatency = total / time.Duration(len(fetches))
+	}
+
+	return h, nil
+}
+
+// DeleteFetches clears the fetch history of a site
+func (tdb *TDB) DeleteFetches(siteId int) error {
+	_, err := tdb.Exec(`DELETE FROM fetch WHERE SiteId = ?`, siteId)
+	return err
+}
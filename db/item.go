@@ -0,0 +1,456 @@
+/*
+   Terminews is a terminal based (TUI) RSS feed manager.
+   Copyright (C) 2017  Alexandros Ntavelos, a[dot]ntavelos[at]gmail[dot]com
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// RetentionPolicy defines which of the stored news are pruned. Bookmarked
+// news, including the tagged ones, are never pruned.
+type RetentionPolicy struct {
+	// MaxAge is the age after which the news are pruned. Zero keeps them
+	// regardless of their age.
+	MaxAge time.Duration
+	// MaxItemsPerSite is the number of the most recent news kept per site.
+	// Zero keeps all of them.
+	MaxItemsPerSite int
+}
+
+func GetItemSql() string {
+	return `
+    CREATE TABLE IF NOT EXISTS item(
+        Id INTEGER NOT NULL PRIMARY KEY ASC,
+        SiteId INTEGER NOT NULL,
+        Title TEXT,
+        Author TEXT,
+        Url TEXT,
+        Summary TEXT,
+        Published TEXT,
+        FetchedAt DATETIME,
+        Read INTEGER NOT NULL DEFAULT 0,
+        Categories TEXT NOT NULL DEFAULT '',
+        CanonicalUrl TEXT NOT NULL DEFAULT '',
+        UNIQUE(SiteId, Url)
+    );`
+}
+
+// GetItemColumns returns the columns added to the item table after its
+// creation
+func GetItemColumns() []Column {
+	return []Column{
+		{"item", "Read", "INTEGER NOT NULL DEFAULT 0"},
+		{"item", "Categories", "TEXT NOT NULL DEFAULT ''"},
+		{"item", "CanonicalUrl", "TEXT NOT NULL DEFAULT ''"},
+	}
+}
+
+var (
+	// categoryEscaper percent-encodes the commas separating the stored
+	// categories, categoryUnescaper decodes them
+	categoryEscaper   = strings.NewReplacer("%", "%25", ",", "%2C")
+	categoryUnescaper = strings.NewReplacer("%2C", ",", "%25", "%")
+	// likeEscaper escapes the wildcards of a LIKE pattern whose escape
+	// character is a backslash
+	likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+)
+
+// joinCategories returns the categories as stored, joined by commas
+func joinCategories(categories []string) string {
+	escaped := make([]string, len(categories))
+	for i, c := range categories {
+		escaped[i] = categoryEscaper.Replace(c)
+	}
+	return strings.Join(escaped, ",")
+}
+
+// splitCategories returns the stored categories
+func splitCategories(value string) []string {
+	categories := strings.Split(value, ",")
+	for i, c := range categories {
+		categories[i] = categoryUnescaper.Replace(c)
+	}
+	return categories
+}
+
+// SaveItems stores the fetched news of a site which are not already stored
+// and returns them
+func (tdb *TDB) SaveItems(siteId int, events []Event) ([]Event, error) {
+	sql_additem := `
+    INSERT OR IGNORE INTO item(
+        SiteId,
+        Title,
+        Author,
+        Url,
+        Summary,
+        Published,
+        FetchedAt,
+        Categories
+    ) values(?, ?, ?, ?, ?, ?, ?, ?)
+    `
+
+	tx, err := tdb.Begin()
+	if err != nil {
+		return nil, err
+	}
+	stmt, err := tx.Prepare(sql_additem)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	defer stmt.Close()
+
+	var added []Event
+	now := time.Now()
+	for _, e := range events {
+		res, err := stmt.Exec(siteId, e.Title, e.Author, e.Url, e.Summary, e.Published, now,
+			joinCategories(e.Categories))
+		if err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+		if len(e.Enclosures) > 0 {
+			if err := saveEnclosures(tx, e.Url, e.Enclosures); err != nil {
+				tx.Rollback()
+				return nil, err
+			}
+		}
+		if n, _ := res.RowsAffected(); n > 0 {
+			id, _ := res.LastInsertId()
+			e.Id = int(id)
+			added = append(added, e)
+		}
+	}
+
+	return added, tx.Commit()
+}
+
+// GetItems returns the stored news of a site, most recently fetched first
+func (tdb *TDB) GetItems(siteId int) ([]Event, error) {
+	sql_readall := `
+    SELECT Id, SiteId, Title, Author, Url, Summary, Published, Read, Categories FROM item
+    WHERE SiteId = ?
+    ORDER BY datetime(FetchedAt) DESC, Id ASC
+    `
+
+	return tdb.queryItems(sql_readall, siteId)
+}
+
+// queryItems returns the stored news selected by the given query along with
+// their enclosures
+func (tdb *TDB) queryItems(query string, args ...interface{}) ([]Event, error) {
+	rows, err := tdb.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []Event
+	for rows.Next() {
+		var (
+			e          Event
+			published  sql.NullString
+			categories string
+		)
+		if err := rows.Scan(&e.Id, &e.SiteId, &e.Title, &e.Author, &e.Url, &e.Summary, &published, &e.Read, &categories); err != nil {
+			return nil, err
+		}
+		e.Published = published.String
+		if len(categories) > 0 {
+			e.Categories = splitCategories(categories)
+		}
+		result = append(result, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return tdb.AttachEnclosures(result)
+}
+
+// ItemFilter selects the stored news
+type ItemFilter struct {
+	// SiteId selects the news of a site, zero selects all sites
+	SiteId int
+	// Url selects the news with the given URL
+	Url    string
+	Unread bool
+	// Terms have to be contained in the title or the summary
+	Terms []string
+	// Category is a category the news have to be filed under
+	Category string
+	Limit    int
+	Offset   int
+}
+
+// FindItems returns the page of the stored news selected by the filter, most
+// recently fetched first, along with the total number of the selected news
+func (tdb *TDB) FindItems(f ItemFilter) ([]Event, int, error) {
+	var (
+		conds []string
+		args  []interface{}
+	)
+	if f.SiteId != 0 {
+		conds = append(conds, "SiteId = ?")
+		args = append(args, f.SiteId)
+	}
+	if f.SiteId == 0 {
+		conds = append(conds, "SiteId NOT IN (SELECT Id FROM site WHERE DeletedAt IS NOT NULL)")
+	}
+	if len(f.Url) > 0 {
+		conds = append(conds, "Url = ?")
+		args = append(args, f.Url)
+	}
+	if f.Unread {
+		conds = append(conds, "Read = 0")
+	}
+	if len(f.Category) > 0 {
+		conds = append(conds, `',' || lower(Categories) || ',' LIKE ? ESCAPE '\'`)
+		category := strings.ToLower(joinCategories([]string{f.Category}))
+		args = append(args, "%,"+likeEscaper.Replace(category)+",%")
+	}
+	for _, term := range f.Terms {
+		conds = append(conds, "(Title LIKE ? OR Summary LIKE ?)")
+		like := "%" + term + "%"
+		args = append(args, like, like)
+	}
+	where := ""
+	if len(conds) > 0 {
+		where = "WHERE " + strings.Join(conds, " AND ")
+	}
+
+	var total int
+	if err := tdb.QueryRow(`SELECT COUNT(*) FROM item `+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	limit := f.Limit
+	if limit <= 0 {
+		limit = -1
+	}
+	sql_readall := `
+    SELECT Id, SiteId, Title, Author, Url, Summary, Published, Read, Categories FROM item ` + where + `
+    ORDER BY datetime(FetchedAt) DESC, Id ASC
+    LIMIT ? OFFSET ?
+    `
+	events, err := tdb.queryItems(sql_readall, append(args, limit, f.Offset)...)
+	return events, total, err
+}
+
+// GetItemById returns a stored news
+func (tdb *TDB) GetItemById(id int) (Event, error) {
+	sql_readone := `SELECT Id, SiteId, Title, Author, Url, Summary, Published, Read, Categories FROM item WHERE Id = ?`
+
+	events, err := tdb.queryItems(sql_readone, id)
+	if err != nil {
+		return Event{}, err
+	}
+	if len(events) == 0 {
+		return Event{}, NotFound(fmt.Sprintf("Item not found for id: %v", id))
+	}
+	return events[0], nil
+}
+
+// SetItemRead marks a stored news as read or unread
+func (tdb *TDB) SetItemRead(id int, read bool) error {
+	res, err := tdb.Exec(`UPDATE item SET Read = ? WHERE Id = ?`, read, id)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		return NotFound(fmt.Sprintf("Item not found for id: %v", id))
+	}
+	return nil
+}
+
+// CountItems returns the number of the stored news of a site
+func (tdb *TDB) CountItems(siteId int) (int, error) {
+	var n int
+	err := tdb.QueryRow(`SELECT COUNT(*) FROM item WHERE SiteId = ?`, siteId).Scan(&n)
+	return n, err
+}
+
+// GetUnreadCounts returns the number of the unread stored news per site
+func (tdb *TDB) GetUnreadCounts() (map[int]int, error) {
+	rows, err := tdb.Query(`SELECT SiteId, COUNT(*) FROM item WHERE Read = 0 GROUP BY SiteId`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make(map[int]int)
+	for rows.Next() {
+		var siteId, n int
+		if err := rows.Scan(&siteId, &n); err != nil {
+			return nil, err
+		}
+		counts[siteId] = n
+	}
+	return counts, rows.Err()
+}
+
+// GetReadUrls returns the URLs of the read news of a site
+func (tdb *TDB) GetReadUrls(siteId int) (map[string]bool, error) {
+	rows, err := tdb.Query(`SELECT Url FROM item WHERE SiteId = ? AND Read = 1`, siteId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := map[string]bool{}
+	for rows.Next() {
+		var url string
+		if err := rows.Scan(&url); err != nil {
+			return nil, err
+		}
+		result[url] = true
+	}
+	return result, rows.Err()
+}
+
+// MarkItemRead marks the stored news with the given URL as read in all sites
+func (tdb *TDB) MarkItemRead(url string) error {
+	return tdb.SetItemsRead(url, true)
+}
+
+// SetItemsRead marks the stored news with the given URL as read or unread in
+// all sites
+func (tdb *TDB) SetItemsRead(url string, read bool) error {
+	_, err := tdb.Exec(`UPDATE item SET Read = ? WHERE Url = ?`, read, url)
+	return err
+}
+
+// GetItemStates returns whether the stored news are read by their URL. A news
+// stored by several sites is read if it is read in any of them.
+func (tdb *TDB) GetItemStates() (map[string]bool, error) {
+	rows, err := tdb.Query(`SELECT Url, MAX(Read) FROM item GROUP BY Url`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := map[string]bool{}
+	for rows.Next() {
+		var (
+			url  string
+			read bool
+		)
+		if err := rows.Scan(&url, &read); err != nil {
+			return nil, err
+		}
+		result[url] = read
+	}
+	return result, rows.Err()
+}
+
+// SetItemsCanonicalUrl stores the URL advertised by the page of the stored
+// news with the given URL as its canonical one
+func (tdb *TDB) SetItemsCanonicalUrl(url, canonical string) error {
+	_, err := tdb.Exec(`UPDATE item SET CanonicalUrl = ? WHERE Url = ?`, canonical, url)
+	return err
+}
+
+// GetCanonicalUrls returns the canonical URLs of the stored news by their URL
+func (tdb *TDB) GetCanonicalUrls() (map[string]string, error) {
+	rows, err := tdb.Query(`SELECT DISTINCT Url, CanonicalUrl FROM item WHERE CanonicalUrl != ''`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := map[string]string{}
+	for rows.Next() {
+		var url, canonical string
+		if err := rows.Scan(&url, &canonical); err != nil {
+			return nil, err
+		}
+		result[url] = canonical
+	}
+	return result, rows.Err()
+}
+
+// DeleteItems removes the stored news of a site
+func (tdb *TDB) DeleteItems(siteId int) error {
+	_, err := tdb.Exec(`DELETE FROM item WHERE SiteId = ?`, siteId)
+	return err
+}
+
+// PruneItems removes the stored news which are not kept by the retention
+// policy and returns their number. The news of deleted sites are removed as
+// well.
+func (tdb *TDB) PruneItems(policy RetentionPolicy) (int64, error) {
+	// the bookmarks are stored apart, the news which are bookmarked are kept
+	// as long as their bookmark exists
+	const notBookmarked = `Url NOT IN (SELECT Url FROM event WHERE Url IS NOT NULL)`
+
+	var pruned int64
+	prune := func(query string, args ...interface{}) error {
+		res, err := tdb.Exec(query, args...)
+		if err != nil {
+			return err
+		}
+		n, err := res.RowsAffected()
+		pruned += n
+		return err
+	}
+
+	if err := prune(`DELETE FROM item WHERE SiteId NOT IN (SELECT Id FROM site)`); err != nil {
+		return pruned, err
+	}
+
+	if policy.MaxAge > 0 {
+		sql_prune := `DELETE FROM item WHERE datetime(FetchedAt) < datetime(?) AND ` + notBookmarked
+		if err := prune(sql_prune, time.Now().Add(-policy.MaxAge).UTC().Format("2006-01-02 15:04:05")); err != nil {
+			return pruned, err
+		}
+	}
+
+	if policy.MaxItemsPerSite > 0 {
+		sql_prune := `
+        DELETE FROM item WHERE ` + notBookmarked + ` AND Id NOT IN (
+            SELECT i.Id FROM item i WHERE i.SiteId = item.SiteId
+            ORDER BY datetime(i.FetchedAt) DESC, i.Id ASC LIMIT ?
+        )`
+		if err := prune(sql_prune, policy.MaxItemsPerSite); err != nil {
+			return pruned, err
+		}
+	}
+
+	// the enclosures of the pruned news are not counted
+	sql_orphans := `
+    DELETE FROM enclosure
+    WHERE ItemUrl NOT IN (SELECT Url FROM item WHERE Url IS NOT NULL)
+        AND ItemUrl NOT IN (SELECT Url FROM event WHERE Url IS NOT NULL)`
+	if _, err := tdb.Exec(sql_orphans); err != nil {
+		return pruned, err
+	}
+
+	// neither is the synchronized state of the pruned news
+	sql_orphans = `
+    DELETE FROM sync_item
+    WHERE Url NOT IN (SELECT Url FROM item WHERE Url IS NOT NULL)
+        AND Url NOT IN (SELECT Url FROM event WHERE Url IS NOT NULL)`
+	_, err := tdb.Exec(sql_orphans)
+
+	return pruned, err
+}
@@ -0,0 +1,133 @@
+/*
+   Terminews is a terminal based (TUI) RSS feed manager.
+   Copyright (C) 2017  Alexandros Ntavelos, a[dot]ntavelos[at]gmail[dot]com
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+package db
+
+import (
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// SiteStats reports the storage used by a site
+type SiteStats struct {
+	SiteId int
+	Name   string
+	Items  int
+	// Bytes is the size of the text of the stored news
+	Bytes   int64
+	Fetches int
+}
+
+// Stats reports the storage used by the database
+type Stats struct {
+	// Size is the size of the database file
+	Size int64
+	// Free is the size of the unused pages which VACUUM reclaims
+	Free      int64
+	Bookmarks int
+	Sites     []SiteStats
+}
+
+// Vacuum rebuilds the database file reclaiming the unused space and updates
+// the statistics of the query planner
+func (tdb *TDB) Vacuum() error {
+	for _, s := range []string{"VACUUM;", "ANALYZE;"} {
+		if _, err := tdb.Exec(s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetStats reports the size of the database and the storage used per site
+func (tdb *TDB) GetStats() (Stats, error) {
+	var (
+		st                             Stats
+		pageSize, pageCount, freePages int64
+	)
+	for pragma, dest := range map[string]*int64{
+		"page_size":      &pageSize,
+		"page_count":     &pageCount,
+		"freelist_count": &freePages,
+	} {
+		if err := tdb.QueryRow("PRAGMA " + pragma).Scan(dest); err != nil {
+			return st, err
+		}
+	}
+	st.Size = pageSize * pageCount
+	st.Free = pageSize * freePages
+
+	if err := tdb.QueryRow(`SELECT COUNT(*) FROM event WHERE DeletedAt IS NULL`).Scan(&st.Bookmarks); err != nil {
+		return st, err
+	}
+
+	sql_stats := `
+    SELECT s.Id, s.Name,
+        (SELECT COUNT(*) FROM item i WHERE i.SiteId = s.Id),
+        (SELECT IFNULL(SUM(LENGTH(i.Title) + LENGTH(i.Author) + LENGTH(i.Url) +
+            LENGTH(i.Summary) + LENGTH(i.Published)), 0) FROM item i WHERE i.SiteId = s.Id),
+        (SELECT COUNT(*) FROM fetch f WHERE f.SiteId = s.Id)
+    FROM site s
+    WHERE s.DeletedAt IS NULL
+    ORDER BY s.Position ASC, datetime(s.CreatedAt) ASC
+    `
+	rows, err := tdb.Query(sql_stats)
+	if err != nil {
+		return st, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var ss SiteStats
+		if err := rows.Scan(&ss.SiteId, &ss.Name, &ss.Items, &ss.Bytes, &ss.Fetches); err != nil {
+			return st, err
+		}
+		st.Sites = append(st.Sites, ss)
+	}
+
+	return st, rows.Err()
+}
+
+// PurgeDeleted removes for good the sites and the bookmarks which were soft
+// deleted before the given time and returns their number. The fetch history
+// and the stored news of the purged sites are removed as well.
+func (tdb *TDB) PurgeDeleted(before time.Time) (int64, error) {
+	const deletedSites = `SELECT Id FROM site WHERE datetime(DeletedAt) <= datetime(?)`
+	t := before.UTC().Format("2006-01-02 15:04:05")
+
+	for _, table := range []string{"fetch", "item"} {
+		if _, err := tdb.Exec(`DELETE FROM `+table+` WHERE SiteId IN (`+deletedSites+`)`, t); err != nil {
+			return 0, err
+		}
+	}
+
+	var purged int64
+	for _, table := range []string{"site", "event"} {
+		res, err := tdb.Exec(`DELETE FROM `+table+` WHERE datetime(DeletedAt) <= datetime(?)`, t)
+		if err != nil {
+			return purged, err
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return purged, err
+		}
+		purged += n
+	}
+
+	return purged, nil
+}
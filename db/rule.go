@@ -0,0 +1,113 @@
+/*
+   Terminews is a terminal based (TUI) RSS feed manager.
+   Copyright (C) 2017  Alexandros Ntavelos, a[dot]ntavelos[at]gmail[dot]com
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+package db
+
+import (
+	"database/sql"
+	"fmt"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// Rule is a user defined filter applied on the fetched news
+type Rule struct {
+	Id int
+	// Action is what happens to the matching news: hide, dim, read or
+	// bookmark
+	Action string
+	// Field is what the pattern is matched against: keyword, regex, author,
+	// site or category
+	Field   string
+	Pattern string
+}
+
+func GetRuleSql() string {
+	return `
+    CREATE TABLE IF NOT EXISTS rule(
+        Id INTEGER NOT NULL PRIMARY KEY ASC,
+        Action TEXT NOT NULL,
+        Field TEXT NOT NULL,
+        Pattern TEXT NOT NULL
+    );`
+}
+
+func (tdb *TDB) GetRules() ([]Rule, error) {
+	sql_readall := `SELECT Id, Action, Field, Pattern FROM rule ORDER BY Id ASC`
+
+	rows, err := tdb.Query(sql_readall)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []Rule
+	for rows.Next() {
+		r := Rule{}
+		if err := rows.Scan(&r.Id, &r.Action, &r.Field, &r.Pattern); err != nil {
+			return nil, err
+		}
+		result = append(result, r)
+	}
+	return result, rows.Err()
+}
+
+func (tdb *TDB) GetRuleById(id int) (Rule, error) {
+	sql_readone := `SELECT Id, Action, Field, Pattern FROM rule WHERE Id = ?`
+
+	var r Rule
+	if err := tdb.QueryRow(sql_readone, id).Scan(&r.Id, &r.Action, &r.Field, &r.Pattern); err != nil {
+		if err == sql.ErrNoRows {
+			return Rule{}, NotFound(fmt.Sprintf("Rule not found for id: %v", id))
+		}
+		return Rule{}, err
+	}
+	return r, nil
+}
+
+func (tdb *TDB) AddRule(r Rule) error {
+	sql_additem := `INSERT INTO rule(Action, Field, Pattern) values(?, ?, ?)`
+
+	_, err := tdb.Exec(sql_additem, r.Action, r.Field, r.Pattern)
+	return err
+}
+
+func (tdb *TDB) UpdateRule(r Rule) error {
+	sql_update := `UPDATE rule SET Action = ?, Field = ?, Pattern = ? WHERE Id = ?`
+
+	res, err := tdb.Exec(sql_update, r.Action, r.Field, r.Pattern, r.Id)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		return NotFound(fmt.Sprintf("Rule not found for id: %v", r.Id))
+	}
+	return nil
+}
+
+func (tdb *TDB) DeleteRule(id int) error {
+	if _, err := tdb.GetRuleById(id); err != nil {
+		return err
+	}
+
+	_, err := tdb.Exec(`DELETE FROM rule WHERE Id = ?`, id)
+	return err
+}
+
+func (r Rule) String() string {
+	return fmt.Sprintf("%v %v %v", r.Action, r.Field, r.Pattern)
+}
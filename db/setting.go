@@ -0,0 +1,72 @@
+/*
+   Terminews is a terminal based (TUI) RSS feed manager.
+   Copyright (C) 2017  Alexandros Ntavelos, a[dot]ntavelos[at]gmail[dot]com
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+package db
+
+import (
+	"database/sql"
+	"fmt"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func GetSettingSql() string {
+	return `
+    CREATE TABLE IF NOT EXISTS setting(
+        Name TEXT NOT NULL PRIMARY KEY,
+        Value TEXT NOT NULL DEFAULT ''
+    );`
+}
+
+// GetSettings returns all the stored settings by name
+func (tdb *TDB) GetSettings() (map[string]string, error) {
+	rows, err := tdb.Query(`SELECT Name, Value FROM setting`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := map[string]string{}
+	for rows.Next() {
+		var name, value string
+		if err := rows.Scan(&name, &value); err != nil {
+			return nil, err
+		}
+		result[name] = value
+	}
+	return result, rows.Err()
+}
+
+func (tdb *TDB) GetSetting(name string) (string, error) {
+	var value string
+	err := tdb.QueryRow(`SELECT Value FROM setting WHERE Name = ?`, name).Scan(&value)
+	if err == sql.ErrNoRows {
+		return "", NotFound(fmt.Sprintf("Setting not found: %v", name))
+	}
+	return value, err
+}
+
+func (tdb *TDB) SetSetting(name, value string) error {
+	_, err := tdb.Exec(`INSERT OR REPLACE INTO setting(Name, Value) values(?, ?)`, name, value)
+	return err
+}
+
+// DeleteSetting removes a setting so that its default value applies again
+func (tdb *TDB) DeleteSetting(name string) error {
+	_, err := tdb.Exec(`DELETE FROM setting WHERE Name = ?`, name)
+	return err
+}
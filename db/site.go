@@ -27,6 +27,30 @@ type Site struct {
 	Id   int
 	Name string
 	Url  string
+	// RefreshInterval is the interval in minutes after which the news of the
+	// site are fetched again while displayed. Zero disables refreshing.
+	RefreshInterval int
+	// MaxItems is the maximum number of news kept per fetch. Zero keeps all.
+	MaxItems int
+	// Extractor is the name of the extractor of the articles' content
+	Extractor string
+	// UserAgent overrides the default User-Agent header of the requests
+	UserAgent string
+	// Headers holds extra request headers, one "Name: Value" per line
+	Headers string
+	// OpenInBrowser opens the articles in the browser instead of extracting
+	// their content
+	OpenInBrowser bool
+	// Proxy overrides the proxy of the requests, "direct" for none
+	Proxy string
+	// Auth is the authentication of the requests: basic, bearer, cookie or
+	// empty for none
+	Auth string
+	// AuthUser is the user of the basic authentication
+	AuthUser string
+	// AuthSecret is the password, the token or the cookies of the
+	// authentication, or a reference to them, e.g. env:NAME or cmd:COMMAND
+	AuthSecret string
 }
 
 func GetSiteSql() string {
@@ -35,26 +59,73 @@ func GetSiteSql() string {
         Id INTEGER NOT NULL PRIMARY KEY ASC,
         Name TEXT,
         Url TEXT,
-        CreatedAt DATETIME
+        CreatedAt DATETIME,
+        RefreshInterval INTEGER NOT NULL DEFAULT 0,
+        MaxItems INTEGER NOT NULL DEFAULT 0,
+        Extractor TEXT NOT NULL DEFAULT '',
+        UserAgent TEXT NOT NULL DEFAULT '',
+        Headers TEXT NOT NULL DEFAULT '',
+        OpenInBrowser INTEGER NOT NULL DEFAULT 0,
+        DeletedAt DATETIME,
+        Position INTEGER NOT NULL DEFAULT 0,
+        Proxy TEXT NOT NULL DEFAULT '',
+        Auth TEXT NOT NULL DEFAULT '',
+        AuthUser TEXT NOT NULL DEFAULT '',
+        AuthSecret TEXT NOT NULL DEFAULT ''
     );`
 }
 
+// GetSiteColumns returns the columns added to the site table after its
+// creation
+func GetSiteColumns() []Column {
+	return []Column{
+		{"site", "RefreshInterval", "INTEGER NOT NULL DEFAULT 0"},
+		{"site", "MaxItems", "INTEGER NOT NULL DEFAULT 0"},
+		{"site", "Extractor", "TEXT NOT NULL DEFAULT ''"},
+		{"site", "UserAgent", "TEXT NOT NULL DEFAULT ''"},
+		{"site", "Headers", "TEXT NOT NULL DEFAULT ''"},
+		{"site", "OpenInBrowser", "INTEGER NOT NULL DEFAULT 0"},
+		{"site", "DeletedAt", "DATETIME"},
+		{"site", "Position", "INTEGER NOT NULL DEFAULT 0"},
+		{"site", "Proxy", "TEXT NOT NULL DEFAULT ''"},
+		{"site", "Auth", "TEXT NOT NULL DEFAULT ''"},
+		{"site", "AuthUser", "TEXT NOT NULL DEFAULT ''"},
+		{"site", "AuthSecret", "TEXT NOT NULL DEFAULT ''"},
+	}
+}
+
+const siteFields = `Id, Name, Url, RefreshInterval, MaxItems, Extractor, UserAgent, Headers, OpenInBrowser, Proxy, Auth, AuthUser, AuthSecret`
+
+// scanner is implemented by both sql.Row and sql.Rows
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanSite(row scanner) (Site, error) {
+	var rr Site
+	err := row.Scan(&rr.Id, &rr.Name, &rr.Url, &rr.RefreshInterval, &rr.MaxItems,
+		&rr.Extractor, &rr.UserAgent, &rr.Headers, &rr.OpenInBrowser, &rr.Proxy,
+		&rr.Auth, &rr.AuthUser, &rr.AuthSecret)
+	return rr, err
+}
+
 func (tdb *TDB) GetSites() ([]Site, error) {
 	sql_readall := `
-    SELECT Id, Name, Url FROM site
-    ORDER BY datetime(CreatedAt) ASC
+    SELECT ` + siteFields + ` FROM site
+    WHERE DeletedAt IS NULL
+    ORDER BY Position ASC, datetime(CreatedAt) ASC
     `
 
 	rows, err := tdb.Query(sql_readall)
-	defer rows.Close()
 	if err != nil {
 		return nil, err
 	}
+	defer rows.Close()
 
 	var records []Site
 	for rows.Next() {
-		rr := Site{}
-		if err := rows.Scan(&rr.Id, &rr.Name, &rr.Url); err != nil {
+		rr, err := scanSite(rows)
+		if err != nil {
 			return nil, err
 		}
 		records = append(records, rr)
@@ -63,16 +134,16 @@ func (tdb *TDB) GetSites() ([]Site, error) {
 }
 
 func (tdb *TDB) GetSiteById(id int) (Site, error) {
-	sql_readone := `SELECT Id, Name, Url FROM site WHERE id = ?`
+	sql_readone := `SELECT ` + siteFields + ` FROM site WHERE id = ? AND DeletedAt IS NULL`
 
 	stmt, err := tdb.Prepare(sql_readone)
-	defer stmt.Close()
 	if err != nil {
 		return Site{}, err
 	}
+	defer stmt.Close()
 
-	var rr Site
-	if err = stmt.QueryRow(id).Scan(&rr.Id, &rr.Name, &rr.Url); err != nil {
+	rr, err := scanSite(stmt.QueryRow(id))
+	if err != nil {
 		if err == sql.ErrNoRows {
 			return Site{}, NotFound(fmt.Sprintf("Site not found for id: %v", id))
 		}
@@ -83,16 +154,16 @@ func (tdb *TDB) GetSiteById(id int) (Site, error) {
 }
 
 func (tdb *TDB) GetSiteByUrl(url string) (Site, error) {
-	sql_readone := `SELECT Id, Name, Url FROM site WHERE Url = ?`
+	sql_readone := `SELECT ` + siteFields + ` FROM site WHERE Url = ? AND DeletedAt IS NULL`
 
 	stmt, err := tdb.Prepare(sql_readone)
-	defer stmt.Close()
 	if err != nil {
 		return Site{}, err
 	}
+	defer stmt.Close()
 
-	var rr Site
-	if err = stmt.QueryRow(url).Scan(&rr.Id, &rr.Name, &rr.Url); err != nil {
+	rr, err := scanSite(stmt.QueryRow(url))
+	if err != nil {
 		if err == sql.ErrNoRows {
 			return Site{}, NotFound(fmt.Sprintf("Site not found for url: %v", url))
 		}
@@ -107,19 +178,71 @@ func (tdb *TDB) AddSite(rr Site) error {
     INSERT OR REPLACE INTO site(
         Name,
         Url,
-        CreatedAt
-    ) values(?, ?, CURRENT_TIMESTAMP)
+        CreatedAt,
+        RefreshInterval,
+        MaxItems,
+        Extractor,
+        UserAgent,
+        Headers,
+        OpenInBrowser,
+        Proxy,
+        Auth,
+        AuthUser,
+        AuthSecret,
+        Position
+    ) values(?, ?, CURRENT_TIMESTAMP, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?,
+        (SELECT IFNULL(MAX(Position), 0) + 1 FROM site))
     `
 
 	stmt, err := tdb.Prepare(sql_additem)
+	if err != nil {
+		return err
+	}
 	defer stmt.Close()
+
+	if _, err = stmt.Exec(rr.Name, rr.Url, rr.RefreshInterval, rr.MaxItems,
+		rr.Extractor, rr.UserAgent, rr.Headers, rr.OpenInBrowser, rr.Proxy,
+		rr.Auth, rr.AuthUser, rr.AuthSecret); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// UpdateSite stores the name, the URL and the settings of an existing site
+func (tdb *TDB) UpdateSite(rr Site) error {
+	sql_update := `
+    UPDATE site SET
+        Name = ?,
+        Url = ?,
+        RefreshInterval = ?,
+        MaxItems = ?,
+        Extractor = ?,
+        UserAgent = ?,
+        Headers = ?,
+        OpenInBrowser = ?,
+        Proxy = ?,
+        Auth = ?,
+        AuthUser = ?,
+        AuthSecret = ?
+    WHERE id = ?
+    `
+
+	stmt, err := tdb.Prepare(sql_update)
 	if err != nil {
 		return err
 	}
+	defer stmt.Close()
 
-	if _, err = stmt.Exec(rr.Name, rr.Url); err != nil {
+	res, err := stmt.Exec(rr.Name, rr.Url, rr.RefreshInterval, rr.MaxItems,
+		rr.Extractor, rr.UserAgent, rr.Headers, rr.OpenInBrowser, rr.Proxy,
+		rr.Auth, rr.AuthUser, rr.AuthSecret, rr.Id)
+	if err != nil {
 		return err
 	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return NotFound(fmt.Sprintf("Site not found for id: %v", rr.Id))
+	}
 
 	return nil
 }
@@ -141,9 +264,71 @@ func (tdb *TDB) DeleteSite(id int) error {
 		return err
 	}
 
+	if err = tdb.DeleteFetches(id); err != nil {
+		return err
+	}
+
+	return tdb.DeleteItems(id)
+}
+
+// SetSitePositions stores the order of the sites as given by their ids
+func (tdb *TDB) SetSitePositions(ids []int) error {
+	tx, err := tdb.Begin()
+	if err != nil {
+		return err
+	}
+	stmt, err := tx.Prepare(`UPDATE site SET Position = ? WHERE id = ?`)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	defer stmt.Close()
+
+	for i, id := range ids {
+		if _, err = stmt.Exec(i+1, id); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// SoftDeleteSite marks a site as deleted so that it is not listed any more but
+// can be restored until it is purged
+func (tdb *TDB) SoftDeleteSite(id int) error {
+	sql_delete := `UPDATE site SET DeletedAt = CURRENT_TIMESTAMP WHERE id = ? AND DeletedAt IS NULL`
+
+	res, err := tdb.Exec(sql_delete, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return NotFound(fmt.Sprintf("Site not found for id: %v", id))
+	}
+
 	return nil
 }
 
+// RestoreSite restores a soft deleted site unless a site with the same URL was
+// added meanwhile
+func (tdb *TDB) RestoreSite(id int) error {
+	var url string
+	sql_readone := `SELECT Url FROM site WHERE id = ? AND DeletedAt IS NOT NULL`
+	if err := tdb.QueryRow(sql_readone, id).Scan(&url); err != nil {
+		if err == sql.ErrNoRows {
+			return NotFound(fmt.Sprintf("Deleted site not found for id: %v", id))
+		}
+		return err
+	}
+	if _, err := tdb.GetSiteByUrl(url); err == nil {
+		return fmt.Errorf("A site with the URL %v already exists", url)
+	}
+
+	_, err := tdb.Exec(`UPDATE site SET DeletedAt = NULL WHERE id = ?`, id)
+	return err
+}
+
 func (rr Site) String() string {
 	return rr.Name
 }
@@ -0,0 +1,82 @@
+/*
+   Terminews is a terminal based (TUI) RSS feed manager.
+   Copyright (C) 2017  Alexandros Ntavelos, a[dot]ntavelos[at]gmail[dot]com
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+package db
+
+import (
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// SyncItem is the state of a news as of the last synchronization with a sync
+// server. It is the base against which the local and the remote changes are
+// detected.
+type SyncItem struct {
+	// RemoteId is the id of the news in the sync server
+	RemoteId string
+	Url      string
+	Read     bool
+	Starred  bool
+}
+
+func GetSyncItemSql() string {
+	return `
+    CREATE TABLE IF NOT EXISTS sync_item(
+        RemoteId TEXT NOT NULL PRIMARY KEY,
+        Url TEXT NOT NULL,
+        Read INTEGER NOT NULL DEFAULT 0,
+        Starred INTEGER NOT NULL DEFAULT 0
+    );`
+}
+
+// GetSyncItems returns the synchronized news by their remote id
+func (tdb *TDB) GetSyncItems() (map[string]SyncItem, error) {
+	rows, err := tdb.Query(`SELECT RemoteId, Url, Read, Starred FROM sync_item`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := map[string]SyncItem{}
+	for rows.Next() {
+		var si SyncItem
+		if err := rows.Scan(&si.RemoteId, &si.Url, &si.Read, &si.Starred); err != nil {
+			return nil, err
+		}
+		result[si.RemoteId] = si
+	}
+	return result, rows.Err()
+}
+
+// SaveSyncItem stores the synchronized state of a news
+func (tdb *TDB) SaveSyncItem(si SyncItem) error {
+	sql_upsert := `
+    INSERT OR REPLACE INTO sync_item(
+        RemoteId,
+        Url,
+        Read,
+        Starred
+    ) values(?, ?, ?, ?)
+    `
+	_, err := tdb.Exec(sql_upsert, si.RemoteId, si.Url, si.Read, si.Starred)
+	return err
+}
+
+// DeleteSyncItems forgets the synchronized state of all news
+func (tdb *TDB) DeleteSyncItems() error {
+	_, err := tdb.Exec(`DELETE FROM sync_item`)
+	return err
+}
@@ -0,0 +1,257 @@
+/*
+   Terminews is a terminal based (TUI) RSS feed manager.
+   Copyright (C) 2017  Alexandros Ntavelos, a[dot]ntavelos[at]gmail[dot]com
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/antavelos/terminews/db"
+	"golang.org/x/net/html"
+)
+
+// trackingParams are the query parameters added by newsletters, social media
+// and analytics which do not identify the article
+var trackingParams = map[string]bool{
+	"fbclid":  true,
+	"gclid":   true,
+	"dclid":   true,
+	"msclkid": true,
+	"mc_cid":  true,
+	"mc_eid":  true,
+	"igshid":  true,
+	"yclid":   true,
+	"ref_src": true,
+	"cmpid":   true,
+	"ncid":    true,
+	"_ga":     true,
+}
+
+// minSimilarTitleWords is the number of words from which titles are compared
+// by similarity, shorter titles have to be identical
+const minSimilarTitleWords = 4
+
+// similarTitleRatio is the ratio of common words above which two titles are
+// considered the same
+const similarTitleRatio = 0.8
+
+// canonicalLinks maps the URLs of the fetched articles to the URL advertised
+// by their <link rel="canonical"> element. They are stored along with the
+// news, and loaded at startup.
+var canonicalLinks = struct {
+	sync.RWMutex
+	urls map[string]string
+}{urls: map[string]string{}}
+
+// CanonicalUrl normalizes a URL so that the different URLs of the same article
+// are equal: the scheme is ignored, the host is lower cased without "www.",
+// the tracking parameters, the fragment and the trailing slash are dropped
+// and the query parameters are sorted. The canonical link of the article is
+// preferred when known.
+func CanonicalUrl(raw string) string {
+	raw = strings.TrimSpace(raw)
+	canonicalLinks.RLock()
+	if link, ok := canonicalLinks.urls[raw]; ok {
+		raw = link
+	}
+	canonicalLinks.RUnlock()
+
+	u, err := url.Parse(raw)
+	if err != nil || len(u.Host) == 0 {
+		return raw
+	}
+
+	host := strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+	if port := u.Port(); len(port) > 0 && port != "80" && port != "443" {
+		host += ":" + port
+	}
+
+	query := u.Query()
+	for name := range query {
+		if trackingParams[strings.ToLower(name)] || strings.HasPrefix(strings.ToLower(name), "utm_") {
+			query.Del(name)
+		}
+	}
+
+	path := strings.TrimRight(u.EscapedPath(), "/")
+	canonical := "//" + host + path
+	if len(query) > 0 {
+		// Encode sorts the parameters by name
+		canonical += "?" + query.Encode()
+	}
+	return canonical
+}
+
+// rememberCanonicalLink records the canonical link of a fetched article, if
+// its page advertises one
+func rememberCanonicalLink(pageUrl string, body []byte) {
+	base, err := url.Parse(pageUrl)
+	if err != nil {
+		return
+	}
+
+	z := html.NewTokenizer(bytes.NewReader(body))
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			return
+		case html.StartTagToken, html.SelfClosingTagToken:
+			t := z.Token()
+			if t.Data == "body" {
+				return
+			}
+			if t.Data != "link" || !strings.EqualFold(strings.TrimSpace(attr(t, "rel")), "canonical") {
+				continue
+			}
+			link, err := base.Parse(strings.TrimSpace(attr(t, "href")))
+			if err != nil || len(link.Host) == 0 {
+				return
+			}
+			canonicalLinks.Lock()
+			canonicalLinks.urls[pageUrl] = link.String()
+			canonicalLinks.Unlock()
+			if App.tdb != nil {
+				if err := App.tdb.SetItemsCanonicalUrl(pageUrl, link.String()); err != nil {
+					log.Println("Error on SetItemsCanonicalUrl", err)
+				}
+			}
+			return
+		}
+	}
+}
+
+// loadCanonicalLinks loads the canonical links of the stored news
+func loadCanonicalLinks() error {
+	urls, err := App.tdb.GetCanonicalUrls()
+	if err != nil {
+		return err
+	}
+	canonicalLinks.Lock()
+	defer canonicalLinks.Unlock()
+	for page, link := range urls {
+		canonicalLinks.urls[page] = link
+	}
+	return nil
+}
+
+// titleWords returns the lower cased words of a title without punctuation
+func titleWords(title string) []string {
+	return strings.FieldsFunc(strings.ToLower(title), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
+
+// similarTitles checks whether two titles most probably refer to the same
+// story, e.g. when a site appends its name to the title
+func similarTitles(a, b string) bool {
+	wa, wb := titleWords(a), titleWords(b)
+	if len(wa) == 0 || len(wb) == 0 {
+		return false
+	}
+	if strings.Join(wa, " ") == strings.Join(wb, " ") {
+		return true
+	}
+	if len(wa) < minSimilarTitleWords || len(wb) < minSimilarTitleWords {
+		return false
+	}
+
+	set := map[string]bool{}
+	for _, w := range wa {
+		set[w] = true
+	}
+	union := len(set)
+	common := 0
+	seen := map[string]bool{}
+	for _, w := range wb {
+		if seen[w] {
+			continue
+		}
+		seen[w] = true
+		if set[w] {
+			common++
+		} else {
+			union++
+		}
+	}
+	return float64(common)/float64(union) >= similarTitleRatio
+}
+
+// isDuplicate checks whether two events are the same article
+func isDuplicate(a, b db.Event) bool {
+	return CanonicalUrl(a.Url) == CanonicalUrl(b.Url) || similarTitles(a.Title, b.Title)
+}
+
+// mergeDuplicate adds an event to the given ones unless it duplicates one of
+// them, in which case its sources are merged into the existing event. The
+// index of the merged event is returned, -1 if the event was appended.
+func mergeDuplicate(events []db.Event, e db.Event) ([]db.Event, int) {
+	for i, existing := range events {
+		if !isDuplicate(existing, e) {
+			continue
+		}
+		for _, s := range e.Sources {
+			if !containsString(existing.Sources, s) {
+				existing.Sources = append(existing.Sources, s)
+			}
+		}
+		events[i] = existing
+		return events, i
+	}
+	return append(events, e), -1
+}
+
+// collapseDuplicates merges the duplicate events keeping the first occurrence
+func collapseDuplicates(events []db.Event) []db.Event {
+	var result []db.Event
+	for _, e := range events {
+		result, _ = mergeDuplicate(result, e)
+	}
+	return result
+}
+
+// formatEvent is the format function of the news list which marks the
+// bookmarked events, indicates the number of sites publishing an event and
+// dims the read and dimmed events
+func formatEvent(item interface{}) string {
+	e := item.(db.Event)
+	title := e.Title
+	if len(e.Sources) > 1 {
+		title = fmt.Sprintf("%v (%v sources)", e.Title, len(e.Sources))
+	}
+	if _, ok := eventInBookmarks(e); ok {
+		title = bookmarkMarker + title
+	}
+	if e.Read || e.Dimmed {
+		return App.Dim.Sprint(title)
+	}
+	return title
+}
+
+func containsString(list []string, s string) bool {
+	for _, l := range list {
+		if l == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/antavelos/terminews/db"
+)
+
+func TestCanonicalUrl(t *testing.T) {
+	for raw, want := range map[string]string{
+		"https://example.org/news/1":                                   "//example.org/news/1",
+		"http://www.Example.ORG/news/1/":                               "//example.org/news/1",
+		"https://example.org/news/1#comments":                          "//example.org/news/1",
+		"https://example.org:443/news/1":                               "//example.org/news/1",
+		"http://example.org:80/news/1":                                 "//example.org/news/1",
+		"https://example.org:8443/news/1":                              "//example.org:8443/news/1",
+		"https://example.org/news?utm_source=rss&utm_medium=feed&id=1": "//example.org/news?id=1",
+		"https://example.org/news?UTM_Campaign=x&fbclid=abc":           "//example.org/news",
+		"https://example.org/compare?ref=main":                         "//example.org/compare?ref=main",
+		"https://example.org/news?page=2&id=1":                         "//example.org/news?id=1&page=2",
+		"https://example.org/news/caf%C3%A9":                           "//example.org/news/caf%C3%A9",
+		"  https://example.org/news/1  ":                               "//example.org/news/1",
+		"/news/1":                                                      "/news/1",
+		"not a url":                                                    "not a url",
+		"":                                                             "",
+	} {
+		if got := CanonicalUrl(raw); got != want {
+			t.Errorf("CanonicalUrl(%q) = %q, want %q", raw, got, want)
+		}
+	}
+}
+
+func TestRememberCanonicalLink(t *testing.T) {
+	const page = "https://m.example.org/news/1?amp=1"
+	defer func() {
+		canonicalLinks.Lock()
+		delete(canonicalLinks.urls, page)
+		canonicalLinks.Unlock()
+	}()
+
+	for _, test := range []struct {
+		body, want string
+	}{
+		{`<html><head><title>News</title></head><body></body></html>`, "//m.example.org/news/1?amp=1"},
+		{`<html><head><link rel="stylesheet" href="/style.css"></head></html>`, "//m.example.org/news/1?amp=1"},
+		{`<html><body><link rel="canonical" href="https://example.org/other"></body></html>`, "//m.example.org/news/1?amp=1"},
+		{`<html><head><link rel=" Canonical " href="/news/1/"/></head></html>`, "//m.example.org/news/1"},
+		{`<html><head><link rel="canonical" href="https://www.example.org/news/1"></head></html>`, "//example.org/news/1"},
+	} {
+		rememberCanonicalLink(page, []byte(test.body))
+		if got := CanonicalUrl(page); got != test.want {
+			t.Errorf("CanonicalUrl after %v = %q, want %q", test.body, got, test.want)
+		}
+	}
+}
+
+func TestStoredCanonicalLinks(t *testing.T) {
+	newTestDB(t)
+	const page = "https://m.example.org/news/2"
+	defer func() {
+		canonicalLinks.Lock()
+		delete(canonicalLinks.urls, page)
+		canonicalLinks.Unlock()
+	}()
+
+	App.tdb.AddSite(db.Site{Name: "Example", Url: "https://example.org/feed"})
+	site, _ := App.tdb.GetSiteByUrl("https://example.org/feed")
+	App.tdb.SaveItems(site.Id, []db.Event{{Title: "News", Url: page}})
+	rememberCanonicalLink(page, []byte(`<html><head><link rel="canonical" href="https://example.org/news/2"></head></html>`))
+
+	// as after a restart
+	canonicalLinks.Lock()
+	delete(canonicalLinks.urls, page)
+	canonicalLinks.Unlock()
+	if err := loadCanonicalLinks(); err != nil {
+		t.Fatal(err)
+	}
+	if got := CanonicalUrl(page); got != "//example.org/news/2" {
+		t.Errorf("CanonicalUrl after loading the stored links = %q, want %q", got, "//example.org/news/2")
+	}
+}
+
+func TestSimilarTitles(t *testing.T) {
+	for _, test := range []struct {
+		a, b string
+		want bool
+	}{
+		// the short titles have to be identical but for the case and the
+		// punctuation
+		{"Go 1.16", "go 1-16!", true},
+		{"Go is fast", "Go is fast now", false},
+		{"Go is fast", "Go is slow", false},
+		{"", "", false},
+		{"Go", "", false},
+		// the longer ones have to share at least 80% of their words
+		{"Go 1.16 is released", "Go 1.16 is released today", true},
+		{"one two three four", "one two three four five", true},
+		{"one two three four", "four three two one", true},
+		{"one two three four", "one two three five", false},
+		{"Go 1.16 is released", "Go 1.16 is released | Example News", false},
+		{"Rust 1.50 is released", "Go 1.16 is released", false},
+		{"the the the the", "the", false},
+	} {
+		if got := similarTitles(test.a, test.b); got != test.want {
+			t.Errorf("similarTitles(%q, %q) = %v, want %v", test.a, test.b, got, test.want)
+		}
+		if got := similarTitles(test.b, test.a); got != test.want {
+			t.Errorf("similarTitles(%q, %q) = %v, want %v", test.b, test.a, got, test.want)
+		}
+	}
+}
+
+func TestMergeDuplicate(t *testing.T) {
+	events := []db.Event{
+		{Title: "Go 1.16 is released", Url: "https://blog.golang.org/go1.16", Sources: []string{"Go blog"}},
+		{Title: "Rust 1.50", Url: "https://blog.rust-lang.org/1.50", Sources: []string{"Rust blog"}},
+	}
+
+	tests := []struct {
+		e       db.Event
+		idx     int
+		sources []string
+	}{
+		// same URL
+		{db.Event{Title: "Go 1.16", Url: "http://www.blog.golang.org/go1.16/?utm_source=hn", Sources: []string{"HN"}}, 0, []string{"Go blog", "HN"}},
+		// same title, the source is not repeated
+		{db.Event{Title: "Go 1.16 is released!", Url: "https://lobste.rs/s/go116", Sources: []string{"HN"}}, 0, []string{"Go blog", "HN"}},
+		{db.Event{Title: "Rust 1.50", Url: "https://reddit.com/r/rust/1", Sources: []string{"Reddit"}}, 1, []string{"Rust blog", "Reddit"}},
+		// different stories
+		{db.Event{Title: "Zig 0.7", Url: "https://ziglang.org/0.7", Sources: []string{"Zig"}}, -1, []string{"Zig"}},
+	}
+	for _, test := range tests {
+		var idx int
+		events, idx = mergeDuplicate(events, test.e)
+		if idx != test.idx {
+			t.Errorf("%v: merged into %v, want %v", test.e.Title, idx, test.idx)
+			continue
+		}
+		if idx < 0 {
+			idx = len(events) - 1
+		}
+		if !reflect.DeepEqual(events[idx].Sources, test.sources) {
+			t.Errorf("%v: got sources %v, want %v", test.e.Title, events[idx].Sources, test.sources)
+		}
+	}
+	if len(events) != 3 || events[0].Url != "https://blog.golang.org/go1.16" {
+		t.Errorf("Got events %+v, want the first occurrences kept", events)
+	}
+}
+
+func TestCollapseDuplicates(t *testing.T) {
+	events := collapseDuplicates([]db.Event{
+		{Title: "Go 1.16 is released", Url: "https://blog.golang.org/go1.16", Sources: []string{"Go blog"}},
+		{Title: "Rust 1.50", Url: "https://blog.rust-lang.org/1.50", Sources: []string{"Rust blog"}},
+		{Title: "Go 1.16", Url: "https://blog.golang.org/go1.16#top", Sources: []string{"HN"}},
+		{Title: "Rust 1.50", Url: "https://reddit.com/r/rust/1", Sources: []string{"Reddit"}},
+	})
+
+	var got [][]string
+	for _, e := range events {
+		got = append(got, append([]string{e.Title}, e.Sources...))
+	}
+	want := [][]string{
+		{"Go 1.16 is released", "Go blog", "HN"},
+		{"Rust 1.50", "Rust blog", "Reddit"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Got %v, want %v", got, want)
+	}
+	if collapseDuplicates(nil) != nil {
+		t.Errorf("Got events from none")
+	}
+}
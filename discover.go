@@ -0,0 +1,247 @@
+/*
+   Terminews is a terminal based (TUI) RSS feed manager.
+   Copyright (C) 2017  Alexandros Ntavelos, a[dot]ntavelos[at]gmail[dot]com
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/antavelos/terminews/db"
+	"github.com/mmcdole/gofeed"
+	"golang.org/x/net/html"
+)
+
+// feedLinkTypes are the MIME types of the feeds advertised by the
+// <link rel="alternate"> elements of a page
+var feedLinkTypes = []string{
+	"application/rss+xml",
+	"application/atom+xml",
+	"application/feed+json",
+	"application/json",
+}
+
+// commonFeedPaths are tried when a page does not advertise any feed
+var commonFeedPaths = []string{
+	"/feed",
+	"/rss.xml",
+	"/atom.xml",
+	"/feed.xml",
+	"/rss",
+	"/index.xml",
+	"/feed.json",
+}
+
+// probeTimeout is the time the candidate feeds of a website are given to be
+// fetched and parsed, all at once
+var probeTimeout = 10 * time.Second
+
+// DiscoveredFeed is a feed found at a website
+type DiscoveredFeed struct {
+	Url   string
+	Title string
+}
+
+func (f DiscoveredFeed) String() string {
+	if len(f.Title) == 0 {
+		return f.Url
+	}
+	return fmt.Sprintf("%v - %v", f.Title, f.Url)
+}
+
+// DiscoverFeeds finds the feeds of the given URL. A feed URL is returned as is,
+// otherwise the feeds advertised by the page are returned or, failing that, the
+// ones found at the common feed paths of the website.
+func DiscoverFeeds(pageUrl string) ([]DiscoveredFeed, error) {
+	base, err := url.Parse(pageUrl)
+	if err != nil {
+		return nil, err
+	}
+	if len(base.Scheme) == 0 {
+		// most people type the domain only
+		pageUrl = "http://" + pageUrl
+		if base, err = url.Parse(pageUrl); err != nil {
+			return nil, err
+		}
+	}
+
+	resp, err := fetch(db.Site{}, pageUrl)
+	if err != nil {
+		return nil, err
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	// redirects are followed, relative links are resolved against the final URL
+	base = resp.Request.URL
+
+	if gofeed.DetectFeedType(bytes.NewReader(body)) != gofeed.FeedTypeUnknown {
+		feed, err := gofeed.NewParser().Parse(bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		return []DiscoveredFeed{{Url: pageUrl, Title: feed.Title}}, nil
+	}
+
+	var links []DiscoveredFeed
+	for _, link := range feedLinks(bytes.NewReader(body), base) {
+		if !containsFeed(links, link.Url) {
+			links = append(links, link)
+		}
+	}
+	if feeds := probeFeeds(links); len(feeds) > 0 {
+		return feeds, nil
+	}
+
+	var guesses []DiscoveredFeed
+	for _, p := range commonFeedPaths {
+		u := &url.URL{Scheme: base.Scheme, Host: base.Host, Path: p}
+		guesses = append(guesses, DiscoveredFeed{Url: u.String()})
+	}
+	feeds := probeFeeds(guesses)
+	if len(feeds) == 0 {
+		return nil, fmt.Errorf("No feed found at: '%v'", pageUrl)
+	}
+
+	return feeds, nil
+}
+
+// probeFeeds fetches and parses the candidate feeds concurrently and returns the
+// valid ones in the same order, titled by the feeds unless they already are.
+// The ones taking longer than probeTimeout are left out.
+func probeFeeds(candidates []DiscoveredFeed) []DiscoveredFeed {
+	type probe struct {
+		idx  int
+		feed DiscoveredFeed
+		err  error
+	}
+	probes := make(chan probe, len(candidates))
+	for i, f := range candidates {
+		go func(i int, f DiscoveredFeed) {
+			feed, err := CheckUrl(f.Url)
+			if err == nil && len(f.Title) == 0 {
+				f.Title = feed.Title
+			}
+			probes <- probe{i, f, err}
+		}(i, f)
+	}
+
+	valid := make([]*DiscoveredFeed, len(candidates))
+	timeout := time.After(probeTimeout)
+probing:
+	for range candidates {
+		select {
+		case p := <-probes:
+			if p.err == nil {
+				valid[p.idx] = &p.feed
+			}
+		case <-timeout:
+			break probing
+		}
+	}
+
+	var feeds []DiscoveredFeed
+	for _, f := range valid {
+		if f != nil {
+			feeds = append(feeds, *f)
+		}
+	}
+	return feeds
+}
+
+// feedLinks returns the feeds advertised by the <link rel="alternate">
+// elements of an HTML page
+func feedLinks(r io.Reader, base *url.URL) []DiscoveredFeed {
+	var feeds []DiscoveredFeed
+
+	z := html.NewTokenizer(r)
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			return feeds
+		case html.StartTagToken, html.SelfClosingTagToken:
+			t := z.Token()
+			if t.Data == "body" {
+				return feeds
+			}
+			if t.Data == "base" {
+				if href := attr(t, "href"); len(href) > 0 {
+					if u, err := base.Parse(href); err == nil {
+						base = u
+					}
+				}
+			}
+			if t.Data != "link" || !isFeedLink(t) {
+				continue
+			}
+			u, err := base.Parse(strings.TrimSpace(attr(t, "href")))
+			if err != nil {
+				continue
+			}
+			feeds = append(feeds, DiscoveredFeed{Url: u.String(), Title: strings.TrimSpace(attr(t, "title"))})
+		}
+	}
+}
+
+// isFeedLink checks whether a <link> element advertises a feed
+func isFeedLink(t html.Token) bool {
+	if len(attr(t, "href")) == 0 {
+		return false
+	}
+	isAlternate := false
+	for _, rel := range strings.Fields(strings.ToLower(attr(t, "rel"))) {
+		if rel == "alternate" {
+			isAlternate = true
+		}
+	}
+	if !isAlternate {
+		return false
+	}
+	linkType := strings.ToLower(strings.TrimSpace(attr(t, "type")))
+	for _, ft := range feedLinkTypes {
+		if linkType == ft {
+			return true
+		}
+	}
+	return false
+}
+
+// attr returns the value of the attribute of the token with the given name
+func attr(t html.Token, name string) string {
+	for _, a := range t.Attr {
+		if strings.ToLower(a.Key) == name {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+func containsFeed(feeds []DiscoveredFeed, feedUrl string) bool {
+	for _, f := range feeds {
+		if f.Url == feedUrl {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestDiscoverFeeds(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, `<html><head>
+<link rel="stylesheet" href="/style.css">
+<link rel="alternate" type="application/rss+xml" title="All posts" href="/posts/index.xml">
+<link rel="alternate" type="application/atom+xml" href="/posts/atom.xml">
+<link rel="alternate" type="application/rss+xml" title="Duplicate" href="/posts/index.xml">
+<link rel="alternate" type="application/rss+xml" title="Broken" href="/posts/missing.xml">
+</head><body><link rel="alternate" type="application/rss+xml" href="/ignored.xml"></body></html>`)
+	})
+	mux.HandleFunc("/blog/", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<html><head><title>No feeds advertised</title></head><body></body></html>`)
+	})
+	mux.HandleFunc("/posts/index.xml", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, testFeed)
+	})
+	mux.HandleFunc("/posts/atom.xml", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, testFeed)
+	})
+	mux.HandleFunc("/rss.xml", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, testFeed)
+	})
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	for _, test := range []struct {
+		url  string
+		want []DiscoveredFeed
+	}{
+		{
+			ts.URL,
+			// the advertised feeds are validated, titled by the feed unless
+			// by the page
+			[]DiscoveredFeed{
+				{Url: ts.URL + "/posts/index.xml", Title: "All posts"},
+				{Url: ts.URL + "/posts/atom.xml", Title: "Test feed"},
+			},
+		},
+		{
+			ts.URL + "/blog/",
+			[]DiscoveredFeed{
+				{Url: ts.URL + "/rss.xml", Title: "Test feed"},
+			},
+		},
+		{
+			ts.URL + "/rss.xml",
+			[]DiscoveredFeed{
+				{Url: ts.URL + "/rss.xml", Title: "Test feed"},
+			},
+		},
+	} {
+		feeds, err := DiscoverFeeds(test.url)
+		if err != nil {
+			t.Fatalf("for %v unexpected error %v", test.url, err)
+		}
+		if len(feeds) != len(test.want) {
+			t.Fatalf("for %v got %v, want %v", test.url, feeds, test.want)
+		}
+		for i := range test.want {
+			if feeds[i] != test.want[i] {
+				t.Errorf("for %v feed %v got %v, want %v", test.url, i, feeds[i], test.want[i])
+			}
+		}
+	}
+}
+
+func TestDiscoverFeedsNotFound(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, `<html><head></head><body>Nothing here</body></html>`)
+	}))
+	defer ts.Close()
+
+	if feeds, err := DiscoverFeeds(ts.URL); err == nil {
+		t.Errorf("expected error, got feeds %v", feeds)
+	}
+}
+
+func TestProbeFeedsTimeout(t *testing.T) {
+	ts := newFixtureServer(t)
+	defer func(saved time.Duration) { probeTimeout = saved }(probeTimeout)
+	probeTimeout = 200 * time.Millisecond
+
+	start := time.Now()
+	feeds := probeFeeds([]DiscoveredFeed{{Url: ts.URL + "/slow"}, {Url: ts.URL + "/feed.rss"}, {Url: ts.URL + "/missing"}})
+	if elapsed := time.Since(start); elapsed >= time.Second {
+		t.Errorf("Probing took %v, want the slow feed given up", elapsed)
+	}
+	if len(feeds) != 1 || feeds[0].Url != ts.URL+"/feed.rss" {
+		t.Errorf("got feeds %v, want the fixture one only", feeds)
+	}
+}
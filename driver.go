@@ -0,0 +1,41 @@
+/*
+   Terminews is a terminal based (TUI) RSS feed manager.
+   Copyright (C) 2017  Alexandros Ntavelos, a[dot]ntavelos[at]gmail[dot]com
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+package main
+
+import (
+	c "github.com/jroimartin/gocui"
+)
+
+// driver is what the GUI needs from the terminal, so that it can be run
+// headless, e.g. by the tests
+type driver struct {
+	// size returns the size of the screen
+	size func(g *c.Gui) (int, int)
+	// update queues a function to be run by the main loop
+	update func(g *c.Gui, f func(*c.Gui) error)
+}
+
+// terminal drives the GUI through termbox
+var terminal = driver{
+	size: func(g *c.Gui) (int, int) {
+		return g.Size()
+	},
+	update: func(g *c.Gui, f func(*c.Gui) error) {
+		g.Update(f)
+	},
+}
@@ -0,0 +1,240 @@
+/*
+   Terminews is a terminal based (TUI) RSS feed manager.
+   Copyright (C) 2017  Alexandros Ntavelos, a[dot]ntavelos[at]gmail[dot]com
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	htmltemplate "html/template"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/antavelos/terminews/db"
+)
+
+// exportFunc writes the given events in a specific format
+type exportFunc func(w io.Writer, title string, events []db.Event) error
+
+// exportFormats maps the names of the supported export formats to the
+// functions writing them
+var exportFormats = map[string]exportFunc{
+	"md":       exportMarkdown,
+	"html":     exportHTML,
+	"json":     exportJSON,
+	"netscape": exportNetscape,
+	"atom":     exportAtom,
+	"rss":      exportRSS,
+}
+
+// exportRecord is the representation of an event in JSON exports
+type exportRecord struct {
+	Title     string   `json:"title"`
+	Url       string   `json:"url"`
+	Author    string   `json:"author,omitempty"`
+	Published string   `json:"published,omitempty"`
+	Tags      []string `json:"tags,omitempty"`
+	Summary   string   `json:"summary,omitempty"`
+	Sources   []string `json:"sources,omitempty"`
+}
+
+// ExportEvents writes the events in the requested format. The title is used as
+// the heading of the formats supporting one.
+func ExportEvents(w io.Writer, format, title string, events []db.Event) error {
+	export, ok := exportFormats[format]
+	if !ok {
+		return fmt.Errorf("Unknown export format: '%v'", format)
+	}
+	return export(w, title, events)
+}
+
+// ExportEventsToFile creates (or truncates) the file at the given path and
+// exports the events in it. If no format is given it is guessed by the file
+// extension.
+func ExportEventsToFile(path, format, title string, events []db.Event) error {
+	if len(format) == 0 {
+		format = exportFormatFromPath(path)
+	}
+	if _, ok := exportFormats[format]; !ok {
+		return fmt.Errorf("Unknown export format: '%v'", format)
+	}
+
+	f, err := os.Create(expandPath(path))
+	if err != nil {
+		return err
+	}
+	if err = ExportEvents(f, format, title, events); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// exportFormatFromPath guesses the export format by the extension of the path
+func exportFormatFromPath(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".md", ".markdown":
+		return "md"
+	case ".json", ".jsonl":
+		return "json"
+	case ".atom":
+		return "atom"
+	case ".rss", ".xml":
+		return "rss"
+	case ".htm", ".html":
+		if strings.Contains(strings.ToLower(filepath.Base(path)), "bookmarks") {
+			return "netscape"
+		}
+		return "html"
+	}
+	return "md"
+}
+
+func exportMarkdown(w io.Writer, title string, events []db.Event) error {
+	if _, err := fmt.Fprintf(w, "# %v\n", title); err != nil {
+		return err
+	}
+	for _, e := range events {
+		fmt.Fprintf(w, "\n## [%v](%v)\n\n", markdownEscape(e.Title), markdownUrl(e.Url))
+
+		var meta []string
+		if len(e.Author) > 0 {
+			meta = append(meta, fmt.Sprintf("By %v", markdownEscape(e.Author)))
+		}
+		if len(e.Published) > 0 {
+			meta = append(meta, fmt.Sprintf("published on %v", e.Published))
+		}
+		if len(meta) > 0 {
+			fmt.Fprintf(w, "*%v*\n\n", strings.Join(meta, ", "))
+		}
+		if tags := e.TagList(); len(tags) > 0 {
+			fmt.Fprintf(w, "Tags: `%v`\n\n", strings.Join(tags, "`, `"))
+		}
+		if len(e.Summary) > 0 {
+			if _, err := fmt.Fprintf(w, "> %v\n", strings.Replace(e.Summary, "\n", "\n> ", -1)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// markdownEscape escapes the characters which would break a markdown link or
+// emphasis
+func markdownEscape(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, "[", `\[`, "]", `\]`, "*", `\*`, "_", `\_`, "`", "\\`")
+	return r.Replace(s)
+}
+
+// markdownUrl percent-encodes the characters which would end the URL of a
+// markdown link
+func markdownUrl(url string) string {
+	r := strings.NewReplacer(" ", "%20", "(", "%28", ")", "%29")
+	return r.Replace(url)
+}
+
+var htmlExportTemplate = htmltemplate.Must(htmltemplate.New("export").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: sans-serif; max-width: 50em; margin: 2em auto; line-height: 1.4; }
+.meta { color: #666; font-size: 0.9em; }
+.tag { background: #eee; border-radius: 3px; padding: 0 0.3em; margin-right: 0.3em; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+{{range .Events}}<article>
+<h2><a href="{{.Url}}">{{.Title}}</a></h2>
+<p class="meta">{{if .Author}}By {{.Author}}{{end}}{{if .Published}} on {{.Published}}{{end}}</p>
+{{if .TagList}}<p>{{range .TagList}}<span class="tag">{{.}}</span>{{end}}</p>
+{{end}}{{if .Summary}}<p>{{.Summary}}</p>
+{{end}}</article>
+{{end}}</body>
+</html>
+`))
+
+func exportHTML(w io.Writer, title string, events []db.Event) error {
+	return htmlExportTemplate.Execute(w, struct {
+		Title  string
+		Events []db.Event
+	}{title, events})
+}
+
+func exportJSON(w io.Writer, title string, events []db.Event) error {
+	enc := json.NewEncoder(w)
+	for _, e := range events {
+		r := exportRecord{
+			Title:     e.Title,
+			Url:       e.Url,
+			Author:    e.Author,
+			Published: e.Published,
+			Tags:      e.TagList(),
+			Summary:   e.Summary,
+			Sources:   e.Sources,
+		}
+		if err := enc.Encode(r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// exportNetscape writes the events in the Netscape bookmark file format which
+// is supported by the import functionality of all the major browsers
+func exportNetscape(w io.Writer, title string, events []db.Event) error {
+	_, err := fmt.Fprintf(w, "<!DOCTYPE NETSCAPE-Bookmark-file-1>\n"+
+		"<META HTTP-EQUIV=\"Content-Type\" CONTENT=\"text/html; charset=UTF-8\">\n"+
+		"<TITLE>Bookmarks</TITLE>\n<H1>Bookmarks</H1>\n<DL><p>\n"+
+		"    <DT><H3>%v</H3>\n    <DL><p>\n", html.EscapeString(title))
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().Unix()
+	for _, e := range events {
+		added := now
+		if t, ok := parseDate(e.Published); ok {
+			added = t.Unix()
+		}
+		fmt.Fprintf(w, "        <DT><A HREF=\"%v\" ADD_DATE=\"%d\"", html.EscapeString(e.Url), added)
+		if tags := e.TagList(); len(tags) > 0 {
+			fmt.Fprintf(w, " TAGS=\"%v\"", html.EscapeString(strings.Join(tags, ",")))
+		}
+		fmt.Fprintf(w, ">%v</A>\n", html.EscapeString(e.Title))
+
+		var desc []string
+		if len(e.Author) > 0 {
+			desc = append(desc, fmt.Sprintf("By %v.", e.Author))
+		}
+		if len(e.Summary) > 0 {
+			desc = append(desc, e.Summary)
+		}
+		if len(desc) > 0 {
+			fmt.Fprintf(w, "        <DD>%v\n", html.EscapeString(strings.Join(desc, " ")))
+		}
+	}
+
+	_, err = fmt.Fprint(w, "    </DL><p>\n</DL><p>\n")
+	return err
+}
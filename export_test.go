@@ -0,0 +1,182 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/antavelos/terminews/db"
+	"github.com/mmcdole/gofeed"
+)
+
+var exportEvents = []db.Event{
+	{
+		Title:     "Go 1.16 is released",
+		Author:    "The Go team",
+		Url:       "https://blog.golang.org/go1.16",
+		Summary:   "Modules on by default & embed",
+		Published: "Tue, 16 Feb 2021 12:00:00 +0000",
+		Tags:      "go, release",
+	},
+	{
+		Title: "Untitled <draft>",
+		Url:   "https://example.org/draft?a=1&b=2",
+	},
+}
+
+func TestExportEvents(t *testing.T) {
+	for _, test := range []struct {
+		format string
+		want   []string
+	}{
+		{
+			"md",
+			[]string{
+				"# My bookmarks\n",
+				"## [Go 1.16 is released](https://blog.golang.org/go1.16)",
+				"*By The Go team, published on Tue, 16 Feb 2021 12:00:00 +0000*",
+				"Tags: `go`, `release`",
+				"> Modules on by default & embed",
+				"## [Untitled <draft>](https://example.org/draft?a=1&b=2)",
+			},
+		},
+		{
+			"html",
+			[]string{
+				"<title>My bookmarks</title>",
+				`<a href="https://blog.golang.org/go1.16">Go 1.16 is released</a>`,
+				`<span class="tag">go</span><span class="tag">release</span>`,
+				"Modules on by default &amp; embed",
+				"Untitled &lt;draft&gt;",
+			},
+		},
+		{
+			"netscape",
+			[]string{
+				"<!DOCTYPE NETSCAPE-Bookmark-file-1>",
+				`<A HREF="https://blog.golang.org/go1.16" ADD_DATE="1613476800" TAGS="go,release">Go 1.16 is released</A>`,
+				"<DD>By The Go team. Modules on by default &amp; embed",
+				`<A HREF="https://example.org/draft?a=1&amp;b=2"`,
+				">Untitled &lt;draft&gt;</A>",
+			},
+		},
+	} {
+		var buf bytes.Buffer
+		if err := ExportEvents(&buf, test.format, "My bookmarks", exportEvents); err != nil {
+			t.Fatalf("format %v: unexpected error %v", test.format, err)
+		}
+		for _, w := range test.want {
+			if !strings.Contains(buf.String(), w) {
+				t.Errorf("format %v: output does not contain '%v':\n%v", test.format, w, buf.String())
+			}
+		}
+	}
+}
+
+func TestExportMarkdownUrls(t *testing.T) {
+	var b bytes.Buffer
+	events := []db.Event{
+		{Title: "Go (programming language)", Url: "https://en.wikipedia.org/wiki/Go_(programming_language)"},
+		{Title: "Spaces", Url: "https://example.org/my news.html"},
+	}
+	if err := exportMarkdown(&b, "Links", events); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"## [Go (programming language)](https://en.wikipedia.org/wiki/Go_%28programming_language%29)",
+		"## [Spaces](https://example.org/my%20news.html)",
+	} {
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("Markdown export %q does not contain %q", b.String(), want)
+		}
+	}
+}
+
+func TestExportJSON(t *testing.T) {
+	var buf bytes.Buffer
+	if err := ExportEvents(&buf, "json", "", exportEvents); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != len(exportEvents) {
+		t.Fatalf("got %v lines, want %v", len(lines), len(exportEvents))
+	}
+	var r exportRecord
+	if err := json.Unmarshal([]byte(lines[0]), &r); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if r.Title != exportEvents[0].Title || r.Url != exportEvents[0].Url {
+		t.Errorf("got %v, want %v", r, exportEvents[0])
+	}
+	if len(r.Tags) != 2 || r.Tags[0] != "go" || r.Tags[1] != "release" {
+		t.Errorf("got tags %v, want [go release]", r.Tags)
+	}
+}
+
+func TestExportFeeds(t *testing.T) {
+	for _, format := range []string{"atom", "rss"} {
+		var buf bytes.Buffer
+		if err := ExportEvents(&buf, format, "My bookmarks", exportEvents); err != nil {
+			t.Fatalf("format %v: unexpected error %v", format, err)
+		}
+		feed, err := gofeed.NewParser().ParseString(buf.String())
+		if err != nil {
+			t.Fatalf("format %v: invalid feed %v:\n%v", format, err, buf.String())
+		}
+		if feed.FeedType != format || feed.Title != "My bookmarks" || len(feed.Items) != len(exportEvents) {
+			t.Fatalf("format %v: got %v feed %q with %v items", format, feed.FeedType, feed.Title, len(feed.Items))
+		}
+		item := feed.Items[0]
+		if item.Title != exportEvents[0].Title || item.Link != exportEvents[0].Url ||
+			item.Description != exportEvents[0].Summary || item.Author == nil || item.Author.Name != exportEvents[0].Author {
+			t.Errorf("format %v: got item %+v", format, item)
+		}
+		if item.PublishedParsed == nil || item.PublishedParsed.Unix() != 1613476800 {
+			t.Errorf("format %v: got published %v", format, item.Published)
+		}
+		if strings.Join(item.Categories, ",") != "go,release" {
+			t.Errorf("format %v: got categories %v, want the tags", format, item.Categories)
+		}
+		if feed.Items[1].Title != exportEvents[1].Title || feed.Items[1].Link != exportEvents[1].Url {
+			t.Errorf("format %v: got item %+v", format, feed.Items[1])
+		}
+	}
+}
+
+func TestEventsWithCategory(t *testing.T) {
+	events := []db.Event{
+		{Title: "a", Categories: []string{"Golang"}},
+		{Title: "b", Tags: "golang"},
+		{Title: "c", Categories: []string{"Rust"}},
+	}
+	if got := eventsWithCategory(events, "golang"); len(got) != 2 || got[0].Title != "a" || got[1].Title != "b" {
+		t.Errorf("got %v, want a and b", got)
+	}
+}
+
+func TestExportUnknownFormat(t *testing.T) {
+	var buf bytes.Buffer
+	if err := ExportEvents(&buf, "pdf", "", exportEvents); err == nil {
+		t.Errorf("expected error for unknown format")
+	}
+}
+
+func TestExportFormatFromPath(t *testing.T) {
+	for path, want := range map[string]string{
+		"reading.md":       "md",
+		"list.markdown":    "md",
+		"items.jsonl":      "json",
+		"page.html":        "html",
+		"bookmarks.html":   "netscape",
+		"no-extension":     "md",
+		"/tmp/EXPORT.JSON": "json",
+		"reading.atom":     "atom",
+		"reading.xml":      "rss",
+	} {
+		if got := exportFormatFromPath(path); got != want {
+			t.Errorf("for %v got %v, want %v", path, got, want)
+		}
+	}
+}
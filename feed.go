@@ -0,0 +1,237 @@
+/*
+   Terminews is a terminal based (TUI) RSS feed manager.
+   Copyright (C) 2017  Alexandros Ntavelos, a[dot]ntavelos[at]gmail[dot]com
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+package main
+
+import (
+	"encoding/xml"
+	"io"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/antavelos/terminews/db"
+)
+
+// generator identifies the app in the generated feeds
+const generator = "terminews"
+
+// generatorUrl is the link of the generated RSS feeds which are not served
+const generatorUrl = "https://github.com/antavelos/terminews"
+
+type atomFeed struct {
+	XMLName   xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Title     string      `xml:"title"`
+	Id        string      `xml:"id"`
+	Updated   string      `xml:"updated"`
+	Links     []atomLink  `xml:"link"`
+	Author    atomAuthor  `xml:"author"`
+	Generator string      `xml:"generator"`
+	Entries   []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+type atomEntry struct {
+	Title      string         `xml:"title"`
+	Id         string         `xml:"id"`
+	Updated    string         `xml:"updated"`
+	Published  string         `xml:"published,omitempty"`
+	Link       atomLink       `xml:"link"`
+	Author     *atomAuthor    `xml:"author"`
+	Summary    string         `xml:"summary,omitempty"`
+	Categories []atomCategory `xml:"category"`
+}
+
+type rssFeed struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	DC      string     `xml:"xmlns:dc,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	LastBuildDate string    `xml:"lastBuildDate"`
+	Generator     string    `xml:"generator"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title       string   `xml:"title"`
+	Link        string   `xml:"link"`
+	Guid        rssGuid  `xml:"guid"`
+	PubDate     string   `xml:"pubDate,omitempty"`
+	Creator     string   `xml:"dc:creator,omitempty"`
+	Description string   `xml:"description,omitempty"`
+	Categories  []string `xml:"category"`
+}
+
+type rssGuid struct {
+	Value       string `xml:",chardata"`
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+}
+
+// exportAtom writes the events as an Atom feed
+func exportAtom(w io.Writer, title string, events []db.Event) error {
+	return writeAtom(w, title, "", events)
+}
+
+// exportRSS writes the events as an RSS 2.0 feed
+func exportRSS(w io.Writer, title string, events []db.Event) error {
+	return writeRSS(w, title, "", events)
+}
+
+// writeAtom writes the events as an Atom feed. The self link is the URL the
+// feed is served at, if any.
+func writeAtom(w io.Writer, title, self string, events []db.Event) error {
+	now := time.Now().UTC()
+	feed := atomFeed{
+		Title:     title,
+		Id:        self,
+		Author:    atomAuthor{generator},
+		Generator: generator,
+	}
+	if len(self) > 0 {
+		feed.Links = []atomLink{{self, "self"}}
+	} else {
+		feed.Id = "urn:terminews:" + url.PathEscape(strings.ToLower(title))
+	}
+
+	var updated time.Time
+	for _, e := range events {
+		entry := atomEntry{
+			Title:   e.Title,
+			Id:      e.Url,
+			Link:    atomLink{e.Url, "alternate"},
+			Summary: e.Summary,
+		}
+		entryUpdated := now
+		if t, ok := parseDate(e.Published); ok {
+			entryUpdated = t.UTC()
+			entry.Published = entryUpdated.Format(time.RFC3339)
+		}
+		entry.Updated = entryUpdated.Format(time.RFC3339)
+		if entryUpdated.After(updated) {
+			updated = entryUpdated
+		}
+		if len(e.Author) > 0 {
+			entry.Author = &atomAuthor{e.Author}
+		}
+		for _, c := range eventCategories(e) {
+			entry.Categories = append(entry.Categories, atomCategory{c})
+		}
+		feed.Entries = append(feed.Entries, entry)
+	}
+	if updated.IsZero() {
+		updated = now
+	}
+	feed.Updated = updated.Format(time.RFC3339)
+
+	return writeXML(w, feed)
+}
+
+// writeRSS writes the events as an RSS 2.0 feed. The self link is the URL the
+// feed is served at, if any.
+func writeRSS(w io.Writer, title, self string, events []db.Event) error {
+	channel := rssChannel{
+		Title:         title,
+		Link:          self,
+		Description:   title,
+		LastBuildDate: time.Now().UTC().Format(time.RFC1123Z),
+		Generator:     generator,
+	}
+	if len(self) == 0 {
+		channel.Link = generatorUrl
+	}
+
+	for _, e := range events {
+		item := rssItem{
+			Title:       e.Title,
+			Link:        e.Url,
+			Guid:        rssGuid{e.Url, true},
+			Creator:     e.Author,
+			Description: e.Summary,
+			Categories:  eventCategories(e),
+		}
+		if t, ok := parseDate(e.Published); ok {
+			item.PubDate = t.Format(time.RFC1123Z)
+		}
+		channel.Items = append(channel.Items, item)
+	}
+
+	return writeXML(w, rssFeed{Version: "2.0", DC: "http://purl.org/dc/elements/1.1/", Channel: channel})
+}
+
+func writeXML(w io.Writer, v interface{}) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// eventCategories returns the categories of the event in its feed along with
+// its tags
+func eventCategories(e db.Event) []string {
+	var categories []string
+	for _, c := range append(append([]string{}, e.Categories...), e.TagList()...) {
+		if c = strings.TrimSpace(c); len(c) > 0 && !containsFold(categories, c) {
+			categories = append(categories, c)
+		}
+	}
+	return categories
+}
+
+// eventsWithCategory filters the events filed under the given category or
+// having it as a tag
+func eventsWithCategory(events []db.Event, category string) []db.Event {
+	var filtered []db.Event
+	for _, e := range events {
+		if containsFold(eventCategories(e), category) {
+			filtered = append(filtered, e)
+		}
+	}
+	return filtered
+}
+
+func containsFold(list []string, s string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, s) {
+			return true
+		}
+	}
+	return false
+}
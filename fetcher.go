@@ -0,0 +1,258 @@
+/*
+   Terminews is a terminal based (TUI) RSS feed manager.
+   Copyright (C) 2017  Alexandros Ntavelos, a[dot]ntavelos[at]gmail[dot]com
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/antavelos/terminews/db"
+	"golang.org/x/net/http/httpproxy"
+)
+
+// defaultFetchTimeout is the time a request of a feed or an article is given
+// to complete
+const defaultFetchTimeout = 30 * time.Second
+
+const (
+	// proxyDirect disables the proxy
+	proxyDirect = "direct"
+	// proxyTor stands for the SOCKS proxy of a local Tor daemon
+	proxyTor = "tor"
+)
+
+// torProxy is the SOCKS proxy of a local Tor daemon, used by default for the
+// onion sites
+var torProxy = "socks5://127.0.0.1:9050"
+
+// Fetcher requests the feeds, the articles and the enclosures of the sites
+type Fetcher interface {
+	// Fetch requests the url with the settings of the site. It fails on any
+	// non successful status.
+	Fetch(site db.Site, url string) (*http.Response, error)
+	// Download is Fetch without timeout, for the downloads which may take long
+	Download(site db.Site, url string) (*http.Response, error)
+	// Client returns the client of the requests to other servers, e.g. the
+	// sync server
+	Client() *http.Client
+}
+
+// fetchOptions configures the HTTP clients of a fetcher
+type fetchOptions struct {
+	// Timeout of the requests, zero for none
+	Timeout time.Duration
+	// UserAgent is used unless the site sets its own
+	UserAgent string
+	// Insecure skips the verification of the TLS certificates
+	Insecure bool
+	// CAFile is a PEM file of certificate authorities trusted besides the
+	// ones of the system
+	CAFile string
+	// Proxy is the URL of an HTTP(S) or SOCKS5 proxy, "tor" or "direct".
+	// The proxy of the environment is used if empty.
+	Proxy string
+}
+
+// httpFetcher fetches with HTTP clients
+type httpFetcher struct {
+	client    *http.Client
+	download  *http.Client
+	userAgent string
+}
+
+// defaultFetcher returns the fetcher used until the fetch settings are loaded
+func defaultFetcher() Fetcher {
+	return &httpFetcher{
+		client:    &http.Client{Timeout: defaultFetchTimeout, CheckRedirect: reauthorize},
+		download:  &http.Client{CheckRedirect: reauthorize},
+		userAgent: defaultUserAgent,
+	}
+}
+
+// newHTTPFetcher returns a fetcher configured by the given options
+func newHTTPFetcher(opts fetchOptions) (*httpFetcher, error) {
+	tlsConfig := &tls.Config{InsecureSkipVerify: opts.Insecure}
+	if len(opts.CAFile) > 0 {
+		pem, err := ioutil.ReadFile(expandPath(opts.CAFile))
+		if err != nil {
+			return nil, err
+		}
+		if tlsConfig.RootCAs, err = x509.SystemCertPool(); err != nil {
+			tlsConfig.RootCAs = x509.NewCertPool()
+		}
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("No certificate found in %v", opts.CAFile)
+		}
+	}
+	proxy, err := proxyFunc(opts.Proxy)
+	if err != nil {
+		return nil, err
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	transport.Proxy = func(req *http.Request) (*url.URL, error) {
+		// the proxy of the site, nil if it connects directly
+		if u, ok := req.Context().Value(siteProxyKey{}).(*url.URL); ok {
+			return u, nil
+		}
+		u, err := proxy(req.URL)
+		if u == nil && err == nil && isOnion(req.URL) {
+			return parseProxy(torProxy)
+		}
+		return u, err
+	}
+
+	userAgent := opts.UserAgent
+	if len(userAgent) == 0 {
+		userAgent = defaultUserAgent
+	}
+	return &httpFetcher{
+		client:    &http.Client{Transport: transport, Timeout: opts.Timeout, CheckRedirect: reauthorize},
+		download:  &http.Client{Transport: transport, CheckRedirect: reauthorize},
+		userAgent: userAgent,
+	}, nil
+}
+
+// loadFetcher returns a fetcher configured by the fetch settings
+func loadFetcher() (*httpFetcher, error) {
+	return newHTTPFetcher(fetchOptions{
+		Timeout:   time.Duration(getIntSetting("fetch.timeout_seconds")) * time.Second,
+		UserAgent: getSetting("fetch.user_agent"),
+		Insecure:  getBoolSetting("fetch.insecure"),
+		CAFile:    getSetting("fetch.ca_file"),
+		Proxy:     getSetting("fetch.proxy"),
+	})
+}
+
+// parseProxy returns the URL of a proxy setting, nil if it is direct
+func parseProxy(value string) (*url.URL, error) {
+	switch value = strings.TrimSpace(value); value {
+	case proxyDirect:
+		return nil, nil
+	case proxyTor:
+		value = torProxy
+	}
+	u, err := url.Parse(value)
+	if err != nil {
+		return nil, err
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5":
+	case "socks5h":
+		// the SOCKS proxies resolve the host names anyway
+		u.Scheme = "socks5"
+	default:
+		return nil, fmt.Errorf("unsupported proxy '%v', expected an http://, https:// or socks5:// URL, tor or direct", value)
+	}
+	if len(u.Host) == 0 {
+		return nil, fmt.Errorf("no host in proxy '%v'", value)
+	}
+	return u, nil
+}
+
+func validateProxy(value string) error {
+	if len(value) == 0 {
+		return nil
+	}
+	_, err := parseProxy(value)
+	return err
+}
+
+// proxyFunc returns the function choosing the proxy of a request given the
+// proxy setting. The hosts listed in NO_PROXY are connected directly.
+func proxyFunc(value string) (func(*url.URL) (*url.URL, error), error) {
+	if len(value) == 0 {
+		return httpproxy.FromEnvironment().ProxyFunc(), nil
+	}
+	u, err := parseProxy(value)
+	if err != nil || u == nil {
+		return func(*url.URL) (*url.URL, error) { return nil, nil }, err
+	}
+	noProxy := os.Getenv("NO_PROXY")
+	if len(noProxy) == 0 {
+		noProxy = os.Getenv("no_proxy")
+	}
+	config := httpproxy.Config{HTTPProxy: u.String(), HTTPSProxy: u.String(), NoProxy: noProxy}
+	return config.ProxyFunc(), nil
+}
+
+// isOnion reports whether the URL is the one of a Tor onion service
+func isOnion(u *url.URL) bool {
+	return strings.HasSuffix(strings.ToLower(u.Hostname()), ".onion")
+}
+
+// siteProxyKey is the context key of the proxy of the site of a request
+type siteProxyKey struct{}
+
+func (f *httpFetcher) Fetch(site db.Site, url string) (*http.Response, error) {
+	return f.do(f.client, site, url)
+}
+
+func (f *httpFetcher) Download(site db.Site, url string) (*http.Response, error) {
+	return f.do(f.download, site, url)
+}
+
+func (f *httpFetcher) Client() *http.Client {
+	return f.client
+}
+
+// do requests the url with the given client applying the user agent, the
+// extra headers and the authentication of the site, the latter again on every
+// redirection
+func (f *httpFetcher) do(client *http.Client, site db.Site, url string) (*http.Response, error) {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(context.WithValue(req.Context(), siteAuthKey{}, site))
+	if len(site.Proxy) > 0 {
+		proxy, err := parseProxy(site.Proxy)
+		if err != nil {
+			return nil, err
+		}
+		req = req.WithContext(context.WithValue(req.Context(), siteProxyKey{}, proxy))
+	}
+	for name, values := range siteHeaders(site) {
+		req.Header[name] = values
+	}
+	if err = authorize(req, site); err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", f.userAgent)
+	if len(site.UserAgent) > 0 {
+		req.Header.Set("User-Agent", site.UserAgent)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		resp.Body.Close()
+		return nil, StatusError{url, resp.StatusCode, resp.Status}
+	}
+	return resp, nil
+}
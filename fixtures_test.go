@@ -0,0 +1,213 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/antavelos/terminews/db"
+)
+
+// fixturesDir holds the sample feeds and articles served to the tests
+var fixturesDir = filepath.Join("testdata", "fixtures")
+
+// newFixtureServer serves the fixtures along with:
+//
+//	/moved    permanently redirected to /feed.rss
+//	/slow     answered after a second
+//	/agent    answering the user agent of the request
+//	/missing  answered with 404, like any missing fixture
+func newFixtureServer(t *testing.T) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.Handle("/", http.FileServer(http.Dir(fixturesDir)))
+	mux.HandleFunc("/moved", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/feed.rss", http.StatusMovedPermanently)
+	})
+	mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(time.Second):
+		case <-r.Context().Done():
+		}
+	})
+	mux.HandleFunc("/agent", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, r.UserAgent())
+	})
+	ts := httptest.NewServer(mux)
+	t.Cleanup(ts.Close)
+
+	return ts
+}
+
+// fixtureFetcher answers every request with the fixture named after the last
+// element of its path, without any network
+type fixtureFetcher struct {
+	mu        sync.Mutex
+	requested []string
+}
+
+func (f *fixtureFetcher) Fetch(site db.Site, url string) (*http.Response, error) {
+	f.mu.Lock()
+	f.requested = append(f.requested, url)
+	f.mu.Unlock()
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	data, err := ioutil.ReadFile(filepath.Join(fixturesDir, path.Base(req.URL.Path)))
+	if err != nil {
+		return nil, StatusError{url, http.StatusNotFound, "404 Not Found"}
+	}
+	return &http.Response{
+		Status:     "200 OK",
+		StatusCode: http.StatusOK,
+		Body:       ioutil.NopCloser(bytes.NewReader(data)),
+		Request:    req,
+	}, nil
+}
+
+func (f *fixtureFetcher) Download(site db.Site, url string) (*http.Response, error) {
+	return f.Fetch(site, url)
+}
+
+func (f *fixtureFetcher) Client() *http.Client {
+	return http.DefaultClient
+}
+
+// useFetcher replaces the fetcher of the app during the test
+func useFetcher(t *testing.T, f Fetcher) {
+	saved := App.Web
+	App.Web = f
+	t.Cleanup(func() { App.Web = saved })
+}
+
+// proxyStandIn records the hosts requested through it
+type proxyStandIn struct {
+	url   string
+	mu    sync.Mutex
+	hosts []string
+}
+
+func (p *proxyStandIn) record(host string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.hosts = append(p.hosts, host)
+}
+
+func (p *proxyStandIn) requested() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]string(nil), p.hosts...)
+}
+
+// newHTTPProxy returns an HTTP proxy stand-in answering the requests of any
+// host with the fixtures
+func newHTTPProxy(t *testing.T) *proxyStandIn {
+	p := &proxyStandIn{}
+	files := http.FileServer(http.Dir(fixturesDir))
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p.record(r.URL.Hostname())
+		files.ServeHTTP(w, r)
+	}))
+	t.Cleanup(ts.Close)
+	p.url = ts.URL
+
+	return p
+}
+
+// newSOCKSProxy returns a SOCKS5 proxy stand-in connecting every host to the
+// given address
+func newSOCKSProxy(t *testing.T, target string) *proxyStandIn {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	p := &proxyStandIn{url: "socks5://" + ln.Addr().String()}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				host, err := socksHandshake(conn)
+				if err != nil {
+					t.Logf("SOCKS handshake failed: %v", err)
+					return
+				}
+				p.record(host)
+				upstream, err := net.Dial("tcp", target)
+				if err != nil {
+					return
+				}
+				defer upstream.Close()
+				go io.Copy(upstream, conn)
+				io.Copy(conn, upstream)
+			}()
+		}
+	}()
+
+	return p
+}
+
+// socksHandshake accepts a SOCKS5 connection without authentication and
+// returns the host of its CONNECT request
+func socksHandshake(conn net.Conn) (string, error) {
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return "", err
+	}
+	if _, err := io.ReadFull(conn, make([]byte, header[1])); err != nil {
+		return "", err
+	}
+	if _, err := conn.Write([]byte{5, 0}); err != nil {
+		return "", err
+	}
+
+	request := make([]byte, 4)
+	if _, err := io.ReadFull(conn, request); err != nil {
+		return "", err
+	}
+	var host string
+	switch request[3] {
+	case 1, 4:
+		ip := make(net.IP, 4)
+		if request[3] == 4 {
+			ip = make(net.IP, 16)
+		}
+		if _, err := io.ReadFull(conn, ip); err != nil {
+			return "", err
+		}
+		host = ip.String()
+	case 3:
+		size := make([]byte, 1)
+		if _, err := io.ReadFull(conn, size); err != nil {
+			return "", err
+		}
+		name := make([]byte, size[0])
+		if _, err := io.ReadFull(conn, name); err != nil {
+			return "", err
+		}
+		host = string(name)
+	default:
+		return "", fmt.Errorf("unknown address type %v", request[3])
+	}
+	// the port is ignored
+	if _, err := io.ReadFull(conn, make([]byte, 2)); err != nil {
+		return "", err
+	}
+
+	_, err := conn.Write([]byte{5, 0, 0, 1, 0, 0, 0, 0, 0, 0})
+	return host, err
+}
@@ -0,0 +1,150 @@
+/*
+   Terminews is a terminal based (TUI) RSS feed manager.
+   Copyright (C) 2017  Alexandros Ntavelos, a[dot]ntavelos[at]gmail[dot]com
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+package main
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/antavelos/terminews/db"
+)
+
+// brokenSiteMarker prefixes the sites whose last fetch failed
+const brokenSiteMarker = "✗ "
+
+// fetchSiteEvents downloads the news of a site, stores them, records the
+// outcome in the fetch history of the site and applies the rules on them
+func fetchSiteEvents(site db.Site) ([]db.Event, FetchInfo, error) {
+	events, info, err := DownloadEvents(site)
+	if site.Id == 0 {
+		if err == nil {
+			events = filterEvents(site, events)
+		}
+		return events, info, err
+	}
+
+	f := db.Fetch{
+		SiteId:    site.Id,
+		FetchedAt: time.Now(),
+		Status:    info.Status,
+		Latency:   info.Latency,
+		MovedTo:   info.MovedTo,
+	}
+	if err != nil {
+		f.Error = err.Error()
+	}
+	if ferr := App.tdb.AddFetch(f); ferr != nil {
+		log.Println("Error on AddFetch", ferr)
+	}
+	if err == nil {
+		// the news of a site fetched for the first time are not new
+		stored, serr := App.tdb.CountItems(site.Id)
+		if serr != nil {
+			log.Println("Error on CountItems", serr)
+		}
+		added, serr := App.tdb.SaveItems(site.Id, events)
+		if serr != nil {
+			log.Println("Error on SaveItems", serr)
+		}
+		if stored > 0 {
+			notifyNewEvents(loadRules(), site, added)
+		}
+		// the played state and the downloaded files are stored
+		if attached, aerr := App.tdb.AttachEnclosures(events); aerr != nil {
+			log.Println("Error on AttachEnclosures", aerr)
+		} else {
+			events = attached
+		}
+		events = filterEvents(site, events)
+	}
+
+	return events, info, err
+}
+
+// loadSitesHealth summarizes the fetch history of the given sites
+func loadSitesHealth(sites []db.Site) map[int]db.SiteHealth {
+	health := make(map[int]db.SiteHealth, len(sites))
+	for _, site := range sites {
+		h, err := App.tdb.GetSiteHealth(site.Id)
+		if err != nil {
+			log.Println("Error on GetSiteHealth", err)
+			continue
+		}
+		health[site.Id] = h
+	}
+	return health
+}
+
+// formatSite is the format function of the sites list which marks the sites
+// failing to be fetched
+func formatSite(item interface{}) string {
+	site := item.(db.Site)
+	name := site.Name
+	if n := App.SitesUnread[site.Id]; App.SitesSort == sortUnread && n > 0 {
+		name = fmt.Sprintf("%v (%v)", name, n)
+	}
+	if App.SitesHealth[site.Id].Failing() {
+		return brokenSiteMarker + name
+	}
+	return name
+}
+
+// siteHealthLines returns the lines describing the fetch history of a site
+func siteHealthLines(site db.Site, h db.SiteHealth) []string {
+	lines := []string{
+		fmt.Sprintf("%v %v", App.Bold.Sprint("URL:"), site.Url),
+		fmt.Sprintf("%v %v", App.Bold.Sprint("Last success:"), formatTime(h.LastSuccess)),
+		fmt.Sprintf("%v %v", App.Bold.Sprint("Last failure:"), formatTime(h.LastFailure)),
+		fmt.Sprintf("%v %v", App.Bold.Sprint("Last HTTP status:"), formatStatus(h.LastStatus)),
+		fmt.Sprintf("%v %v", App.Bold.Sprint("Consecutive failures:"), h.ConsecutiveFailures),
+		fmt.Sprintf("%v %v", App.Bold.Sprint("Average latency:"), h.AverageLatency),
+	}
+	if len(h.MovedTo) > 0 {
+		lines = append(lines, fmt.Sprintf("%v %v", App.Bold.Sprint("Moved permanently to:"), h.MovedTo))
+	}
+	if len(h.LastError) > 0 {
+		lines = append(lines, "", App.Bold.Sprint("Last error:"), h.LastError)
+	}
+
+	lines = append(lines, "", App.Bold.Sprintf("History (%v fetches):", len(h.Fetches)))
+	for _, f := range h.Fetches {
+		outcome := "OK"
+		if len(f.Error) > 0 {
+			outcome = f.Error
+		}
+		lines = append(lines, fmt.Sprintf("%v  %v  %6v  %v",
+			formatTime(f.FetchedAt), formatStatus(f.Status), f.Latency.Round(time.Millisecond), outcome))
+	}
+
+	return lines
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return "never"
+	}
+	return t.Local().Format("2006-01-02 15:04:05")
+}
+
+func formatStatus(status int) string {
+	if status == 0 {
+		return "---"
+	}
+	return fmt.Sprint(status)
+}
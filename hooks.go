@@ -0,0 +1,171 @@
+/*
+   Terminews is a terminal based (TUI) RSS feed manager.
+   Copyright (C) 2017  Alexandros Ntavelos, a[dot]ntavelos[at]gmail[dot]com
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"os"
+	"os/exec"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/antavelos/terminews/db"
+)
+
+// hookTimeout is the time a hook command is given to complete
+const hookTimeout = 30 * time.Second
+
+// hooks tracks the hooks running in the background so that the commands wait
+// for them before exiting
+var hooks sync.WaitGroup
+
+// hooksFileMu serializes the writes to the hooks file
+var hooksFileMu sync.Mutex
+
+// hookPayload is the JSON representation of a new event passed to the hooks
+type hookPayload struct {
+	Site       string          `json:"site"`
+	SiteUrl    string          `json:"site_url"`
+	Rule       string          `json:"rule"`
+	Title      string          `json:"title"`
+	Url        string          `json:"url"`
+	Author     string          `json:"author,omitempty"`
+	Published  string          `json:"published,omitempty"`
+	Summary    string          `json:"summary,omitempty"`
+	Categories []string        `json:"categories,omitempty"`
+	Enclosures []hookEnclosure `json:"enclosures,omitempty"`
+}
+
+type hookEnclosure struct {
+	Url      string `json:"url"`
+	Type     string `json:"type,omitempty"`
+	Length   int64  `json:"length,omitempty"`
+	Duration string `json:"duration,omitempty"`
+}
+
+func newHookPayload(site db.Site, rule db.Rule, e db.Event) hookPayload {
+	p := hookPayload{
+		Site:       site.Name,
+		SiteUrl:    site.Url,
+		Rule:       rule.String(),
+		Title:      e.Title,
+		Url:        e.Url,
+		Author:     e.Author,
+		Published:  e.Published,
+		Summary:    e.Summary,
+		Categories: e.Categories,
+	}
+	for _, enc := range e.Enclosures {
+		p.Enclosures = append(p.Enclosures, hookEnclosure{enc.Url, enc.Type, enc.Length, enc.Duration})
+	}
+	return p
+}
+
+// notifyNewEvents runs in the background the configured hooks for the newly
+// fetched events of a site which match a notify rule
+func notifyNewEvents(rules []compiledRule, site db.Site, events []db.Event) {
+	command, file := getSetting("hooks.command"), getSetting("hooks.file")
+	if len(command) == 0 && len(file) == 0 {
+		return
+	}
+
+	var payloads []hookPayload
+	for _, e := range events {
+		for _, r := range rules {
+			if r.Action == ruleNotify && r.matches(site, e) {
+				payloads = append(payloads, newHookPayload(site, r.Rule, e))
+				break
+			}
+		}
+	}
+	if len(payloads) == 0 {
+		return
+	}
+
+	hooks.Add(1)
+	go func() {
+		defer hooks.Done()
+		for _, p := range payloads {
+			if err := runHooks(command, file, p); err != nil {
+				log.Println("Error on runHooks", err)
+			}
+		}
+	}()
+}
+
+// runHooks passes an event to the hook command on its standard input and
+// appends it as a JSON line to the hooks file, which may be a FIFO
+func runHooks(command, file string, p hookPayload) error {
+	data, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+
+	if len(file) > 0 {
+		if err := appendHooksFile(expandPath(file), data); err != nil {
+			return err
+		}
+	}
+	if len(command) == 0 {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), hookTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	cmd.Stdin = bytes.NewReader(append(data, '\n'))
+	cmd.Env = append(os.Environ(),
+		"TERMINEWS_SITE="+p.Site,
+		"TERMINEWS_TITLE="+p.Title,
+		"TERMINEWS_URL="+p.Url,
+	)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		log.Printf("Hook command failed: %v: %s", err, out)
+		return err
+	}
+	return nil
+}
+
+// appendHooksFile appends a line to the hooks file. The line is skipped when
+// the file is a FIFO which nobody reads, rather than blocking the next hooks.
+func appendHooksFile(path string, line []byte) error {
+	hooksFileMu.Lock()
+	defer hooksFileMu.Unlock()
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE|syscall.O_NONBLOCK, 0600)
+	if err != nil {
+		if errors.Is(err, syscall.ENXIO) {
+			log.Printf("No reader of the hooks file %v, skipped %s", path, line)
+			return nil
+		}
+		return err
+	}
+	// a FIFO whose reader stopped reading does not block the writes forever,
+	// the deadline is not supported by the regular files
+	f.SetWriteDeadline(time.Now().Add(hookTimeout))
+	if _, err = f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
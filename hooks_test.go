@@ -0,0 +1,112 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/antavelos/terminews/db"
+)
+
+func TestNotifyNewEvents(t *testing.T) {
+	dir := newTestDB(t)
+
+	items := `<item><title>Go 1.16 released</title><link>https://example.org/1</link></item>`
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `<rss version="2.0"><channel><title>Test</title>%v</channel></rss>`, items)
+	}))
+	defer ts.Close()
+
+	App.tdb.AddSite(db.Site{Name: "Test", Url: ts.URL})
+	site, _ := App.tdb.GetSiteByUrl(ts.URL)
+	r, _ := parseRule("notify keyword go")
+	App.tdb.AddRule(r)
+	out, file := filepath.Join(dir, "out.json"), filepath.Join(dir, "hooks.json")
+	App.tdb.SetSetting("hooks.command", fmt.Sprintf(`cat >> %v && echo "$TERMINEWS_TITLE" >> %v`, out, out))
+	App.tdb.SetSetting("hooks.file", file)
+
+	// nothing is new on the first fetch of a site
+	if _, _, err := fetchSiteEvents(site); err != nil {
+		t.Fatal(err)
+	}
+	hooks.Wait()
+	if _, err := os.Stat(out); !os.IsNotExist(err) {
+		t.Errorf("Expected no hook to run on the first fetch")
+	}
+
+	items += `<item><title>Go 1.17 released</title><link>https://example.org/2</link></item>` +
+		`<item><title>Rust 1.50 released</title><link>https://example.org/3</link></item>`
+	if _, _, err := fetchSiteEvents(site); err != nil {
+		t.Fatal(err)
+	}
+	hooks.Wait()
+
+	data, err := ioutil.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 2 || lines[1] != "Go 1.17 released" {
+		t.Fatalf("Hook command got %q, want the JSON and the title of the new Go event", data)
+	}
+	var p hookPayload
+	if err = json.Unmarshal([]byte(lines[0]), &p); err != nil {
+		t.Fatal(err)
+	}
+	if p.Url != "https://example.org/2" || p.Site != "Test" || p.Rule != "notify keyword go" {
+		t.Errorf("Hook command got %+v", p)
+	}
+
+	data, _ = ioutil.ReadFile(file)
+	if lines := strings.Split(strings.TrimSpace(string(data)), "\n"); len(lines) != 1 || !strings.Contains(lines[0], `"url":"https://example.org/2"`) {
+		t.Errorf("Hooks file got %q, want one JSON line", data)
+	}
+}
+
+func TestAppendHooksFifo(t *testing.T) {
+	dir, err := ioutil.TempDir("", "terminews")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	fifo := filepath.Join(dir, "hooks.fifo")
+	if err := exec.Command("mkfifo", fifo).Run(); err != nil {
+		t.Skipf("Cannot create a FIFO: %v", err)
+	}
+
+	// the line is skipped while nobody reads the FIFO
+	done := make(chan error)
+	go func() { done <- appendHooksFile(fifo, []byte(`{"title":"skipped"}`)) }()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Got %v appending to a FIFO without reader", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Appending to a FIFO without reader blocked")
+	}
+
+	r, err := os.OpenFile(fifo, os.O_RDONLY|syscall.O_NONBLOCK, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	if err := appendHooksFile(fifo, []byte(`{"title":"read"}`)); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 64)
+	r.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, err := r.Read(buf)
+	if err != nil || string(buf[:n]) != "{\"title\":\"read\"}\n" {
+		t.Errorf("Read %q (%v), want the line", buf[:n], err)
+	}
+}
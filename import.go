@@ -0,0 +1,301 @@
+/*
+   Terminews is a terminal based (TUI) RSS feed manager.
+   Copyright (C) 2017  Alexandros Ntavelos, a[dot]ntavelos[at]gmail[dot]com
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/antavelos/terminews/db"
+	"golang.org/x/net/html"
+)
+
+// Import statuses of a bookmark
+const (
+	importNew       = "new"
+	importDuplicate = "duplicate"
+	importInvalid   = "invalid"
+)
+
+// ImportEntry is a bookmark read from an import file along with the outcome
+// of its import
+type ImportEntry struct {
+	Event  db.Event
+	Status string
+	Reason string
+}
+
+// ImportReport summarizes an import
+type ImportReport struct {
+	Entries    []ImportEntry
+	New        int
+	Duplicates int
+	Invalid    int
+}
+
+// ParseNetscapeBookmarks reads the bookmarks of a browser HTML export (Netscape
+// bookmark file format). Pocket's HTML export is supported as well. The name of
+// the folder containing a bookmark is added to its tags.
+func ParseNetscapeBookmarks(r io.Reader) ([]db.Event, error) {
+	var (
+		events   []db.Event
+		folders  []string
+		folder   string
+		inFolder bool
+		inLink   bool
+		inDesc   bool
+		text     strings.Builder
+		e        db.Event
+	)
+
+	z := html.NewTokenizer(r)
+	for {
+		tt := z.Next()
+		switch tt {
+		case html.ErrorToken:
+			if z.Err() == io.EOF {
+				return events, nil
+			}
+			return nil, z.Err()
+		case html.StartTagToken, html.SelfClosingTagToken:
+			t := z.Token()
+			switch t.Data {
+			case "h3":
+				inFolder = true
+				text.Reset()
+			case "dl":
+				// a folder's list follows its heading, a nameless one
+				// keeps the parent's name
+				if len(folder) == 0 && len(folders) > 0 {
+					folder = folders[len(folders)-1]
+				}
+				folders = append(folders, folder)
+				folder = ""
+			case "a":
+				inLink, inDesc = true, false
+				text.Reset()
+				e = db.Event{}
+				var tags []string
+				for _, a := range t.Attr {
+					switch strings.ToLower(a.Key) {
+					case "href":
+						e.Url = strings.TrimSpace(a.Val)
+					case "add_date", "time_added":
+						e.Published = parseImportDate(a.Val)
+					case "tags":
+						tags = append(tags, a.Val)
+					}
+				}
+				if len(folders) > 0 {
+					tags = append(tags, folders[len(folders)-1])
+				}
+				e.Tags = joinTags(tags...)
+			case "dd":
+				if len(events) > 0 {
+					inDesc = true
+					text.Reset()
+				}
+			case "dt":
+				inDesc = false
+			}
+		case html.EndTagToken:
+			t := z.Token()
+			switch t.Data {
+			case "h3":
+				inFolder = false
+				folder = strings.TrimSpace(text.String())
+			case "dl":
+				if len(folders) > 0 {
+					folders = folders[:len(folders)-1]
+				}
+				inDesc = false
+			case "a":
+				if inLink {
+					e.Title = strings.TrimSpace(text.String())
+					events = append(events, e)
+				}
+				inLink = false
+			}
+		case html.TextToken:
+			if inFolder || inLink {
+				text.Write(z.Text())
+			} else if inDesc {
+				text.Write(z.Text())
+				events[len(events)-1].Summary = strings.TrimSpace(text.String())
+			}
+		}
+	}
+}
+
+// ParseBookmarksCSV reads the bookmarks of a CSV export of a read-later service
+// like Pocket or Instapaper. The columns are identified by the header line.
+func ParseBookmarksCSV(r io.Reader) ([]db.Event, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.LazyQuotes = true
+
+	header, err := cr.Read()
+	if err != nil {
+		return nil, err
+	}
+	cols := map[string]int{}
+	for i, h := range header {
+		cols[strings.ToLower(strings.TrimSpace(h))] = i
+	}
+	column := func(record []string, names ...string) string {
+		for _, name := range names {
+			if i, ok := cols[name]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+		}
+		return ""
+	}
+	if column(header, "url", "link") == "" {
+		return nil, fmt.Errorf("No URL column found in CSV header: %v", strings.Join(header, ","))
+	}
+
+	var events []db.Event
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			return events, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		e := db.Event{
+			Url:       column(record, "url", "link"),
+			Title:     column(record, "title"),
+			Summary:   column(record, "selection", "excerpt", "description", "summary", "note"),
+			Published: parseImportDate(column(record, "time_added", "timestamp", "created", "date")),
+		}
+		tags := strings.FieldsFunc(column(record, "tags"), func(r rune) bool {
+			return r == '|' || r == ','
+		})
+		// Instapaper keeps the bookmarks of the user in folders
+		switch folder := column(record, "folder"); strings.ToLower(folder) {
+		case "", "unread", "archive":
+		default:
+			tags = append(tags, folder)
+		}
+		e.Tags = joinTags(tags...)
+		events = append(events, e)
+	}
+}
+
+// ParseBookmarks reads bookmarks in the given format ("html" or "csv").
+func ParseBookmarks(r io.Reader, format string) ([]db.Event, error) {
+	switch format {
+	case "html":
+		return ParseNetscapeBookmarks(r)
+	case "csv":
+		return ParseBookmarksCSV(r)
+	}
+	return nil, fmt.Errorf("Unknown import format: '%v'", format)
+}
+
+// ImportEvents stores the given bookmarks unless they already exist or their
+// URL is invalid. Titles and summaries missing from the import are fetched
+// later, when the bookmarks get displayed. Nothing is stored on dry run.
+func ImportEvents(events []db.Event, dryRun bool) (ImportReport, error) {
+	var report ImportReport
+	seen := map[string]bool{}
+
+	for _, e := range events {
+		entry := ImportEntry{Event: e, Status: importNew}
+		if u, err := url.Parse(e.Url); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			entry.Status, entry.Reason = importInvalid, "not an http(s) URL"
+		} else if seen[e.Url] {
+			entry.Status, entry.Reason = importDuplicate, "appears more than once in the import"
+		} else if _, err := App.tdb.GetEventByUrl(e.Url); err == nil {
+			entry.Status, entry.Reason = importDuplicate, "already bookmarked"
+		} else if _, ok := err.(db.NotFound); !ok {
+			return report, err
+		}
+		seen[e.Url] = true
+
+		switch entry.Status {
+		case importNew:
+			report.New++
+			if !dryRun {
+				if len(e.Title) == 0 {
+					e.Title = e.Url
+				}
+				if err := App.tdb.AddEvent(e); err != nil {
+					return report, err
+				}
+			}
+		case importDuplicate:
+			report.Duplicates++
+		case importInvalid:
+			report.Invalid++
+		}
+		report.Entries = append(report.Entries, entry)
+	}
+
+	return report, nil
+}
+
+// eventPendingDetails indicates whether an imported event still misses its
+// details which will be fetched lazily
+func eventPendingDetails(e db.Event) bool {
+	return len(e.Summary) == 0
+}
+
+// importFormatFromPath guesses the import format by the extension of the path
+func importFormatFromPath(path string) string {
+	if strings.HasSuffix(strings.ToLower(path), ".csv") {
+		return "csv"
+	}
+	return "html"
+}
+
+// parseImportDate converts a unix timestamp or a date of any known layout to
+// the format of the published date of the feeds
+func parseImportDate(date string) string {
+	date = strings.TrimSpace(date)
+	if secs, err := strconv.ParseInt(date, 10, 64); err == nil {
+		return time.Unix(secs, 0).UTC().Format(time.RFC1123Z)
+	}
+	if t, ok := parseDate(date); ok {
+		return t.Format(time.RFC1123Z)
+	}
+	return date
+}
+
+// joinTags merges the given comma separated tags dropping the empty and
+// duplicate ones
+func joinTags(tags ...string) string {
+	var result []string
+	seen := map[string]bool{}
+	for _, t := range strings.Split(strings.Join(tags, ","), ",") {
+		t = strings.TrimSpace(t)
+		if len(t) == 0 || seen[strings.ToLower(t)] {
+			continue
+		}
+		seen[strings.ToLower(t)] = true
+		result = append(result, t)
+	}
+	return strings.Join(result, ",")
+}
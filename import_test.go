@@ -0,0 +1,130 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/antavelos/terminews/db"
+)
+
+const netscapeBookmarks = `<!DOCTYPE NETSCAPE-Bookmark-file-1>
+<META HTTP-EQUIV="Content-Type" CONTENT="text/html; charset=UTF-8">
+<TITLE>Bookmarks</TITLE>
+<H1>Bookmarks</H1>
+<DL><p>
+    <DT><A HREF="https://golang.org/" ADD_DATE="1613476800">The Go Programming Language</A>
+    <DT><H3 ADD_DATE="1613476800">Reading</H3>
+    <DL><p>
+        <DT><A HREF="https://blog.golang.org/go1.16" TAGS="go,release">Go 1.16 &amp; modules</A>
+        <DD>Modules on by default
+        <DT><A HREF="javascript:alert(1)">Bookmarklet</A>
+    </DL><p>
+    <DT><A HREF="https://golang.org/">The Go Programming Language</A>
+</DL><p>
+`
+
+const pocketCSV = `title,url,time_added,tags,status
+Go 1.16,https://blog.golang.org/go1.16,1613476800,go|release,unread
+,https://example.org/untitled,1613476800,,archive
+`
+
+const instapaperCSV = `URL,Title,Selection,Folder,Timestamp
+https://example.org/a,Article A,Some selection,Unread,1613476800
+https://example.org/b,Article B,,Work,1613476800
+`
+
+func TestParseNetscapeBookmarks(t *testing.T) {
+	events, err := ParseNetscapeBookmarks(strings.NewReader(netscapeBookmarks))
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	want := []db.Event{
+		{Title: "The Go Programming Language", Url: "https://golang.org/", Published: "Tue, 16 Feb 2021 12:00:00 +0000"},
+		{Title: "Go 1.16 & modules", Url: "https://blog.golang.org/go1.16", Summary: "Modules on by default", Tags: "go,release,Reading"},
+		{Title: "Bookmarklet", Url: "javascript:alert(1)", Tags: "Reading"},
+		{Title: "The Go Programming Language", Url: "https://golang.org/"},
+	}
+	if len(events) != len(want) {
+		t.Fatalf("got %v events, want %v: %v", len(events), len(want), events)
+	}
+	for i := range want {
+		if !reflect.DeepEqual(events[i], want[i]) {
+			t.Errorf("event %v got %#v, want %#v", i, events[i], want[i])
+		}
+	}
+}
+
+func TestParseBookmarksCSV(t *testing.T) {
+	for _, test := range []struct {
+		csv  string
+		want []db.Event
+	}{
+		{
+			pocketCSV,
+			[]db.Event{
+				{Title: "Go 1.16", Url: "https://blog.golang.org/go1.16", Published: "Tue, 16 Feb 2021 12:00:00 +0000", Tags: "go,release"},
+				{Url: "https://example.org/untitled", Published: "Tue, 16 Feb 2021 12:00:00 +0000"},
+			},
+		},
+		{
+			instapaperCSV,
+			[]db.Event{
+				{Title: "Article A", Url: "https://example.org/a", Summary: "Some selection", Published: "Tue, 16 Feb 2021 12:00:00 +0000"},
+				{Title: "Article B", Url: "https://example.org/b", Published: "Tue, 16 Feb 2021 12:00:00 +0000", Tags: "Work"},
+			},
+		},
+	} {
+		events, err := ParseBookmarksCSV(strings.NewReader(test.csv))
+		if err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+		if len(events) != len(test.want) {
+			t.Fatalf("got %v events, want %v: %v", len(events), len(test.want), events)
+		}
+		for i := range test.want {
+			if !reflect.DeepEqual(events[i], test.want[i]) {
+				t.Errorf("event %v got %#v, want %#v", i, events[i], test.want[i])
+			}
+		}
+	}
+
+	if _, err := ParseBookmarksCSV(strings.NewReader("title,date\nfoo,bar\n")); err == nil {
+		t.Errorf("expected error for CSV without URL column")
+	}
+}
+
+func TestImportEvents(t *testing.T) {
+	newTestDB(t)
+
+	App.tdb.AddEvent(db.Event{Title: "Existing", Url: "https://golang.org/"})
+	events, _ := ParseNetscapeBookmarks(strings.NewReader(netscapeBookmarks))
+
+	report, err := ImportEvents(events, true)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if report.New != 1 || report.Duplicates != 2 || report.Invalid != 1 {
+		t.Errorf("dry run got %v new, %v duplicates, %v invalid, want 1, 2, 1",
+			report.New, report.Duplicates, report.Invalid)
+	}
+	if stored, _ := App.tdb.GetEvents(); len(stored) != 1 {
+		t.Errorf("dry run stored %v events", len(stored)-1)
+	}
+
+	if _, err = ImportEvents(events, false); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	e, err := App.tdb.GetEventByUrl("https://blog.golang.org/go1.16")
+	if err != nil {
+		t.Fatalf("imported event not found: %v", err)
+	}
+	if e.Tags != "go,release,Reading" || eventPendingDetails(e) {
+		t.Errorf("got imported event %#v", e)
+	}
+
+	report, _ = ImportEvents(events, false)
+	if report.New != 0 {
+		t.Errorf("reimport got %v new, want 0", report.New)
+	}
+}
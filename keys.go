@@ -0,0 +1,94 @@
+/*
+   Terminews is a terminal based (TUI) RSS feed manager.
+   Copyright (C) 2017  Alexandros Ntavelos, a[dot]ntavelos[at]gmail[dot]com
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+package main
+
+import (
+	c "github.com/jroimartin/gocui"
+)
+
+// keybinding binds a key, either a gocui.Key or a rune, of a view to a
+// handler. An empty view binds the key in all views.
+type keybinding struct {
+	view    string
+	key     interface{}
+	mod     c.Modifier
+	handler func(*c.Gui, *c.View) error
+}
+
+// keybindings returns the keybindings of the app. Their handlers report
+// their errors in the status bar, except Quit which ends the main loop.
+func keybindings() []keybinding {
+	kbs := []keybinding{
+		{"", c.KeyCtrlN, c.ModNone, AddSite},
+		{"", c.KeyDelete, c.ModNone, DeleteEntry},
+		{NEWS_VIEW, c.KeyCtrlB, c.ModNone, AddBookmark},
+		{"", c.KeyCtrlB, c.ModAlt, LoadBookmarks},
+		{"", c.KeyTab, c.ModNone, SwitchView},
+		{"", c.KeyArrowUp, c.ModNone, ListUp},
+		{"", c.KeyArrowDown, c.ModNone, ListDown},
+		{"", c.KeyPgup, c.ModNone, ListPgUp},
+		{"", c.KeyPgdn, c.ModNone, ListPgDown},
+		{"", c.KeyEnter, c.ModNone, OnEnter},
+		{"", c.KeyCtrlQ, c.ModNone, RemoveTopView},
+		{"", c.KeyCtrlF, c.ModNone, Find},
+		{NEWS_VIEW, c.KeyCtrlO, c.ModNone, LoadContent},
+		{NEWS_VIEW, c.KeyCtrlO, c.ModAlt, OpenBrowser},
+		{SITES_VIEW, c.KeyCtrlE, c.ModNone, EditSite},
+		{SITES_VIEW, c.KeyCtrlK, c.ModNone, MoveSiteUp},
+		{SITES_VIEW, c.KeyCtrlJ, c.ModNone, MoveSiteDown},
+		{SITES_VIEW, c.KeyCtrlT, c.ModNone, SortSites},
+		{SITES_VIEW, c.KeyCtrlD, c.ModNone, SiteHealth},
+		{SITES_VIEW, c.KeyCtrlR, c.ModNone, Rules},
+		{NEWS_VIEW, c.KeyCtrlR, c.ModNone, Rules},
+		{NEWS_VIEW, c.KeyCtrlX, c.ModNone, Export},
+		{NEWS_VIEW, c.KeyCtrlP, c.ModNone, PlayEnclosure},
+		{NEWS_VIEW, c.KeyCtrlP, c.ModAlt, TogglePlayed},
+		{NEWS_VIEW, c.KeyCtrlG, c.ModNone, DownloadEnclosure},
+		{CONTENT_VIEW, 'f', c.ModNone, ToggleFullContent},
+		{"", c.KeyCtrlH, c.ModNone, Help},
+		{"", c.KeyCtrlL, c.ModNone, ShowMessages},
+		{"", c.KeyCtrlZ, c.ModNone, Undo},
+	}
+	// the layout keys are bound to the main panes so that they can be typed
+	// in the prompts
+	for _, view := range []string{SITES_VIEW, NEWS_VIEW} {
+		kbs = append(kbs,
+			keybinding{view, '>', c.ModNone, GrowSites},
+			keybinding{view, '<', c.ModNone, ShrinkSites},
+			keybinding{view, '+', c.ModNone, GrowNews},
+			keybinding{view, '-', c.ModNone, ShrinkNews},
+			keybinding{view, 's', c.ModNone, ToggleSites},
+			keybinding{view, 't', c.ModNone, CycleTheme},
+		)
+	}
+	for i := range kbs {
+		kbs[i].handler = guard(kbs[i].handler)
+	}
+
+	return append(kbs, keybinding{"", c.KeyCtrlC, c.ModNone, Quit})
+}
+
+// setKeybindings sets the keybindings of the app to the GUI
+func setKeybindings(g *c.Gui) error {
+	for _, kb := range keybindings() {
+		if err := g.SetKeybinding(kb.view, kb.key, kb.mod, kb.handler); err != nil {
+			return err
+		}
+	}
+	return nil
+}
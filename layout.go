@@ -0,0 +1,185 @@
+/*
+   Terminews is a terminal based (TUI) RSS feed manager.
+   Copyright (C) 2017  Alexandros Ntavelos, a[dot]ntavelos[at]gmail[dot]com
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+package main
+
+import (
+	"encoding/json"
+	"log"
+
+	c "github.com/jroimartin/gocui"
+)
+
+// layoutState stores the layout of the panes across sessions
+const layoutState = "state.layout"
+
+// stackedWidth is the width of the terminal below which the panes are stacked
+const stackedWidth = 80
+
+// the bounds and the step of the sizes of the panes in percent
+const (
+	minPaneSize  = 10
+	maxPaneSize  = 90
+	paneSizeStep = 5
+)
+
+// paneLayout describes how the main panes and the content view are laid out
+type paneLayout struct {
+	// SitesSize is the width of the sites pane, or its height when the
+	// panes are stacked, in percent of the terminal
+	SitesSize int `json:"sites_size"`
+	// NewsSize is the height of the news pane in percent of the terminal, or
+	// of the space left by the sites pane when the panes are stacked
+	NewsSize    int  `json:"news_size"`
+	HideSites   bool `json:"hide_sites"`
+	FullContent bool `json:"full_content"`
+}
+
+// rect holds the coordinates of a view
+type rect struct {
+	x0, y0, x1, y1 int
+}
+
+func defaultLayout() paneLayout {
+	return paneLayout{SitesSize: 30, NewsSize: 70}
+}
+
+// fit makes sure that the view can be created on tiny terminals
+func (r rect) fit() rect {
+	if r.x1 <= r.x0 {
+		r.x1 = r.x0 + 1
+	}
+	if r.y1 <= r.y0 {
+		r.y1 = r.y0 + 1
+	}
+	return r
+}
+
+// panes calculates the rects of the sites, the news and the summary views for
+// the given terminal size. The last line is left to the status bar. A hidden
+// sites pane is moved out of the screen.
+func (l paneLayout) panes(tw, th int) (sites, news, summary rect) {
+	bottom := th - 2
+	if tw < stackedWidth {
+		sh := (th * l.SitesSize) / 100
+		top := sh + 1
+		sites = rect{0, 0, tw - 1, sh}
+		if l.HideSites {
+			sites, top = rect{0, -sh - 1, tw - 1, -1}, 0
+		}
+		nh := top + ((bottom-top)*l.NewsSize)/100
+		news = rect{0, top, tw - 1, nh}
+		summary = rect{0, nh + 1, tw - 1, bottom}
+		return sites.fit(), news.fit(), summary.fit()
+	}
+
+	sw := (tw * l.SitesSize) / 100
+	left := sw + 1
+	sites = rect{0, 0, sw, bottom}
+	if l.HideSites {
+		sites, left = rect{-sw - 1, 0, -1, bottom}, 0
+	}
+	nh := (th * l.NewsSize) / 100
+	news = rect{left, 0, tw - 1, nh}
+	summary = rect{left, nh + 1, tw - 1, bottom}
+	return sites.fit(), news.fit(), summary.fit()
+}
+
+// content calculates the rect of the content view for the given terminal size
+func (l paneLayout) content(tw, th int) rect {
+	if l.FullContent {
+		return rect{0, 0, tw - 1, th - 2}.fit()
+	}
+	return rect{tw / 8, th / 8, (tw * 7) / 8, (th * 7) / 8}.fit()
+}
+
+// setView creates or updates a view with the given rect
+func setView(g *c.Gui, name string, r rect) (*c.View, error) {
+	return g.SetView(name, r.x0, r.y0, r.x1, r.y1)
+}
+
+// loadLayout returns the stored layout of the panes
+func loadLayout() paneLayout {
+	l := defaultLayout()
+	data, err := App.tdb.GetSetting(layoutState)
+	if err != nil {
+		return l
+	}
+	if err = json.Unmarshal([]byte(data), &l); err != nil {
+		log.Println("Error on loading the layout", err)
+		return defaultLayout()
+	}
+	l.SitesSize = clampPaneSize(l.SitesSize)
+	l.NewsSize = clampPaneSize(l.NewsSize)
+	return l
+}
+
+// applyLayout stores the layout and redraws the panes accordingly
+func applyLayout(g *c.Gui) error {
+	App.relayout = true
+	data, err := json.Marshal(App.Layout)
+	if err != nil {
+		return err
+	}
+	return App.tdb.SetSetting(layoutState, string(data))
+}
+
+func clampPaneSize(size int) int {
+	if size < minPaneSize {
+		return minPaneSize
+	}
+	if size > maxPaneSize {
+		return maxPaneSize
+	}
+	return size
+}
+
+func GrowSites(g *c.Gui, v *c.View) error {
+	App.Layout.SitesSize = clampPaneSize(App.Layout.SitesSize + paneSizeStep)
+	return applyLayout(g)
+}
+
+func ShrinkSites(g *c.Gui, v *c.View) error {
+	App.Layout.SitesSize = clampPaneSize(App.Layout.SitesSize - paneSizeStep)
+	return applyLayout(g)
+}
+
+func GrowNews(g *c.Gui, v *c.View) error {
+	App.Layout.NewsSize = clampPaneSize(App.Layout.NewsSize + paneSizeStep)
+	return applyLayout(g)
+}
+
+func ShrinkNews(g *c.Gui, v *c.View) error {
+	App.Layout.NewsSize = clampPaneSize(App.Layout.NewsSize - paneSizeStep)
+	return applyLayout(g)
+}
+
+func ToggleSites(g *c.Gui, v *c.View) error {
+	App.Layout.HideSites = !App.Layout.HideSites
+	if App.Layout.HideSites && v.Name() == SITES_VIEW {
+		App.SitesList.Unfocus()
+		if err := App.NewsList.Focus(g); err != nil {
+			return err
+		}
+	}
+	return applyLayout(g)
+}
+
+func ToggleFullContent(g *c.Gui, v *c.View) error {
+	App.Layout.FullContent = !App.Layout.FullContent
+	return applyLayout(g)
+}
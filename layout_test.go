@@ -0,0 +1,66 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestPanes(t *testing.T) {
+	tests := []struct {
+		name                 string
+		layout               paneLayout
+		tw, th               int
+		sites, news, summary rect
+	}{
+		{"default", defaultLayout(), 120, 35, rect{0, 0, 36, 33}, rect{37, 0, 119, 24}, rect{37, 25, 119, 33}},
+		{"resized", paneLayout{SitesSize: 50, NewsSize: 40}, 120, 35, rect{0, 0, 60, 33}, rect{61, 0, 119, 14}, rect{61, 15, 119, 33}},
+		{"hidden sites", paneLayout{SitesSize: 30, NewsSize: 70, HideSites: true}, 120, 35, rect{-37, 0, -1, 33}, rect{0, 0, 119, 24}, rect{0, 25, 119, 33}},
+		{"stacked", defaultLayout(), 70, 35, rect{0, 0, 69, 10}, rect{0, 11, 69, 26}, rect{0, 27, 69, 33}},
+		{"stacked hidden sites", paneLayout{SitesSize: 30, NewsSize: 70, HideSites: true}, 70, 35, rect{0, -11, 69, -1}, rect{0, 0, 69, 23}, rect{0, 24, 69, 33}},
+		{"tiny", defaultLayout(), 3, 3, rect{0, 0, 2, 1}, rect{0, 1, 2, 2}, rect{0, 2, 2, 3}},
+	}
+	for _, test := range tests {
+		sites, news, summary := test.layout.panes(test.tw, test.th)
+		if sites != test.sites || news != test.news || summary != test.summary {
+			t.Errorf("%v: got %v %v %v, want %v %v %v", test.name,
+				sites, news, summary, test.sites, test.news, test.summary)
+		}
+	}
+}
+
+func TestContent(t *testing.T) {
+	l := defaultLayout()
+	if r := l.content(120, 35); r != (rect{15, 4, 105, 30}) {
+		t.Errorf("got %v for the content window", r)
+	}
+	l.FullContent = true
+	if r := l.content(120, 35); r != (rect{0, 0, 119, 33}) {
+		t.Errorf("got %v for the full screen content", r)
+	}
+}
+
+func TestLoadLayout(t *testing.T) {
+	newTestApp(t)
+	newTestDB(t)
+
+	if l := loadLayout(); l != defaultLayout() {
+		t.Errorf("got layout %+v from a new DB", l)
+	}
+
+	App.Layout = paneLayout{SitesSize: 40, NewsSize: 55, HideSites: true}
+	if err := applyLayout(nil); err != nil {
+		t.Fatal(err)
+	}
+	if l := loadLayout(); l != App.Layout {
+		t.Errorf("got layout %+v, want %+v", l, App.Layout)
+	}
+
+	App.tdb.SetSetting(layoutState, `{"sites_size": 5, "news_size": 200}`)
+	if l := loadLayout(); l.SitesSize != minPaneSize || l.NewsSize != maxPaneSize {
+		t.Errorf("got pane sizes %v/%v out of bounds", l.SitesSize, l.NewsSize)
+	}
+
+	App.tdb.SetSetting(layoutState, "{")
+	if l := loadLayout(); l != defaultLayout() {
+		t.Errorf("got layout %+v from invalid data", l)
+	}
+}
@@ -18,74 +18,70 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"log"
 	"os"
-	"os/user"
 	"path"
 
 	"github.com/antavelos/terminews/db"
-	"github.com/fatih/color"
 	c "github.com/jroimartin/gocui"
 )
 
 const (
-	SITES_VIEW   = "rssreaders"
-	NEWS_VIEW    = "news"
-	SUMMARY_VIEW = "summary"
-	PROMPT_VIEW  = "prompt"
-	CONTENT_VIEW = "content"
-	HELP_VIEW    = "help"
+	SITES_VIEW     = "rssreaders"
+	NEWS_VIEW      = "news"
+	SUMMARY_VIEW   = "summary"
+	PROMPT_VIEW    = "prompt"
+	CONTENT_VIEW   = "content"
+	HELP_VIEW      = "help"
+	SITE_EDIT_VIEW = "siteedit"
+	FEEDS_VIEW     = "feeds"
+	HEALTH_VIEW    = "health"
+	RULES_VIEW     = "rules"
+	STATUS_VIEW    = "status"
+	MESSAGES_VIEW  = "messages"
 
 	appVersion = "1.2.1"
 )
 
-var (
-	tdb              *db.TDB
-	SitesList        *List
-	NewsList         *List
-	ContentList      *List
-	Summary          *c.View
-	CurrentContent   []string
-	CurrentBookmarks []db.Event
-	curW             int
-	curH             int
-	Bold             *color.Color
-)
-
-// relSize calculates the  sizes of the sites view width
-// and the news view height in relation to the current terminal size
-func relSize(g *c.Gui) (int, int) {
-	tw, th := g.Size()
-
-	return (tw * 3) / 10, (th * 70) / 100
-}
-
 // The layout handler calculates all sizes depending
 // on the current terminal size.
 func layout(g *c.Gui) error {
 	// Get the current terminal size.
-	tw, th := g.Size()
+	tw, th := App.Driver.size(g)
 
-	// Get the relative size of the views
-	rw, rh := relSize(g)
+	// Get the rects of the panes according to the layout
+	sites, news, summary := App.Layout.panes(tw, th)
 
-	_, err := g.SetView(SITES_VIEW, 0, 0, rw, th-1)
+	_, err := setView(g, SITES_VIEW, sites)
 	if err != nil {
-		log.Fatal("Cannot update sites view", err)
+		return fmt.Errorf("Cannot update sites view: %v", err)
 	}
 
-	_, err = g.SetView(NEWS_VIEW, rw+1, 0, tw-1, rh)
+	_, err = setView(g, NEWS_VIEW, news)
 	if err != nil {
-		log.Fatal("Cannot update news view", err)
+		return fmt.Errorf("Cannot update news view: %v", err)
 	}
 
-	_, err = g.SetView(SUMMARY_VIEW, rw+1, rh+1, tw-1, th-1)
+	_, err = setView(g, SUMMARY_VIEW, summary)
 	if err != nil {
-		log.Fatal("Cannot update Summary view.", err)
+		return fmt.Errorf("Cannot update Summary view: %v", err)
 	}
 	UpdateSummary()
 
+	// the status bar is the last line of the terminal
+	v, err := g.SetView(STATUS_VIEW, -1, th-2, tw, th)
+	if err != nil {
+		if err != c.ErrUnknownView {
+			return fmt.Errorf("Cannot update status bar: %v", err)
+		}
+		v.Frame = false
+		if err = drawStatus(g); err != nil {
+			return err
+		}
+	}
+
 	if _, err = g.View(PROMPT_VIEW); err == nil {
 		_, err = g.SetView(PROMPT_VIEW, tw/6, (th/2)-1, (tw*5)/6, (th/2)+1)
 		if err != nil && err != c.ErrUnknownView {
@@ -94,198 +90,232 @@ func layout(g *c.Gui) error {
 	}
 
 	if _, err = g.View(CONTENT_VIEW); err == nil {
-		_, err = g.SetView(CONTENT_VIEW, tw/8, th/8, (tw*7)/8, (th*7)/8)
+		_, err = setView(g, CONTENT_VIEW, App.Layout.content(tw, th))
 		if err != nil && err != c.ErrUnknownView {
 			return err
 		}
 	}
 
-	if _, err = g.View(HELP_VIEW); err == nil {
-		_, err = g.SetView(HELP_VIEW, tw/6, th/5, (tw*5)/6, (th*4)/5)
+	if _, err = g.View(SITE_EDIT_VIEW); err == nil {
+		_, err = g.SetView(SITE_EDIT_VIEW, tw/6, th/4, (tw*5)/6, (th*3)/4)
 		if err != nil && err != c.ErrUnknownView {
 			return err
 		}
 	}
 
-	if curW != tw || curH != th {
-		SitesList.ResetPages()
-		SitesList.Draw()
-		NewsList.ResetPages()
-		NewsList.Draw()
-		if ContentList != nil {
-			ContentList.Reset()
-			UpdateContent(g, CurrentContent)
+	if _, err = g.View(FEEDS_VIEW); err == nil {
+		_, err = g.SetView(FEEDS_VIEW, tw/6, th/4, (tw*5)/6, (th*3)/4)
+		if err != nil && err != c.ErrUnknownView {
+			return err
 		}
-		curW = tw
-		curH = th
 	}
 
-	return nil
-}
-
-// getappDir creates if not exists the app directory where the sqlite db file
-// as well as the log file will be stored. In case of failure the current dir
-// will be used.
-func getAppDir() (string, error) {
-	usr, _ := user.Current()
-	dir := path.Join(usr.HomeDir, ".terminews")
-	if _, err := os.Stat(dir); err != nil {
-		if os.IsNotExist(err) {
-			if oserr := os.Mkdir(dir, 0700); oserr != nil {
-				return "", oserr
-			}
-		} else {
-			return "", err
+	if _, err = g.View(RULES_VIEW); err == nil {
+		_, err = g.SetView(RULES_VIEW, tw/6, th/4, (tw*5)/6, (th*3)/4)
+		if err != nil && err != c.ErrUnknownView {
+			return err
 		}
 	}
-	return dir, nil
-}
 
-func main() {
-	// If app is called with --version then display version flag & exit
-	if len(os.Args) == 2 {
-		if os.Args[1] == "--version" {
-			fmt.Println(appVersion)
-			return
+	if _, err = g.View(HEALTH_VIEW); err == nil {
+		_, err = g.SetView(HEALTH_VIEW, tw/8, th/8, (tw*7)/8, (th*7)/8)
+		if err != nil && err != c.ErrUnknownView {
+			return err
 		}
 	}
 
-	var v *c.View
-	var err error
-
-	Bold = color.New(color.Bold)
-
-	appDir, err := getAppDir()
-	if err != nil {
-		panic("Could not set up app directory.")
+	if _, err = g.View(MESSAGES_VIEW); err == nil {
+		_, err = g.SetView(MESSAGES_VIEW, tw/8, th/8, (tw*7)/8, (th*7)/8)
+		if err != nil && err != c.ErrUnknownView {
+			return err
+		}
 	}
 
-	// Setup logging
-	logfile := path.Join(appDir, "terminews.log")
-	f, err := os.OpenFile(logfile, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0700)
-	if err != nil {
-		log.Fatal("Failed to open logfile", err)
+	if _, err = g.View(HELP_VIEW); err == nil {
+		_, err = g.SetView(HELP_VIEW, tw/6, th/5, (tw*5)/6, (th*4)/5)
+		if err != nil && err != c.ErrUnknownView {
+			return err
+		}
 	}
-	defer f.Close()
-	log.SetOutput(f)
 
-	// Init DB
-	if tdb, err = db.InitDB(appDir); err != nil {
-		log.Fatal("Failed to initialize DB", err)
+	if App.curW != tw || App.curH != th || App.relayout {
+		App.SitesList.Redraw()
+		App.NewsList.Redraw()
+		if App.MessagesList != nil {
+			App.MessagesList.Redraw()
+		}
+		if App.ContentList != nil {
+			App.ContentList.Reset()
+			UpdateContent(g, App.CurrentContent)
+		}
+		App.curW = tw
+		App.curH = th
+		App.relayout = false
 	}
-	defer tdb.Close()
 
-	// Create a new GUI.
-	g, err := c.NewGui(c.OutputNormal)
-	if err != nil {
-		log.Fatal("Failed to initialize GUI", err)
-	}
-	defer g.Close()
+	return nil
+}
 
+// setupGui creates the initial views of the GUI and sets its keybindings
+func setupGui(g *c.Gui) error {
 	// some basic configuration
-	g.SelFgColor = c.ColorGreen | c.AttrBold
-	g.BgColor = c.ColorDefault
+	applyTheme(g, loadTheme())
 	g.Highlight = true
 
 	// setup the layout
 	g.SetManagerFunc(layout)
 
 	// the current actual size of the terminal
-	curW, curH = g.Size()
-
-	// rw is the relative width of the sites view
-	// rh is the relative height of the news view
-	rw, rh := relSize(g)
+	App.curW, App.curH = App.Driver.size(g)
 
 	// Setup the initial layout
+	App.Layout = loadLayout()
+	sites, news, summary := App.Layout.panes(App.curW, App.curH)
+
 	// Sites List
-	v, err = g.SetView(SITES_VIEW, 0, 0, rw, curH-1)
+	v, err := setView(g, SITES_VIEW, sites)
 	if err != nil && err != c.ErrUnknownView {
-		log.Fatal("Failed to create sites list:", err)
+		return fmt.Errorf("Failed to create sites list: %v", err)
 	}
-	SitesList = CreateList(v, true)
-	SitesList.Focus(g)
+	App.SitesList = CreateList(v, true)
+	App.SitesList.format = formatSite
+	App.SitesList.Focus(g)
+	App.SitesSort = loadSitesSort()
 
 	// it loads the existing sites if any at the beginning
-	g.Update(func(g *c.Gui) error {
+	update(g, func(g *c.Gui) error {
 		if err := LoadSites(); err != nil {
-			log.Fatal("Error while loading sites", err)
+			return err
 		}
 		log.Print("Loaded initial sites")
-		return nil
+		return restoreSession(g)
 	})
 
 	// News list
-	v, err = g.SetView(NEWS_VIEW, rw+1, 0, curW-1, rh)
+	v, err = setView(g, NEWS_VIEW, news)
 	if err != nil && err != c.ErrUnknownView {
-		log.Fatal(" Failed to create news list:", err)
+		return fmt.Errorf("Failed to create news list: %v", err)
 	}
-	NewsList = CreateList(v, true)
-	NewsList.SetTitle("No news yet...")
+	App.NewsList = CreateList(v, true)
+	App.NewsList.format = formatEvent
+	App.NewsList.SetTitle("No news yet...")
 
 	// Summary view
-	Summary, err = g.SetView(SUMMARY_VIEW, rw+1, rh+1, curW-1, curH-1)
+	App.Summary, err = setView(g, SUMMARY_VIEW, summary)
 	if err != nil && err != c.ErrUnknownView {
-		log.Fatal("Failed to create Summary view:", err)
+		return fmt.Errorf("Failed to create Summary view: %v", err)
 	}
-	Summary.Title = " Summary "
-	Summary.Wrap = true
+	App.Summary.Title = " Summary "
+	App.Summary.Wrap = true
 
 	// preload thebookmarks
-	CurrentBookmarks, err = tdb.GetEvents()
+	App.CurrentBookmarks, err = App.tdb.GetEvents()
 	if err != nil {
 		log.Println("Error on load bookmarks", err)
 	}
 
 	// setup the keybindings of the app
-	if err = g.SetKeybinding("", c.KeyCtrlN, c.ModNone, AddSite); err != nil {
-		log.Fatal("Failed to set keybindings")
-	}
-	if err = g.SetKeybinding("", c.KeyDelete, c.ModNone, DeleteEntry); err != nil {
-		log.Fatal("Failed to set keybindings")
-	}
-	if err = g.SetKeybinding(NEWS_VIEW, c.KeyCtrlB, c.ModNone, AddBookmark); err != nil {
-		log.Fatal("Failed to set keybindings")
-	}
-	if err = g.SetKeybinding("", c.KeyCtrlC, c.ModNone, Quit); err != nil {
-		log.Fatal("Failed to set keybindings")
+	return setKeybindings(g)
+}
+
+func main() {
+	opts, args, err := parseOptions(os.Args[1:])
+	if err == flag.ErrHelp {
+		return
 	}
-	if err = g.SetKeybinding("", c.KeyCtrlB, c.ModAlt, LoadBookmarks); err != nil {
-		log.Fatal("Failed to set keybindings")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "terminews: %v\n", err)
+		os.Exit(2)
 	}
-	if err = g.SetKeybinding("", c.KeyTab, c.ModNone, SwitchView); err != nil {
-		log.Fatal("Failed to set keybindings")
+
+	// If app is called with --version then display version flag & exit
+	if opts.version {
+		fmt.Println(appVersion)
+		return
+	}
+
+	// If app is called with a command then make sure it exists before
+	// anything gets initialized
+	var cmd command
+	if len(args) > 0 {
+		var ok bool
+		if cmd, ok = findCommand(args[0]); !ok {
+			printUsage()
+			os.Exit(2)
+		}
 	}
-	if err = g.SetKeybinding("", c.KeyArrowUp, c.ModNone, ListUp); err != nil {
-		log.Fatal("Failed to set keybindings")
+
+	setTextColors(App.Theme)
+
+	appDir, err := appDataDir(opts.profile)
+	if err == nil {
+		err = os.MkdirAll(appDir, 0700)
 	}
-	if err = g.SetKeybinding("", c.KeyArrowDown, c.ModNone, ListDown); err != nil {
-		log.Fatal("Failed to set keybindings")
+	if err != nil {
+		panic(fmt.Sprintf("Could not set up app directory: %v", err))
 	}
-	if err = g.SetKeybinding("", c.KeyPgup, c.ModNone, ListPgUp); err != nil {
-		log.Fatal("Failed to set keybindings")
+
+	// Setup logging
+	logfile := path.Join(appDir, "terminews.log")
+	f, err := os.OpenFile(logfile, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0700)
+	if err != nil {
+		log.Fatal("Failed to open logfile", err)
 	}
-	if err = g.SetKeybinding("", c.KeyPgdn, c.ModNone, ListPgDown); err != nil {
-		log.Fatal("Failed to set keybindings")
+	defer f.Close()
+	log.SetOutput(f)
+
+	// Init DB
+	dbPath := path.Join(appDir, "terminews.db")
+	if len(opts.dbPath) > 0 {
+		dbPath = expandPath(opts.dbPath)
 	}
-	if err = g.SetKeybinding("", c.KeyEnter, c.ModNone, OnEnter); err != nil {
-		log.Fatal("Failed to set keybindings")
+	if App.tdb, err = db.Open(dbPath); err != nil {
+		log.Fatal("Failed to initialize DB", err)
 	}
-	if err = g.SetKeybinding("", c.KeyCtrlQ, c.ModNone, RemoveTopView); err != nil {
-		log.Fatal("Failed to set keybindings")
+	defer App.tdb.Close()
+	if err = loadCanonicalLinks(); err != nil {
+		log.Println("Error on loadCanonicalLinks", err)
 	}
-	if err = g.SetKeybinding("", c.KeyCtrlF, c.ModNone, Find); err != nil {
-		log.Fatal("Failed to set keybindings")
+
+	// Setup the fetching of the feeds and the articles
+	if App.Web, err = loadFetcher(); err != nil {
+		fmt.Fprintf(os.Stderr, "terminews: failed to set up the fetching: %v\n", err)
+		App.tdb.Close()
+		os.Exit(1)
 	}
-	if err = g.SetKeybinding(NEWS_VIEW, c.KeyCtrlO, c.ModNone, LoadContent); err != nil {
-		log.Fatal("Failed to set keybindings")
+
+	// Run the requested command instead of the GUI
+	if len(cmd.name) > 0 {
+		err = cmd.run(args[1:])
+		// let the notifications of the new news complete
+		hooks.Wait()
+		if err != nil && err != flag.ErrHelp {
+			fmt.Fprintf(os.Stderr, "terminews %v: %v\n", cmd.name, err)
+			App.tdb.Close()
+			os.Exit(1)
+		}
+		return
 	}
-	if err = g.SetKeybinding(NEWS_VIEW, c.KeyCtrlO, c.ModAlt, OpenBrowser); err != nil {
-		log.Fatal("Failed to set keybindings")
+
+	// prune the stored news and compact the DB, then periodically
+	startMaintenance()
+
+	// Create a new GUI.
+	mode := outputMode()
+	App.Colors256 = mode == c.Output256
+	g, err := c.NewGui(mode)
+	if err != nil {
+		log.Fatal("Failed to initialize GUI", err)
 	}
-	if err = g.SetKeybinding("", c.KeyCtrlH, c.ModNone, Help); err != nil {
-		log.Fatal("Failed to set keybindings")
+	defer g.Close()
+
+	if err = setupGui(g); err != nil {
+		log.Fatal("Failed to set up the GUI: ", err)
 	}
+
+	// refresh periodically the displayed news
+	go autoRefresh(g)
+
 	// run the mainloop
 	if err = g.MainLoop(); err != nil && err != c.ErrQuit {
 		log.Println("terminews exited unexpectedly: ", err)
@@ -0,0 +1,196 @@
+/*
+   Terminews is a terminal based (TUI) RSS feed manager.
+   Copyright (C) 2017  Alexandros Ntavelos, a[dot]ntavelos[at]gmail[dot]com
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/antavelos/terminews/db"
+)
+
+// lastVacuumState stores the time of the last compaction of the DB file
+const lastVacuumState = "state.last_vacuum"
+
+// retentionPolicy returns the configured retention policy of the stored news
+func retentionPolicy() db.RetentionPolicy {
+	return db.RetentionPolicy{
+		MaxAge:          time.Duration(getIntSetting("retention.max_age_days")) * 24 * time.Hour,
+		MaxItemsPerSite: getIntSetting("retention.max_items_per_site"),
+	}
+}
+
+// vacuumDue checks whether the configured interval since the last compaction
+// of the DB file has elapsed
+func vacuumDue(now time.Time) bool {
+	days := getIntSetting("maintenance.vacuum_interval_days")
+	if days == 0 {
+		return false
+	}
+	last, err := App.tdb.GetSetting(lastVacuumState)
+	if err != nil {
+		return true
+	}
+	t, err := time.Parse(time.RFC3339, last)
+	return err != nil || now.Sub(t) >= time.Duration(days)*24*time.Hour
+}
+
+// vacuum compacts the DB file and remembers when it happened
+func vacuum() error {
+	if err := App.tdb.Vacuum(); err != nil {
+		return err
+	}
+	return App.tdb.SetSetting(lastVacuumState, time.Now().Format(time.RFC3339))
+}
+
+// runMaintenance prunes the stored news according to the retention policy and
+// compacts the DB file when due
+func runMaintenance() error {
+	pruned, err := App.tdb.PruneItems(retentionPolicy())
+	if err != nil {
+		return err
+	}
+	log.Printf("Pruned %v stored news", pruned)
+
+	purged, err := App.tdb.PurgeDeleted(time.Now().Add(-undoKeep()))
+	if err != nil {
+		return err
+	}
+	log.Printf("Purged %v deleted sites and bookmarks", purged)
+
+	if vacuumDue(time.Now()) {
+		if err := vacuum(); err != nil {
+			return err
+		}
+		log.Print("Vacuumed the DB")
+	}
+	return nil
+}
+
+// startMaintenance runs the maintenance before the GUI or the API reads the
+// DB, the VACUUM needing an exclusive access to it, and then periodically in
+// the background
+func startMaintenance() {
+	if err := runMaintenance(); err != nil {
+		log.Println("Error on runMaintenance", err)
+	}
+	go maintenanceLoop()
+}
+
+// maintenanceLoop runs the maintenance periodically as configured
+func maintenanceLoop() {
+	hours := getIntSetting("retention.prune_interval_hours")
+	if hours == 0 {
+		return
+	}
+	for range time.Tick(time.Duration(hours) * time.Hour) {
+		if err := runMaintenance(); err != nil {
+			log.Println("Error on runMaintenance", err)
+		}
+	}
+}
+
+func dbCommand(args []string) error {
+	fs := flag.NewFlagSet("db", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: terminews db [stats | prune | vacuum]\n\n"+
+			"  stats   reports the size of the DB and the storage used per site\n"+
+			"  prune   removes the stored news according to the retention settings and\n"+
+			"          purges the deleted sites and bookmarks which can no longer be restored\n"+
+			"  vacuum  compacts the DB file\n")
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errors.New("exactly one action is expected")
+	}
+
+	switch fs.Arg(0) {
+	case "stats":
+		st, err := App.tdb.GetStats()
+		if err != nil {
+			return err
+		}
+		return printStats(st)
+	case "prune":
+		pruned, err := App.tdb.PruneItems(retentionPolicy())
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Pruned %v stored news\n", pruned)
+		purged, err := App.tdb.PurgeDeleted(time.Now().Add(-undoKeep()))
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Purged %v deleted sites and bookmarks\n", purged)
+	case "vacuum":
+		if err := vacuum(); err != nil {
+			return err
+		}
+		st, err := App.tdb.GetStats()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Database compacted to %v\n", formatBytes(st.Size))
+	default:
+		fs.Usage()
+		return fmt.Errorf("unknown action '%v'", fs.Arg(0))
+	}
+
+	return nil
+}
+
+func printStats(st db.Stats) error {
+	fmt.Printf("Database size: %v (%v reclaimable by vacuum)\n", formatBytes(st.Size), formatBytes(st.Free))
+	fmt.Printf("Bookmarks: %v\n\n", st.Bookmarks)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "SITE\tNEWS\tSIZE\tFETCHES")
+	var (
+		items int
+		bytes int64
+	)
+	for _, s := range st.Sites {
+		fmt.Fprintf(w, "%v\t%v\t%v\t%v\n", s.Name, s.Items, formatBytes(s.Bytes), s.Fetches)
+		items += s.Items
+		bytes += s.Bytes
+	}
+	fmt.Fprintf(w, "Total\t%v\t%v\t\n", items, formatBytes(bytes))
+	return w.Flush()
+}
+
+// formatBytes formats a size in a human readable way
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%v B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
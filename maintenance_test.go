@@ -0,0 +1,54 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFormatBytes(t *testing.T) {
+	tests := map[int64]string{
+		0:           "0 B",
+		1023:        "1023 B",
+		1536:        "1.5 KiB",
+		3 * 1 << 20: "3.0 MiB",
+	}
+	for n, want := range tests {
+		if got := formatBytes(n); got != want {
+			t.Errorf("formatBytes(%v) = %v, want %v", n, got, want)
+		}
+	}
+}
+
+func TestRetentionSettings(t *testing.T) {
+	newTestDB(t)
+
+	if p := retentionPolicy(); p.MaxAge != 30*24*time.Hour || p.MaxItemsPerSite != 500 {
+		t.Errorf("Found default policy %+v, want 30 days and 500 items", p)
+	}
+	if err := configCommand([]string{"set", "retention.max_age_days", "-1"}); err == nil {
+		t.Errorf("Expected an error for a negative max age")
+	}
+	if err := configCommand([]string{"set", "retention.unknown", "1"}); err == nil {
+		t.Errorf("Expected an error for an unknown setting")
+	}
+	if err := configCommand([]string{"set", "retention.max_age_days", "7"}); err != nil {
+		t.Fatal(err)
+	}
+	if p := retentionPolicy(); p.MaxAge != 7*24*time.Hour {
+		t.Errorf("Found max age %v, want 7 days", p.MaxAge)
+	}
+
+	now := time.Now()
+	if !vacuumDue(now) {
+		t.Errorf("Expected a vacuum to be due when none ever happened")
+	}
+	if err := runMaintenance(); err != nil {
+		t.Fatal(err)
+	}
+	if vacuumDue(now.Add(24 * time.Hour)) {
+		t.Errorf("Expected no vacuum to be due a day after the last one")
+	}
+	if !vacuumDue(now.Add(8 * 24 * time.Hour)) {
+		t.Errorf("Expected a vacuum to be due 8 days after the last one")
+	}
+}
@@ -0,0 +1,210 @@
+/*
+   Terminews is a terminal based (TUI) RSS feed manager.
+   Copyright (C) 2017  Alexandros Ntavelos, a[dot]ntavelos[at]gmail[dot]com
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/antavelos/terminews/db"
+	"github.com/mmcdole/gofeed"
+)
+
+// enclosures returns the media files attached to a feed item
+func enclosures(item *gofeed.Item) []db.Enclosure {
+	var result []db.Enclosure
+	for _, enc := range item.Enclosures {
+		if enc == nil || len(enc.URL) == 0 {
+			continue
+		}
+		length, _ := strconv.ParseInt(strings.TrimSpace(enc.Length), 10, 64)
+		result = append(result, db.Enclosure{Url: enc.URL, Type: enc.Type, Length: length})
+	}
+	if len(result) > 0 && item.ITunesExt != nil {
+		result[0].Duration = formatDuration(item.ITunesExt.Duration)
+	}
+	return result
+}
+
+// formatDuration normalizes the duration of an episode given either in
+// seconds or as [[HH:]MM:]SS to H:MM:SS or M:SS
+func formatDuration(d string) string {
+	d = strings.TrimSpace(d)
+	if len(d) == 0 {
+		return ""
+	}
+	secs := 0
+	for _, part := range strings.Split(d, ":") {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return d
+		}
+		secs = secs*60 + n
+	}
+	if secs >= 3600 {
+		return fmt.Sprintf("%d:%02d:%02d", secs/3600, secs/60%60, secs%60)
+	}
+	return fmt.Sprintf("%d:%02d", secs/60, secs%60)
+}
+
+// enclosureLines returns the lines describing the enclosures of an event in
+// the summary
+func enclosureLines(encs []db.Enclosure) []string {
+	var lines []string
+	for i, enc := range encs {
+		details := []string{}
+		if len(enc.Type) > 0 {
+			details = append(details, enc.Type)
+		}
+		if enc.Length > 0 {
+			details = append(details, formatBytes(enc.Length))
+		}
+		if len(enc.Duration) > 0 {
+			details = append(details, enc.Duration)
+		}
+		state := "unplayed"
+		if enc.Played {
+			state = "played"
+		}
+		if len(enc.Path) > 0 {
+			state += ", downloaded to " + enc.Path
+		}
+		details = append(details, state)
+		lines = append(lines, fmt.Sprintf("%v. %v (%v)", i+1, enc.Url, strings.Join(details, ", ")))
+	}
+	return lines
+}
+
+// playEnclosure streams an enclosure, or plays its downloaded file, with the
+// configured player in the background. Marking it played is up to the caller.
+func playEnclosure(enc db.Enclosure) error {
+	args := strings.Fields(getSetting("player.command"))
+	if len(args) == 0 {
+		return fmt.Errorf("no player command configured")
+	}
+	source := enc.Url
+	if len(enc.Path) > 0 {
+		if _, err := os.Stat(enc.Path); err == nil {
+			source = enc.Path
+		}
+	}
+
+	cmd := exec.Command(args[0], append(args[1:], source)...)
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	// reap the player once it exits
+	go cmd.Wait()
+
+	return nil
+}
+
+// enclosureFileName derives the name of the downloaded file of an enclosure
+// from its URL
+func enclosureFileName(enc db.Enclosure) string {
+	name := "enclosure"
+	if u, err := url.Parse(enc.Url); err == nil {
+		if base := path.Base(u.Path); base != "/" && base != "." {
+			name = base
+		}
+	}
+	return name
+}
+
+// downloadPath returns the file to download an enclosure to in the given
+// directory: the one it was downloaded to before, otherwise one named after
+// it unless another download uses that name, e.g. "episode (2).mp3"
+func downloadPath(dir string, enc db.Enclosure) string {
+	if len(enc.Path) > 0 && filepath.Dir(enc.Path) == dir {
+		return enc.Path
+	}
+	used := func(p string) bool {
+		_, err := os.Stat(p)
+		_, perr := os.Stat(p + ".part")
+		return err == nil || perr == nil
+	}
+
+	name := enclosureFileName(enc)
+	ext := filepath.Ext(name)
+	dest := filepath.Join(dir, name)
+	for i := 2; used(dest); i++ {
+		dest = filepath.Join(dir, fmt.Sprintf("%v (%v)%v", strings.TrimSuffix(name, ext), i, ext))
+	}
+	return dest
+}
+
+// progressWriter reports the number of bytes written so far
+type progressWriter struct {
+	written  int64
+	progress func(written int64)
+}
+
+func (w *progressWriter) Write(p []byte) (int, error) {
+	w.written += int64(len(p))
+	w.progress(w.written)
+	return len(p), nil
+}
+
+// downloadEnclosure downloads an enclosure in the configured download
+// directory reporting the progress of the download. The path of the file is
+// returned.
+func downloadEnclosure(site db.Site, enc db.Enclosure, progress func(written, total int64)) (string, error) {
+	dir := expandPath(getSetting("download.directory"))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+
+	resp, err := App.Web.Download(site, enc.Url)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	total := resp.ContentLength
+	if total <= 0 {
+		total = enc.Length
+	}
+
+	dest := downloadPath(dir, enc)
+	// a partially downloaded file never replaces a complete one
+	f, err := os.Create(dest + ".part")
+	if err != nil {
+		return "", err
+	}
+	pw := &progressWriter{progress: func(written int64) { progress(written, total) }}
+	if _, err = io.Copy(io.MultiWriter(f, pw), resp.Body); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return "", err
+	}
+	if err = f.Close(); err != nil {
+		return "", err
+	}
+	if err = os.Rename(f.Name(), dest); err != nil {
+		return "", err
+	}
+
+	return dest, App.tdb.SetEnclosurePath(enc.Url, dest)
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/antavelos/terminews/db"
+)
+
+const testPodcast = `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0" xmlns:itunes="http://www.itunes.com/dtds/podcast-1.0.dtd">
+<channel>
+<title>Test podcast</title>
+<item>
+<title>Episode 1</title>
+<itunes:summary>The first episode</itunes:summary>
+<itunes:duration>3723</itunes:duration>
+<enclosure url="%v/episode1.mp3" length="1048576" type="audio/mpeg"/>
+</item>
+</channel>
+</rss>`
+
+func TestDownloadPodcast(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, testPodcast, "https://cdn.example.org")
+	}))
+	defer ts.Close()
+
+	events, _, err := DownloadEvents(db.Site{Url: ts.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 {
+		t.Fatalf("Found %v events, want 1", len(events))
+	}
+	e := events[0]
+	if e.Url != "https://cdn.example.org/episode1.mp3" || e.Summary != "The first episode" {
+		t.Errorf("Found url %v and summary %v, want the enclosure and the itunes summary", e.Url, e.Summary)
+	}
+	want := db.Enclosure{Url: "https://cdn.example.org/episode1.mp3", Type: "audio/mpeg", Length: 1048576, Duration: "1:02:03"}
+	if len(e.Enclosures) != 1 || e.Enclosures[0] != want {
+		t.Errorf("Found enclosures %+v, want %+v", e.Enclosures, want)
+	}
+	lines := enclosureLines(e.Enclosures)
+	if len(lines) != 1 || !strings.Contains(lines[0], "audio/mpeg, 1.0 MiB, 1:02:03, unplayed") {
+		t.Errorf("Found attachment lines %v", lines)
+	}
+}
+
+func TestFormatDuration(t *testing.T) {
+	tests := map[string]string{
+		"":         "",
+		"59":       "0:59",
+		"3723":     "1:02:03",
+		"62:03":    "1:02:03",
+		"01:02:03": "1:02:03",
+		"5:07":     "5:07",
+		"1h":       "1h",
+	}
+	for d, want := range tests {
+		if got := formatDuration(d); got != want {
+			t.Errorf("formatDuration(%q) = %q, want %q", d, got, want)
+		}
+	}
+}
+
+func TestEnclosureDownloadAndPlay(t *testing.T) {
+	dir := newTestDB(t)
+
+	content := strings.Repeat("x", 10000)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", fmt.Sprint(len(content)))
+		fmt.Fprint(w, content)
+	}))
+	defer ts.Close()
+
+	enc := db.Enclosure{Url: ts.URL + "/media/episode%201.mp3"}
+	App.tdb.SaveEnclosures("https://example.org/1", []db.Enclosure{enc})
+	App.tdb.SetSetting("download.directory", filepath.Join(dir, "downloads"))
+
+	var lastWritten, lastTotal int64
+	path, err := downloadEnclosure(db.Site{}, enc, func(written, total int64) {
+		lastWritten, lastTotal = written, total
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if path != filepath.Join(dir, "downloads", "episode 1.mp3") {
+		t.Errorf("Downloaded to %v", path)
+	}
+	if data, _ := ioutil.ReadFile(path); string(data) != content {
+		t.Errorf("Downloaded %v bytes, want %v", len(data), len(content))
+	}
+	if lastWritten != 10000 || lastTotal != 10000 {
+		t.Errorf("Last progress %v/%v, want 10000/10000", lastWritten, lastTotal)
+	}
+
+	// the enclosures of other news having the same name are not overwritten
+	other := db.Enclosure{Url: ts.URL + "/other/episode%201.mp3"}
+	App.tdb.SaveEnclosures("https://example.org/2", []db.Enclosure{other})
+	otherPath, err := downloadEnclosure(db.Site{}, other, func(written, total int64) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if otherPath != filepath.Join(dir, "downloads", "episode 1 (2).mp3") {
+		t.Errorf("Downloaded the other enclosure to %v", otherPath)
+	}
+	// but a downloaded enclosure is downloaded again to the same file
+	enc.Path = path
+	if again, err := downloadEnclosure(db.Site{}, enc, func(written, total int64) {}); err != nil || again != path {
+		t.Errorf("Downloaded again to %v (%v), want %v", again, err, path)
+	}
+
+	App.tdb.SetSetting("player.command", "true --option")
+	if err = playEnclosure(enc); err != nil {
+		t.Fatal(err)
+	}
+	encs, _ := App.tdb.GetEnclosures("https://example.org/1")
+	if stored := encs["https://example.org/1"]; len(stored) != 1 || stored[0].Path != path {
+		t.Errorf("Found stored enclosures %+v, want downloaded", stored)
+	}
+}
@@ -0,0 +1,96 @@
+/*
+   Terminews is a terminal based (TUI) RSS feed manager.
+   Copyright (C) 2017  Alexandros Ntavelos, a[dot]ntavelos[at]gmail[dot]com
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// options are the global options of the app given before the command
+type options struct {
+	version bool
+	// profile is the name of a profile having its own sites, news and
+	// settings, the default profile is used if empty
+	profile string
+	// dbPath overrides the DB file of the profile
+	dbPath string
+}
+
+// parseOptions parses the global options of the app and returns the remaining
+// arguments, the command and its own options
+func parseOptions(args []string) (options, []string, error) {
+	var o options
+	fs := flag.NewFlagSet("terminews", flag.ContinueOnError)
+	fs.BoolVar(&o.version, "version", false, "")
+	fs.StringVar(&o.profile, "profile", "", "")
+	fs.StringVar(&o.dbPath, "db", "", "")
+	fs.Usage = printUsage
+	if err := fs.Parse(args); err != nil {
+		return o, nil, err
+	}
+	if err := validateProfile(o.profile); err != nil {
+		return o, nil, err
+	}
+	return o, fs.Args(), nil
+}
+
+// validateProfile checks that the name of a profile can be used as a directory
+// name
+func validateProfile(name string) error {
+	if name == "." || name == ".." || strings.ContainsAny(name, `/\`) {
+		return fmt.Errorf("invalid profile name '%v'", name)
+	}
+	return nil
+}
+
+// appDataDir returns the directory of the data (DB file, log file) of the given
+// profile: $TERMINEWS_HOME if set, or ~/.terminews if it exists, otherwise the
+// terminews directory of $XDG_DATA_HOME. The settings are stored in the DB, so
+// there is no configuration directory. The profiles other than the default one
+// are kept in its profiles sub directory.
+func appDataDir(profile string) (string, error) {
+	dir := os.Getenv("TERMINEWS_HOME")
+	if len(dir) == 0 {
+		userHome, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		dir = filepath.Join(userHome, ".terminews")
+		if fi, err := os.Stat(dir); err != nil || !fi.IsDir() {
+			dir = filepath.Join(xdgDir("XDG_DATA_HOME", filepath.Join(userHome, ".local", "share")), "terminews")
+		}
+	}
+
+	if len(profile) > 0 {
+		dir = filepath.Join(dir, "profiles", profile)
+	}
+	return dir, nil
+}
+
+// xdgDir returns the directory of the given XDG environment variable or the
+// default one if it is not set to an absolute path
+func xdgDir(name, def string) string {
+	if dir := os.Getenv(name); filepath.IsAbs(dir) {
+		return dir
+	}
+	return def
+}
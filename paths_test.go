@@ -0,0 +1,96 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// setenv sets the environment variables returning a function restoring them
+func setenv(vars map[string]string) func() {
+	old := map[string]string{}
+	for name, value := range vars {
+		old[name] = os.Getenv(name)
+		os.Setenv(name, value)
+	}
+	return func() {
+		for name, value := range old {
+			os.Setenv(name, value)
+		}
+	}
+}
+
+func TestAppDataDir(t *testing.T) {
+	home, err := ioutil.TempDir("", "terminews")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(home)
+
+	for _, test := range []struct {
+		env     map[string]string
+		legacy  bool
+		profile string
+		want    string
+	}{
+		{
+			env:     map[string]string{"TERMINEWS_HOME": "/srv/terminews"},
+			legacy:  true,
+			profile: "work",
+			want:    "/srv/terminews/profiles/work",
+		},
+		{
+			legacy: true,
+			want:   filepath.Join(home, ".terminews"),
+		},
+		{
+			want: filepath.Join(home, ".local", "share", "terminews"),
+		},
+		{
+			env:  map[string]string{"XDG_DATA_HOME": "/xdg/data", "XDG_CONFIG_HOME": "/xdg/config"},
+			want: "/xdg/data/terminews",
+		},
+		{
+			env:     map[string]string{"XDG_DATA_HOME": "relative"},
+			profile: "personal",
+			want:    filepath.Join(home, ".local", "share", "terminews", "profiles", "personal"),
+		},
+	} {
+		env := map[string]string{"HOME": home, "TERMINEWS_HOME": "", "XDG_DATA_HOME": ""}
+		for name, value := range test.env {
+			env[name] = value
+		}
+		restore := setenv(env)
+		os.RemoveAll(filepath.Join(home, ".terminews"))
+		if test.legacy {
+			os.Mkdir(filepath.Join(home, ".terminews"), 0700)
+		}
+
+		dir, err := appDataDir(test.profile)
+		restore()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if dir != test.want {
+			t.Errorf("%v: got %v, want %v", test.env, dir, test.want)
+		}
+	}
+}
+
+func TestParseOptions(t *testing.T) {
+	opts, args, err := parseOptions([]string{"--profile", "work", "-db", "/tmp/x.db", "export", "-tag", "go"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opts.profile != "work" || opts.dbPath != "/tmp/x.db" || !reflect.DeepEqual(args, []string{"export", "-tag", "go"}) {
+		t.Errorf("got %+v and %v", opts, args)
+	}
+
+	for _, profile := range []string{"..", "a/b"} {
+		if _, _, err := parseOptions([]string{"--profile", profile}); err == nil {
+			t.Errorf("Expected error for profile %v", profile)
+		}
+	}
+}
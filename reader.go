@@ -0,0 +1,339 @@
+/*
+   Terminews is a terminal based (TUI) RSS feed manager.
+   Copyright (C) 2017  Alexandros Ntavelos, a[dot]ntavelos[at]gmail[dot]com
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/antavelos/terminews/db"
+)
+
+// the streams of the Google Reader API holding the state of the news
+const (
+	readerReadingList = "user/-/state/com.google/reading-list"
+	readerRead        = "user/-/state/com.google/read"
+	readerStarred     = "user/-/state/com.google/starred"
+	readerLabelPrefix = "user/-/label/"
+	readerFeedPrefix  = "feed/"
+)
+
+const (
+	// readerBatch is the number of news requested or edited per request
+	readerBatch = 250
+	// readerMaxItems bounds the number of news requested per stream
+	readerMaxItems = 1000
+)
+
+// readerClient is a client of the Google Reader API as implemented by sync
+// servers like FreshRSS or Miniflux
+type readerClient struct {
+	// url is the base URL of the API, e.g. https://example.org/api/greader.php
+	url      string
+	user     string
+	password string
+	client   *http.Client
+	// auth is the token obtained by the login, token the one of the edits
+	auth  string
+	token string
+}
+
+type readerSubscription struct {
+	Id      string `json:"id"`
+	Title   string `json:"title"`
+	Url     string `json:"url"`
+	HtmlUrl string `json:"htmlUrl"`
+}
+
+// feedUrl returns the URL of the feed of the subscription
+func (s readerSubscription) feedUrl() string {
+	if len(s.Url) > 0 {
+		return s.Url
+	}
+	return strings.TrimPrefix(s.Id, readerFeedPrefix)
+}
+
+type readerLink struct {
+	Href string `json:"href"`
+}
+
+type readerContent struct {
+	Content string `json:"content"`
+}
+
+type readerItem struct {
+	Id         string        `json:"id"`
+	Title      string        `json:"title"`
+	Published  int64         `json:"published"`
+	Author     string        `json:"author"`
+	Canonical  []readerLink  `json:"canonical"`
+	Alternate  []readerLink  `json:"alternate"`
+	Summary    readerContent `json:"summary"`
+	Content    readerContent `json:"content"`
+	Categories []string      `json:"categories"`
+	Origin     struct {
+		StreamId string `json:"streamId"`
+		Title    string `json:"title"`
+	} `json:"origin"`
+}
+
+// feedUrl returns the URL of the feed the news was published by
+func (it readerItem) feedUrl() string {
+	return strings.TrimPrefix(it.Origin.StreamId, readerFeedPrefix)
+}
+
+func (it readerItem) url() string {
+	for _, links := range [][]readerLink{it.Canonical, it.Alternate} {
+		if len(links) > 0 {
+			return links[0].Href
+		}
+	}
+	return ""
+}
+
+// event converts the news to an event
+func (it readerItem) event() db.Event {
+	e := db.Event{
+		Title:  it.Title,
+		Url:    it.url(),
+		Author: it.Author,
+	}
+	switch {
+	case len(it.Summary.Content) > 0:
+		e.Summary = trim(it.Summary.Content)
+	case len(it.Content.Content) > 0:
+		e.Summary = trim(it.Content.Content)
+	default:
+		e.Summary = "No summary available"
+	}
+	if it.Published > 0 {
+		e.Published = time.Unix(it.Published, 0).UTC().Format(time.RFC1123Z)
+	}
+	for _, c := range it.Categories {
+		if strings.HasPrefix(c, readerLabelPrefix) {
+			e.Categories = append(e.Categories, strings.TrimPrefix(c, readerLabelPrefix))
+		}
+	}
+	return e
+}
+
+// readerItemId returns the short form, a decimal number, of the id of a news
+// which is also given in its long form, e.g.
+// tag:google.com,2005:reader/item/000000000000001f
+func readerItemId(id string) string {
+	if !strings.HasPrefix(id, "tag:google.com") {
+		return id
+	}
+	n, err := strconv.ParseUint(id[strings.LastIndex(id, "/")+1:], 16, 64)
+	if err != nil {
+		return id
+	}
+	return strconv.FormatInt(int64(n), 10)
+}
+
+// login obtains the auth token of the user
+func (c *readerClient) login() error {
+	resp, err := c.client.PostForm(c.url+"/accounts/ClientLogin",
+		url.Values{"Email": {c.user}, "Passwd": {c.password}})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("login failed: %v", resp.Status)
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	for _, line := range strings.Split(string(body), "\n") {
+		if strings.HasPrefix(line, "Auth=") {
+			c.auth = strings.TrimSpace(strings.TrimPrefix(line, "Auth="))
+			return nil
+		}
+	}
+	return errors.New("login failed: no auth token received")
+}
+
+// request calls the given path of the API with the parameters, in the query
+// of a GET or the form of a POST, decoding the JSON response into v if given
+func (c *readerClient) request(method, path string, params url.Values, v interface{}) error {
+	u := c.url + path
+	var body io.Reader
+	if method == http.MethodGet {
+		u += "?" + params.Encode()
+	} else {
+		body = strings.NewReader(params.Encode())
+	}
+	req, err := http.NewRequest(method, u, body)
+	if err != nil {
+		return err
+	}
+	if method != http.MethodGet {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	req.Header.Set("Authorization", "GoogleLogin auth="+c.auth)
+	req.Header.Set("User-Agent", defaultUserAgent)
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return StatusError{c.url + path, resp.StatusCode, resp.Status}
+	}
+
+	switch v := v.(type) {
+	case nil:
+		return nil
+	case *string:
+		data, err := ioutil.ReadAll(resp.Body)
+		*v = strings.TrimSpace(string(data))
+		return err
+	default:
+		return json.NewDecoder(resp.Body).Decode(v)
+	}
+}
+
+// edit posts an edit of the user's data along with the edit token
+func (c *readerClient) edit(path string, params url.Values) error {
+	if len(c.token) == 0 {
+		if err := c.request(http.MethodGet, "/reader/api/0/token", url.Values{}, &c.token); err != nil {
+			return err
+		}
+	}
+	params.Set("T", c.token)
+	return c.request(http.MethodPost, path, params, nil)
+}
+
+func (c *readerClient) subscriptions() ([]readerSubscription, error) {
+	var result struct {
+		Subscriptions []readerSubscription `json:"subscriptions"`
+	}
+	err := c.request(http.MethodGet, "/reader/api/0/subscription/list", url.Values{"output": {"json"}}, &result)
+	return result.Subscriptions, err
+}
+
+func (c *readerClient) subscribe(feedUrl, title string) error {
+	return c.edit("/reader/api/0/subscription/edit",
+		url.Values{"ac": {"subscribe"}, "s": {readerFeedPrefix + feedUrl}, "t": {title}})
+}
+
+// streamContents returns the most recent news of a stream, the ones crawled
+// since the given time if it is set
+func (c *readerClient) streamContents(stream string, since time.Time) ([]readerItem, error) {
+	params := url.Values{"output": {"json"}, "n": {strconv.Itoa(readerBatch)}}
+	if !since.IsZero() {
+		params.Set("ot", strconv.FormatInt(since.Unix(), 10))
+	}
+
+	var items []readerItem
+	for len(items) < readerMaxItems {
+		var page struct {
+			Items        []readerItem `json:"items"`
+			Continuation string       `json:"continuation"`
+		}
+		if err := c.request(http.MethodGet, "/reader/api/0/stream/contents/"+stream, params, &page); err != nil {
+			return nil, err
+		}
+		items = append(items, page.Items...)
+		if len(page.Continuation) == 0 || len(page.Items) == 0 {
+			break
+		}
+		params.Set("c", page.Continuation)
+	}
+	return items, nil
+}
+
+// itemContents returns the news with the given ids
+func (c *readerClient) itemContents(ids []string) ([]readerItem, error) {
+	var items []readerItem
+	for start := 0; start < len(ids); start += readerBatch {
+		end := start + readerBatch
+		if end > len(ids) {
+			end = len(ids)
+		}
+		var page struct {
+			Items []readerItem `json:"items"`
+		}
+		params := url.Values{"output": {"json"}, "i": ids[start:end]}
+		if err := c.request(http.MethodPost, "/reader/api/0/stream/items/contents", params, &page); err != nil {
+			return nil, err
+		}
+		items = append(items, page.Items...)
+	}
+	return items, nil
+}
+
+// itemIds returns the ids (short form) of all the news of a stream, except the
+// ones of the excluded stream if any
+func (c *readerClient) itemIds(stream, exclude string) (map[string]bool, error) {
+	params := url.Values{"output": {"json"}, "s": {stream}, "n": {"10000"}}
+	if len(exclude) > 0 {
+		params.Set("xt", exclude)
+	}
+
+	ids := map[string]bool{}
+	for {
+		var page struct {
+			ItemRefs []struct {
+				Id string `json:"id"`
+			} `json:"itemRefs"`
+			Continuation string `json:"continuation"`
+		}
+		if err := c.request(http.MethodGet, "/reader/api/0/stream/items/ids", params, &page); err != nil {
+			return nil, err
+		}
+		for _, ref := range page.ItemRefs {
+			ids[readerItemId(ref.Id)] = true
+		}
+		if len(page.Continuation) == 0 || len(page.ItemRefs) == 0 {
+			return ids, nil
+		}
+		params.Set("c", page.Continuation)
+	}
+}
+
+// editTag adds the given state (or label) to the news or, if remove is set,
+// removes it
+func (c *readerClient) editTag(ids []string, state string, remove bool) error {
+	action := "a"
+	if remove {
+		action = "r"
+	}
+	for start := 0; start < len(ids); start += readerBatch {
+		end := start + readerBatch
+		if end > len(ids) {
+			end = len(ids)
+		}
+		if err := c.edit("/reader/api/0/edit-tag", url.Values{"i": ids[start:end], action: {state}}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
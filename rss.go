@@ -18,25 +18,108 @@
 package main
 
 import (
-	"errors"
 	"fmt"
+	"net/http"
 	"regexp"
 	"strings"
+	"time"
 
 	"github.com/antavelos/terminews/db"
 	"github.com/mmcdole/gofeed"
 )
 
-func CheckUrl(url string) (*gofeed.Feed, error) {
-	fp := gofeed.NewParser()
+const defaultUserAgent = "terminews/" + appVersion
+
+// siteHeaders parses the extra request headers of a site
+func siteHeaders(site db.Site) http.Header {
+	headers := http.Header{}
+	for _, line := range strings.Split(site.Headers, "\n") {
+		kv := strings.SplitN(line, ":", 2)
+		if len(kv) != 2 || len(strings.TrimSpace(kv[0])) == 0 {
+			continue
+		}
+		headers.Add(strings.TrimSpace(kv[0]), strings.TrimSpace(kv[1]))
+	}
+	return headers
+}
+
+// StatusError is returned when a request is answered with a non successful
+// HTTP status
+type StatusError struct {
+	Url    string
+	Code   int
+	Status string
+}
+
+func (e StatusError) Error() string {
+	return fmt.Sprintf("%v: %v", e.Url, e.Status)
+}
+
+// FetchInfo describes the outcome of the request of a feed
+type FetchInfo struct {
+	// Status is the HTTP status of the response, zero if none was received
+	Status  int
+	Latency time.Duration
+	// MovedTo is the new URL of a feed which was permanently redirected
+	MovedTo string
+}
+
+// permanentRedirect returns the final URL of a response if it was reached
+// through permanent redirects only
+func permanentRedirect(resp *http.Response) (string, bool) {
+	req := resp.Request
+	if req.Response == nil {
+		return "", false
+	}
+	for ; req.Response != nil; req = req.Response.Request {
+		code := req.Response.StatusCode
+		if code != http.StatusMovedPermanently && code != http.StatusPermanentRedirect {
+			return "", false
+		}
+	}
+	return resp.Request.URL.String(), true
+}
 
-	return fp.ParseURL(url)
+// fetch requests the given url with the settings of the site through the
+// fetcher of the app
+func fetch(site db.Site, url string) (*http.Response, error) {
+	return App.Web.Fetch(site, url)
 }
 
-func DownloadEvents(url string) ([]db.Event, error) {
-	feed, err := CheckUrl(url)
+// fetchFeed downloads and parses the feed of the site
+func fetchFeed(site db.Site) (*gofeed.Feed, FetchInfo, error) {
+	var info FetchInfo
+
+	start := time.Now()
+	resp, err := fetch(site, site.Url)
 	if err != nil {
-		return nil, errors.New(fmt.Sprintf("Failed to retrieve news from: '%v'", url))
+		info.Latency = time.Since(start)
+		if serr, ok := err.(StatusError); ok {
+			info.Status = serr.Code
+		}
+		return nil, info, err
+	}
+	defer resp.Body.Close()
+	info.Status = resp.StatusCode
+	if movedTo, ok := permanentRedirect(resp); ok {
+		info.MovedTo = movedTo
+	}
+
+	feed, err := gofeed.NewParser().Parse(resp.Body)
+	info.Latency = time.Since(start)
+
+	return feed, info, err
+}
+
+func CheckUrl(url string) (*gofeed.Feed, error) {
+	feed, _, err := fetchFeed(db.Site{Url: url})
+	return feed, err
+}
+
+func DownloadEvents(site db.Site) ([]db.Event, FetchInfo, error) {
+	feed, info, err := fetchFeed(site)
+	if err != nil {
+		return nil, info, fmt.Errorf("Failed to retrieve news from: '%v': %v", site.Url, err)
 	}
 
 	var events []db.Event
@@ -49,17 +132,33 @@ func DownloadEvents(url string) ([]db.Event, error) {
 			e.Author = "Unknown author"
 		}
 		e.Url = item.Link
-		if len(item.Description) > 0 {
+		e.Enclosures = enclosures(item)
+		if len(e.Url) == 0 && len(e.Enclosures) > 0 {
+			// podcast episodes often have no page of their own
+			e.Url = e.Enclosures[0].Url
+		}
+		switch {
+		case len(item.Description) > 0:
 			e.Summary = trim(item.Description)
-		} else {
+		case len(item.Content) > 0:
+			e.Summary = trim(item.Content)
+		case item.ITunesExt != nil && len(item.ITunesExt.Summary) > 0:
+			e.Summary = trim(item.ITunesExt.Summary)
+		case item.ITunesExt != nil && len(item.ITunesExt.Subtitle) > 0:
+			e.Summary = trim(item.ITunesExt.Subtitle)
+		default:
 			e.Summary = "No summary available"
 		}
 		e.Published = item.Published
+		e.Categories = item.Categories
 
 		events = append(events, e)
 	}
+	if site.MaxItems > 0 && len(events) > site.MaxItems {
+		events = events[:site.MaxItems]
+	}
 
-	return events, nil
+	return events, info, nil
 }
 
 func trim(desc string) string {
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/antavelos/terminews/db"
+)
+
+const testFeed = `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0">
+<channel>
+<title>Test feed</title>
+<item><title>Item 1</title><link>https://example.org/1</link><description>&lt;p&gt;First&lt;/p&gt;</description></item>
+<item><title>Item 2</title><link>https://example.org/2</link></item>
+<item><title>Item 3</title><link>https://example.org/3</link></item>
+</channel>
+</rss>`
+
+func TestDownloadEvents(t *testing.T) {
+	var headers http.Header
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		headers = r.Header
+		fmt.Fprint(w, testFeed)
+	}))
+	defer ts.Close()
+
+	site := db.Site{
+		Url:       ts.URL,
+		MaxItems:  2,
+		UserAgent: "custom-agent",
+		Headers:   "X-Token: secret\nAccept-Language: el",
+	}
+	events, info, err := DownloadEvents(site)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if len(events) != 2 {
+		t.Fatalf("got %v events, want 2", len(events))
+	}
+	if info.Status != 200 || len(info.MovedTo) > 0 {
+		t.Errorf("got fetch info %#v", info)
+	}
+	if events[0].Title != "Item 1" || events[0].Summary != "First" || events[1].Summary != "No summary available" {
+		t.Errorf("got events %#v", events)
+	}
+	for name, want := range map[string]string{
+		"User-Agent":      "custom-agent",
+		"X-Token":         "secret",
+		"Accept-Language": "el",
+	} {
+		if got := headers.Get(name); got != want {
+			t.Errorf("header %v got '%v', want '%v'", name, got, want)
+		}
+	}
+
+	if _, err := CheckUrl(ts.URL); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+	if headers.Get("User-Agent") != defaultUserAgent {
+		t.Errorf("got User-Agent '%v', want '%v'", headers.Get("User-Agent"), defaultUserAgent)
+	}
+}
+
+func TestDownloadEventsFailure(t *testing.T) {
+	ts := httptest.NewServer(http.NotFoundHandler())
+	defer ts.Close()
+
+	_, info, err := DownloadEvents(db.Site{Url: ts.URL})
+	if err == nil {
+		t.Errorf("expected error for missing feed")
+	}
+	if info.Status != 404 {
+		t.Errorf("got status %v, want 404", info.Status)
+	}
+}
+
+func TestDownloadEventsMovedFeed(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.Handle("/old", http.RedirectHandler("/older", http.StatusMovedPermanently))
+	mux.Handle("/older", http.RedirectHandler("/new", http.StatusPermanentRedirect))
+	mux.Handle("/temporary", http.RedirectHandler("/new", http.StatusFound))
+	mux.HandleFunc("/new", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, testFeed)
+	})
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	for path, want := range map[string]string{
+		"/old":       ts.URL + "/new",
+		"/temporary": "",
+		"/new":       "",
+	} {
+		_, info, err := DownloadEvents(db.Site{Url: ts.URL + path})
+		if err != nil {
+			t.Fatalf("for %v unexpected error %v", path, err)
+		}
+		if info.MovedTo != want {
+			t.Errorf("for %v got moved to '%v', want '%v'", path, info.MovedTo, want)
+		}
+	}
+}
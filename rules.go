@@ -0,0 +1,249 @@
+/*
+   Terminews is a terminal based (TUI) RSS feed manager.
+   Copyright (C) 2017  Alexandros Ntavelos, a[dot]ntavelos[at]gmail[dot]com
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/antavelos/terminews/db"
+)
+
+// Actions of the rules
+const (
+	ruleHide     = "hide"
+	ruleDim      = "dim"
+	ruleRead     = "read"
+	ruleBookmark = "bookmark"
+	ruleNotify   = "notify"
+)
+
+// Fields matched by the rules
+const (
+	ruleKeyword  = "keyword"
+	ruleRegex    = "regex"
+	ruleAuthor   = "author"
+	ruleSite     = "site"
+	ruleCategory = "category"
+)
+
+var ruleActions = []string{ruleHide, ruleDim, ruleRead, ruleBookmark, ruleNotify}
+
+var ruleFields = []string{ruleKeyword, ruleRegex, ruleAuthor, ruleSite, ruleCategory}
+
+// ruleSyntax describes the text of a rule as typed in the prompt and the
+// command line
+var ruleSyntax = fmt.Sprintf("%v %v PATTERN", strings.Join(ruleActions, "|"), strings.Join(ruleFields, "|"))
+
+// compiledRule is a rule ready to be matched against the news
+type compiledRule struct {
+	db.Rule
+	re *regexp.Regexp
+}
+
+// parseRule reads a rule written as "ACTION FIELD PATTERN"
+func parseRule(text string) (db.Rule, error) {
+	fields := strings.Fields(text)
+	if len(fields) < 3 {
+		return db.Rule{}, fmt.Errorf("expected: %v", ruleSyntax)
+	}
+	r := db.Rule{
+		Action: strings.ToLower(fields[0]),
+		Field:  strings.ToLower(fields[1]),
+		// the pattern may contain spaces
+		Pattern: strings.TrimSpace(strings.SplitN(strings.TrimSpace(text), fields[1], 2)[1]),
+	}
+	_, err := compileRule(r)
+	return r, err
+}
+
+// compileRule validates a rule and prepares it for matching
+func compileRule(r db.Rule) (compiledRule, error) {
+	cr := compiledRule{Rule: r}
+	if !containsString(ruleActions, r.Action) {
+		return cr, fmt.Errorf("unknown action '%v'", r.Action)
+	}
+	if !containsString(ruleFields, r.Field) {
+		return cr, fmt.Errorf("unknown field '%v'", r.Field)
+	}
+	if len(r.Pattern) == 0 {
+		return cr, fmt.Errorf("empty pattern")
+	}
+	if r.Field == ruleRegex {
+		re, err := regexp.Compile(r.Pattern)
+		if err != nil {
+			return cr, fmt.Errorf("invalid regex: %v", err)
+		}
+		cr.re = re
+	}
+	return cr, nil
+}
+
+// matches checks whether the rule applies to an event of the given site.
+// Apart from the regex, patterns are case insensitive.
+func (r compiledRule) matches(site db.Site, e db.Event) bool {
+	pattern := strings.ToLower(r.Pattern)
+	switch r.Field {
+	case ruleKeyword:
+		return strings.Contains(strings.ToLower(e.Title), pattern) ||
+			strings.Contains(strings.ToLower(e.Summary), pattern)
+	case ruleRegex:
+		return r.re.MatchString(e.Title) || r.re.MatchString(e.Summary)
+	case ruleAuthor:
+		return strings.Contains(strings.ToLower(e.Author), pattern)
+	case ruleSite:
+		return strings.EqualFold(site.Name, r.Pattern) || strings.Contains(strings.ToLower(site.Url), pattern)
+	case ruleCategory:
+		for _, cat := range e.Categories {
+			if strings.EqualFold(strings.TrimSpace(cat), r.Pattern) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// loadRules returns the stored rules skipping the invalid ones
+func loadRules() []compiledRule {
+	rules, err := App.tdb.GetRules()
+	if err != nil {
+		log.Println("Error on GetRules", err)
+		return nil
+	}
+
+	var result []compiledRule
+	for _, r := range rules {
+		cr, err := compileRule(r)
+		if err != nil {
+			log.Printf("Skipping invalid rule %v: %v", r.Id, err)
+			continue
+		}
+		result = append(result, cr)
+	}
+	return result
+}
+
+// applyRules drops the hidden events of a site, dims the dimmed ones and
+// marks as read or bookmarks the ones matching the respective rules. The
+// notify rules apply to the new events only, see notifyNewEvents.
+func applyRules(rules []compiledRule, site db.Site, events []db.Event) []db.Event {
+	var result []db.Event
+	for _, e := range events {
+		hidden := false
+		for _, r := range rules {
+			if !r.matches(site, e) {
+				continue
+			}
+			switch r.Action {
+			case ruleHide:
+				hidden = true
+			case ruleDim:
+				e.Dimmed = true
+			case ruleRead:
+				if !e.Read {
+					e.Read = true
+					if err := App.tdb.MarkItemRead(e.Url); err != nil {
+						log.Println("Error on MarkItemRead", err)
+					}
+				}
+			case ruleBookmark:
+				if _, err := App.tdb.GetEventByUrl(e.Url); err == nil {
+					continue
+				}
+				if err := App.tdb.AddEvent(e); err != nil {
+					log.Println("Error on AddEvent", err)
+				}
+			}
+		}
+		if !hidden {
+			result = append(result, e)
+		}
+	}
+	return result
+}
+
+// filterEvents sets the read state of the events of a site and applies the
+// rules on them
+func filterEvents(site db.Site, events []db.Event) []db.Event {
+	if site.Id != 0 {
+		read, err := App.tdb.GetReadUrls(site.Id)
+		if err != nil {
+			log.Println("Error on GetReadUrls", err)
+		}
+		for i := range events {
+			if read[events[i].Url] {
+				events[i].Read = true
+			}
+		}
+	}
+	return applyRules(loadRules(), site, events)
+}
+
+func rulesCommand(args []string) error {
+	fs := flag.NewFlagSet("rules", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: terminews rules [list | add %v | delete ID]\n", ruleSyntax)
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	action := "list"
+	if fs.NArg() > 0 {
+		action = fs.Arg(0)
+	}
+	switch action {
+	case "list":
+		rules, err := App.tdb.GetRules()
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tACTION\tFIELD\tPATTERN")
+		for _, r := range rules {
+			fmt.Fprintf(w, "%v\t%v\t%v\t%v\n", r.Id, r.Action, r.Field, r.Pattern)
+		}
+		return w.Flush()
+	case "add":
+		r, err := parseRule(strings.Join(fs.Args()[1:], " "))
+		if err != nil {
+			return err
+		}
+		return App.tdb.AddRule(r)
+	case "delete":
+		if fs.NArg() != 2 {
+			fs.Usage()
+			return errors.New("exactly one rule ID is expected")
+		}
+		id, err := strconv.Atoi(fs.Arg(1))
+		if err != nil {
+			return fmt.Errorf("invalid rule ID '%v'", fs.Arg(1))
+		}
+		return App.tdb.DeleteRule(id)
+	}
+
+	fs.Usage()
+	return fmt.Errorf("unknown action '%v'", action)
+}
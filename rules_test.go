@@ -0,0 +1,103 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/antavelos/terminews/db"
+)
+
+func TestParseRule(t *testing.T) {
+	r, err := parseRule("  hide keyword  crypto currency ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Action != ruleHide || r.Field != ruleKeyword || r.Pattern != "crypto currency" {
+		t.Errorf("Parsed %#v, want hide keyword 'crypto currency'", r)
+	}
+
+	for _, text := range []string{"hide keyword", "drop keyword x", "hide title x", "dim regex ([a-z"} {
+		if _, err := parseRule(text); err == nil {
+			t.Errorf("Expected an error for rule '%v'", text)
+		}
+	}
+}
+
+func TestRuleMatches(t *testing.T) {
+	site := db.Site{Name: "Hacker News", Url: "https://news.ycombinator.com/rss"}
+	e := db.Event{
+		Title:      "Show HN: A Bitcoin wallet in Go",
+		Summary:    "Written over a weekend",
+		Author:     "Jane Doe",
+		Categories: []string{"Crypto", "Go"},
+	}
+	tests := []struct {
+		rule db.Rule
+		want bool
+	}{
+		{db.Rule{Action: ruleHide, Field: ruleKeyword, Pattern: "bitcoin"}, true},
+		{db.Rule{Action: ruleHide, Field: ruleKeyword, Pattern: "weekend"}, true},
+		{db.Rule{Action: ruleHide, Field: ruleKeyword, Pattern: "rust"}, false},
+		{db.Rule{Action: ruleHide, Field: ruleRegex, Pattern: `^Show HN:`}, true},
+		{db.Rule{Action: ruleHide, Field: ruleRegex, Pattern: `^show hn:`}, false},
+		{db.Rule{Action: ruleHide, Field: ruleAuthor, Pattern: "jane"}, true},
+		{db.Rule{Action: ruleHide, Field: ruleSite, Pattern: "hacker news"}, true},
+		{db.Rule{Action: ruleHide, Field: ruleSite, Pattern: "ycombinator.com"}, true},
+		{db.Rule{Action: ruleHide, Field: ruleCategory, Pattern: "crypto"}, true},
+		{db.Rule{Action: ruleHide, Field: ruleCategory, Pattern: "cry"}, false},
+	}
+	for _, tt := range tests {
+		cr, err := compileRule(tt.rule)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := cr.matches(site, e); got != tt.want {
+			t.Errorf("Rule '%v' matches: %v, want %v", tt.rule, got, tt.want)
+		}
+	}
+}
+
+func TestFilterEvents(t *testing.T) {
+	newTestDB(t)
+
+	App.tdb.AddSite(db.Site{Name: "Blog", Url: "https://blog.example.com/feed"})
+	site, _ := App.tdb.GetSiteByUrl("https://blog.example.com/feed")
+	events := []db.Event{
+		{Title: "Sponsored: buy now", Url: "https://blog.example.com/1"},
+		{Title: "Weekly roundup", Url: "https://blog.example.com/2"},
+		{Title: "Release notes", Url: "https://blog.example.com/3", Author: "Bot"},
+		{Title: "Must read", Url: "https://blog.example.com/4", Categories: []string{"Important"}},
+		{Title: "Plain", Url: "https://blog.example.com/5"},
+	}
+	App.tdb.SaveItems(site.Id, events)
+	for _, text := range []string{"hide regex ^Sponsored", "dim keyword roundup", "read author bot", "bookmark category important"} {
+		r, err := parseRule(text)
+		if err != nil {
+			t.Fatal(err)
+		}
+		App.tdb.AddRule(r)
+	}
+
+	filtered := filterEvents(site, events)
+	if len(filtered) != 4 || filtered[0].Title != "Weekly roundup" {
+		t.Fatalf("Found %v, want the sponsored event hidden", filtered)
+	}
+	if !filtered[0].Dimmed || filtered[0].Read {
+		t.Errorf("Expected the roundup to be dimmed only")
+	}
+	if !filtered[1].Read {
+		t.Errorf("Expected the release notes to be read")
+	}
+	if _, err := App.tdb.GetEventByUrl("https://blog.example.com/4"); err != nil {
+		t.Errorf("Expected the important event to be bookmarked: %v", err)
+	}
+	if filtered[3].Read || filtered[3].Dimmed {
+		t.Errorf("Expected the plain event untouched")
+	}
+
+	// the read state is kept by the next fetches
+	App.tdb.MarkItemRead("https://blog.example.com/5")
+	filtered = filterEvents(site, events)
+	if !filtered[1].Read || !filtered[3].Read {
+		t.Errorf("Expected the read events to remain read")
+	}
+}
@@ -0,0 +1,376 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/antavelos/terminews/db"
+	c "github.com/jroimartin/gocui"
+)
+
+var updateGolden = flag.Bool("update", false, "update the golden screens in testdata/screens")
+
+const (
+	screenWidth  = 100
+	screenHeight = 30
+)
+
+// screen drives the GUI headless: the keys are dispatched through the
+// keybindings of the app and the views are rendered as text
+type screen struct {
+	t *testing.T
+	g *c.Gui
+
+	mu    sync.Mutex
+	queue []func(*c.Gui) error
+}
+
+// newScreen starts the app headless with a new state and an empty DB
+func newScreen(t *testing.T) *screen {
+	newTestApp(t)
+	newTestDB(t)
+
+	s := &screen{t: t, g: &c.Gui{}}
+	App.Driver = driver{
+		size: func(g *c.Gui) (int, int) {
+			return screenWidth, screenHeight
+		},
+		update: func(g *c.Gui, f func(*c.Gui) error) {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			s.queue = append(s.queue, f)
+		},
+	}
+
+	if err := setupGui(s.g); err != nil {
+		t.Fatal(err)
+	}
+	s.settle()
+
+	return s
+}
+
+// drain runs the queued updates and reports whether there were any
+func (s *screen) drain() bool {
+	s.mu.Lock()
+	queue := s.queue
+	s.queue = nil
+	s.mu.Unlock()
+
+	for _, f := range queue {
+		if err := f(s.g); err != nil && err != c.ErrQuit {
+			s.t.Fatalf("Update failed: %v", err)
+		}
+	}
+	return len(queue) > 0
+}
+
+// settle lays out the views and runs the queued updates, like the main loop
+// does, until there are no more
+func (s *screen) settle() {
+	for {
+		if err := layout(s.g); err != nil {
+			s.t.Fatalf("Layout failed: %v", err)
+		}
+		if !s.drain() {
+			return
+		}
+	}
+}
+
+// press dispatches a key, either a gocui.Key or a rune, to the handlers
+// bound to the current view, or to its editor if there are none
+func (s *screen) press(key interface{}, mod c.Modifier) {
+	v := s.g.CurrentView()
+	matched := false
+	for _, kb := range keybindings() {
+		if kb.key != key || kb.mod != mod || (len(kb.view) > 0 && (v == nil || v.Name() != kb.view)) {
+			continue
+		}
+		matched = true
+		if err := kb.handler(s.g, v); err != nil && err != c.ErrQuit {
+			s.t.Fatalf("Handler of %v failed: %v", key, err)
+		}
+	}
+	if !matched && v != nil && v.Editable && v.Editor != nil {
+		switch k := key.(type) {
+		case rune:
+			v.Editor.Edit(v, 0, k, mod)
+		case c.Key:
+			v.Editor.Edit(v, k, 0, mod)
+		}
+	}
+	s.settle()
+}
+
+// typeText types the given text rune by rune
+func (s *screen) typeText(text string) {
+	for _, ch := range text {
+		s.press(ch, c.ModNone)
+	}
+}
+
+// waitFor runs the queued updates until the condition holds
+func (s *screen) waitFor(what string, cond func() bool) {
+	for deadline := time.Now().Add(5 * time.Second); !cond(); {
+		if time.Now().After(deadline) {
+			s.t.Fatalf("Timed out waiting for %v:\n%v", what, s.render())
+		}
+		time.Sleep(10 * time.Millisecond)
+		s.settle()
+	}
+}
+
+// render draws the views in their stacking order. The frame of the current
+// view is drawn with heavy lines and the highlighted line is pointed at on
+// the left border.
+func (s *screen) render() string {
+	cells := make([][]rune, screenHeight)
+	for y := range cells {
+		cells[y] = []rune(strings.Repeat(" ", screenWidth))
+	}
+	set := func(x, y int, ch rune) {
+		if x >= 0 && x < screenWidth && y >= 0 && y < screenHeight {
+			cells[y][x] = ch
+		}
+	}
+
+	current := s.g.CurrentView()
+	for _, v := range s.g.Views() {
+		x0, y0, x1, y1, err := s.g.ViewPosition(v.Name())
+		if err != nil {
+			s.t.Fatal(err)
+		}
+		for y := y0 + 1; y < y1; y++ {
+			for x := x0 + 1; x < x1; x++ {
+				set(x, y, ' ')
+			}
+		}
+
+		if v.Frame {
+			frame := []rune("─│┌┐└┘")
+			if v == current {
+				frame = []rune("━┃┏┓┗┛")
+			}
+			for x := x0 + 1; x < x1; x++ {
+				set(x, y0, frame[0])
+				set(x, y1, frame[0])
+			}
+			for y := y0 + 1; y < y1; y++ {
+				set(x0, y, frame[1])
+				set(x1, y, frame[1])
+			}
+			set(x0, y0, frame[2])
+			set(x1, y0, frame[3])
+			set(x0, y1, frame[4])
+			set(x1, y1, frame[5])
+			for i, ch := range []rune(v.Title) {
+				if x := x0 + i + 2; x <= x1-2 {
+					set(x, y0, ch)
+				}
+			}
+		}
+
+		maxX, maxY := v.Size()
+		var lines [][]rune
+		for _, line := range v.BufferLines() {
+			runes := []rune(line)
+			for v.Wrap && maxX > 0 && len(runes) > maxX {
+				lines = append(lines, runes[:maxX])
+				runes = runes[maxX:]
+			}
+			lines = append(lines, runes)
+		}
+		ox, oy := v.Origin()
+		if v.Wrap {
+			ox = 0
+		}
+		if v.Autoscroll && len(lines) > maxY {
+			oy = len(lines) - maxY
+		}
+		for y := 0; y < maxY && oy+y < len(lines); y++ {
+			line := lines[oy+y]
+			for x := 0; x < maxX && ox+x < len(line); x++ {
+				set(x0+1+x, y0+1+y, line[ox+x])
+			}
+		}
+		if _, cy := v.Cursor(); v.Highlight && v.Frame && cy < maxY {
+			set(x0, y0+1+cy, '▶')
+		}
+	}
+
+	lines := make([]string, len(cells))
+	for y, row := range cells {
+		lines[y] = strings.TrimRight(string(row), " ")
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// assertScreen compares the rendered screen to the golden file with the given
+// name, rewriting the file instead when the tests run with -update
+func (s *screen) assertScreen(name string) {
+	s.t.Helper()
+	got := s.render()
+	path := filepath.Join("testdata", "screens", name+".golden")
+	if *updateGolden {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			s.t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(got), 0644); err != nil {
+			s.t.Fatal(err)
+		}
+		return
+	}
+	want, err := ioutil.ReadFile(path)
+	if err != nil {
+		s.t.Fatalf("%v (run the tests with -update to create it)", err)
+	}
+	if got != string(want) {
+		s.t.Errorf("Screen %v differs from %v\ngot:\n%v\nwant:\n%v", name, path, got, want)
+	}
+}
+
+// newFixtureFeed serves a feed of 30 news about rotating topics
+func newFixtureFeed(t *testing.T) *httptest.Server {
+	topics := []string{"go", "rust", "zig"}
+	var items strings.Builder
+	for i := 1; i <= 30; i++ {
+		fmt.Fprintf(&items, "<item><title>Story %02d about %v</title><link>https://example.org/story/%02d</link>"+
+			"<author>editor@example.org (Editor)</author><pubDate>Mon, 05 Oct 2020 10:%02d:00 +0000</pubDate>"+
+			"<description>The %v news number %v.</description></item>\n", i, topics[i%3], i, i, topics[i%3], i)
+	}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0">
+<channel>
+<title>Fixture News</title>
+<link>https://example.org/</link>
+%v</channel>
+</rss>`, items.String())
+	}))
+	t.Cleanup(ts.Close)
+
+	return ts
+}
+
+// addSite adds a site through the new site prompt
+func (s *screen) addSite(url string) {
+	s.press(c.KeyCtrlN, c.ModNone)
+	s.typeText(url)
+	s.press(c.KeyEnter, c.ModNone)
+	s.waitFor("the site to be added", func() bool { return len(App.SitesList.items) == 1 })
+}
+
+func TestScreenStartup(t *testing.T) {
+	s := newScreen(t)
+	s.assertScreen("startup")
+
+	s.press(c.KeyTab, c.ModNone)
+	s.assertScreen("startup_news")
+}
+
+func TestScreenPaging(t *testing.T) {
+	s := newScreen(t)
+	s.addSite(newFixtureFeed(t).URL)
+	s.assertScreen("site_added")
+
+	s.press(c.KeyEnter, c.ModNone)
+	s.assertScreen("news")
+
+	s.press(c.KeyArrowDown, c.ModNone)
+	s.press(c.KeyArrowDown, c.ModNone)
+	s.assertScreen("news_down")
+
+	s.press(c.KeyPgdn, c.ModNone)
+	s.assertScreen("news_page_down")
+
+	s.press(c.KeyPgup, c.ModNone)
+	s.assertScreen("news_page_up")
+}
+
+func TestScreenBookmarks(t *testing.T) {
+	s := newScreen(t)
+	s.addSite(newFixtureFeed(t).URL)
+	s.press(c.KeyEnter, c.ModNone)
+	s.press(c.KeyArrowDown, c.ModNone)
+	s.press(c.KeyCtrlB, c.ModNone)
+	s.press(c.KeyArrowDown, c.ModNone)
+	s.press(c.KeyArrowDown, c.ModNone)
+	s.press(c.KeyCtrlB, c.ModNone)
+	s.assertScreen("bookmarked")
+
+	s.press(c.KeyCtrlB, c.ModAlt)
+	s.assertScreen("bookmarks")
+
+	// unbookmarking keeps the news listed until the bookmarks are reloaded
+	s.press(c.KeyCtrlB, c.ModNone)
+	s.assertScreen("unbookmarked")
+}
+
+func TestScreenSearch(t *testing.T) {
+	s := newScreen(t)
+	s.addSite(newFixtureFeed(t).URL)
+
+	s.press(c.KeyCtrlF, c.ModNone)
+	s.typeText("rust")
+	s.assertScreen("search_prompt")
+
+	s.press(c.KeyEnter, c.ModNone)
+	s.waitFor("the search to complete", func() bool {
+		return strings.HasSuffix(strings.TrimSpace(App.NewsList.Title), "found")
+	})
+	s.assertScreen("search_results")
+}
+
+func TestScreenPrompts(t *testing.T) {
+	s := newScreen(t)
+	s.press(c.KeyCtrlN, c.ModNone)
+	s.typeText("ftp://example.org/feed")
+	s.press(c.KeyEnter, c.ModNone)
+	s.waitFor("the URL to be rejected", func() bool { return App.UI.Prompt != nil && App.UI.Prompt.rejected })
+	s.assertScreen("prompt_rejected")
+
+	// the layout keys are typed in the prompts
+	s.typeText("s+")
+	s.press(c.KeyCtrlQ, c.ModNone)
+	s.assertScreen("prompt_closed")
+}
+
+func TestScreenMovedFeed(t *testing.T) {
+	s := newScreen(t)
+	ts := newFixtureServer(t)
+	s.addSite(ts.URL + "/moved")
+
+	s.press(c.KeyEnter, c.ModNone)
+	s.waitFor("the moved feed prompt", func() bool { return App.UI.Prompt != nil })
+	if v := s.g.CurrentView(); v == nil || v.Name() != PROMPT_VIEW {
+		t.Fatalf("The prompt is not focused:\n%v", s.render())
+	}
+	if len(App.NewsList.items) != 3 {
+		t.Errorf("Got %v news under the prompt, want 3", len(App.NewsList.items))
+	}
+
+	s.press(c.KeyEnter, c.ModNone)
+	if App.UI.Prompt != nil {
+		t.Fatalf("The prompt was not submitted:\n%v", s.render())
+	}
+	site, err := App.tdb.GetSiteById(App.SitesList.CurrentItem().(db.Site).Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if site.Url != ts.URL+"/feed.rss" {
+		t.Errorf("Got URL %v, want %v", site.Url, ts.URL+"/feed.rss")
+	}
+	if v := s.g.CurrentView(); v == nil || v.Name() != NEWS_VIEW {
+		t.Errorf("The news are not focused after the prompt:\n%v", s.render())
+	}
+}
@@ -0,0 +1,155 @@
+/*
+   Terminews is a terminal based (TUI) RSS feed manager.
+   Copyright (C) 2017  Alexandros Ntavelos, a[dot]ntavelos[at]gmail[dot]com
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+package main
+
+import (
+	"encoding/json"
+	"log"
+
+	"github.com/antavelos/terminews/db"
+	c "github.com/jroimartin/gocui"
+)
+
+// sessionState stores the state of the UI saved on exit
+const sessionState = "state.session"
+
+// session is the state of the UI restored at startup
+type session struct {
+	// SiteId is the selected site of the sites list
+	SiteId int `json:"site_id,omitempty"`
+	// News is the source of the news list, NewsSiteId and Terms its details
+	News       newsSource `json:"news,omitempty"`
+	NewsSiteId int        `json:"news_site_id,omitempty"`
+	Terms      []string   `json:"terms,omitempty"`
+	// NewsIdx is the selected news of the news list
+	NewsIdx int `json:"news_idx"`
+	// Focus is the focused list and Content whether the content of the
+	// selected news is displayed
+	Focus   string `json:"focus,omitempty"`
+	Content bool   `json:"content,omitempty"`
+}
+
+// captureSession returns the current state of the UI
+func captureSession(g *c.Gui) session {
+	var s session
+	if currItem := App.SitesList.CurrentItem(); currItem != nil {
+		s.SiteId = currItem.(db.Site).Id
+	}
+
+	s.News = App.UI.News
+	switch App.UI.News {
+	case newsSite:
+		s.NewsSiteId = App.CurrentSite.Id
+	case newsSearch:
+		s.Terms = App.SearchTerms
+	}
+	s.NewsIdx = App.NewsList.CurrentIdx()
+
+	s.Focus = SITES_VIEW
+	if v := g.CurrentView(); v != nil {
+		switch v.Name() {
+		case CONTENT_VIEW:
+			s.Focus, s.Content = NEWS_VIEW, true
+		case NEWS_VIEW:
+			s.Focus = NEWS_VIEW
+		}
+	}
+	return s
+}
+
+// saveSession stores the current state of the UI
+func saveSession(g *c.Gui) error {
+	data, err := json.Marshal(captureSession(g))
+	if err != nil {
+		return err
+	}
+	return App.tdb.SetSetting(sessionState, string(data))
+}
+
+// loadSession returns the stored state of the UI if any
+func loadSession() (session, bool) {
+	var s session
+	data, err := App.tdb.GetSetting(sessionState)
+	if err != nil {
+		return s, false
+	}
+	if err = json.Unmarshal([]byte(data), &s); err != nil {
+		log.Println("Error on loading the session", err)
+		return s, false
+	}
+	return s, true
+}
+
+// restoreSession restores the stored state of the UI once the sites are
+// loaded
+func restoreSession(g *c.Gui) error {
+	s, ok := loadSession()
+	if !ok {
+		return nil
+	}
+	if err := selectSite(s.SiteId); err != nil {
+		return err
+	}
+
+	switch s.News {
+	case newsSite:
+		site, err := App.tdb.GetSiteById(s.NewsSiteId)
+		if err != nil {
+			// the site was deleted meanwhile
+			return nil
+		}
+		openSite(g, site)
+		if err := showSiteNews(g, site); err != nil {
+			return err
+		}
+	case newsBookmarks:
+		if err := LoadBookmarks(g, App.SitesList.View); err != nil {
+			return err
+		}
+	case newsSearch:
+		// the results are listed as they are found so the selection is lost
+		startSearch(g, s.Terms)
+		return nil
+	default:
+		return nil
+	}
+
+	if err := App.NewsList.Select(s.NewsIdx); err != nil {
+		return err
+	}
+	if err := UpdateSummary(); err != nil {
+		return err
+	}
+	// a prompt opened meanwhile, e.g. for a moved feed, keeps the focus
+	if App.UI.Prompt != nil {
+		return nil
+	}
+	// the news are focused anyway when the sites are hidden
+	if (s.Focus != NEWS_VIEW && !App.Layout.HideSites) || App.NewsList.IsEmpty() {
+		App.NewsList.Unfocus()
+		return App.SitesList.Focus(g)
+	}
+	App.SitesList.Unfocus()
+	if err := App.NewsList.Focus(g); err != nil {
+		return err
+	}
+	if s.Content {
+		return LoadContent(g, App.NewsList.View)
+	}
+	return nil
+}
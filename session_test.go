@@ -0,0 +1,27 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestLoadSession(t *testing.T) {
+	newTestDB(t)
+
+	if _, ok := loadSession(); ok {
+		t.Errorf("Found a session in a new DB")
+	}
+
+	want := session{SiteId: 2, News: newsSearch, Terms: []string{"go", "release"}, NewsIdx: -1, Focus: NEWS_VIEW}
+	data, _ := json.Marshal(want)
+	App.tdb.SetSetting(sessionState, string(data))
+	if s, ok := loadSession(); !ok || !reflect.DeepEqual(s, want) {
+		t.Errorf("Found session %+v (%v), want %+v", s, ok, want)
+	}
+
+	App.tdb.SetSetting(sessionState, "{")
+	if _, ok := loadSession(); ok {
+		t.Errorf("Found a session from invalid data")
+	}
+}
@@ -0,0 +1,239 @@
+/*
+   Terminews is a terminal based (TUI) RSS feed manager.
+   Copyright (C) 2017  Alexandros Ntavelos, a[dot]ntavelos[at]gmail[dot]com
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/antavelos/terminews/db"
+)
+
+// siteField describes a setting of a site which can be edited in the site
+// edit view
+type siteField struct {
+	label string
+	get   func(s db.Site) string
+	set   func(s *db.Site, value string) error
+	// options, if any, are cycled through instead of prompting for a value
+	options func() []string
+}
+
+// headersSeparator separates the extra headers of a site while edited in the
+// single line prompt, the separators and the backslashes of the headers being
+// escaped with a backslash
+const headersSeparator = " | "
+
+// joinHeaders returns the headers, stored one per line, as edited in the prompt
+func joinHeaders(headers string) string {
+	escaper := strings.NewReplacer(`\`, `\\`, "|", `\|`)
+	lines := strings.Split(headers, "\n")
+	for i, h := range lines {
+		lines[i] = escaper.Replace(h)
+	}
+	return strings.Join(lines, headersSeparator)
+}
+
+// splitHeaders returns the headers edited in the prompt, the escaped
+// separators being kept in the headers
+func splitHeaders(value string) []string {
+	var headers []string
+	var h strings.Builder
+	for i := 0; i < len(value); i++ {
+		switch {
+		case value[i] == '\\' && i+1 < len(value):
+			i++
+			h.WriteByte(value[i])
+		case value[i] == '|':
+			headers = append(headers, h.String())
+			h.Reset()
+		default:
+			h.WriteByte(value[i])
+		}
+	}
+	return append(headers, h.String())
+}
+
+var siteFields = []siteField{
+	{
+		label: "Name",
+		get:   func(s db.Site) string { return s.Name },
+		set: func(s *db.Site, value string) error {
+			if len(value) == 0 {
+				return errors.New("empty name")
+			}
+			s.Name = value
+			return nil
+		},
+	},
+	{
+		label: "Refresh interval (minutes, 0 to disable)",
+		get:   func(s db.Site) string { return strconv.Itoa(s.RefreshInterval) },
+		set: func(s *db.Site, value string) error {
+			n, err := parseNonNegative(value)
+			if err == nil {
+				s.RefreshInterval = n
+			}
+			return err
+		},
+	},
+	{
+		label: "Max items (0 to keep all)",
+		get:   func(s db.Site) string { return strconv.Itoa(s.MaxItems) },
+		set: func(s *db.Site, value string) error {
+			n, err := parseNonNegative(value)
+			if err == nil {
+				s.MaxItems = n
+			}
+			return err
+		},
+	},
+	{
+		label: "Content extractor",
+		get: func(s db.Site) string {
+			if len(s.Extractor) == 0 {
+				return defaultExtractor
+			}
+			return s.Extractor
+		},
+		set: func(s *db.Site, value string) error {
+			if _, ok := extractors[value]; !ok {
+				return fmt.Errorf("unknown extractor '%v'", value)
+			}
+			s.Extractor = value
+			return nil
+		},
+		options: extractorNames,
+	},
+	{
+		label: "Open in browser instead of extracting",
+		get:   func(s db.Site) string { return formatBool(s.OpenInBrowser) },
+		set: func(s *db.Site, value string) error {
+			s.OpenInBrowser = value == formatBool(true)
+			return nil
+		},
+		options: func() []string { return []string{formatBool(false), formatBool(true)} },
+	},
+	{
+		label: "User-Agent (empty for default)",
+		get:   func(s db.Site) string { return s.UserAgent },
+		set: func(s *db.Site, value string) error {
+			s.UserAgent = value
+			return nil
+		},
+	},
+	{
+		label: "Headers (Name: Value" + headersSeparator + "...)",
+		get: func(s db.Site) string {
+			return joinHeaders(s.Headers)
+		},
+		set: func(s *db.Site, value string) error {
+			var headers []string
+			for _, h := range splitHeaders(value) {
+				if h = strings.TrimSpace(h); len(h) == 0 {
+					continue
+				}
+				if !strings.Contains(h, ":") {
+					return fmt.Errorf("invalid header '%v'", h)
+				}
+				headers = append(headers, h)
+			}
+			s.Headers = strings.Join(headers, "\n")
+			return nil
+		},
+	},
+	{
+		label: "Proxy (URL, tor, direct or empty for the default)",
+		get:   func(s db.Site) string { return s.Proxy },
+		set: func(s *db.Site, value string) error {
+			if err := validateProxy(value); err != nil {
+				return err
+			}
+			s.Proxy = value
+			return nil
+		},
+	},
+	{
+		label: "Authentication",
+		get:   func(s db.Site) string { return formatAuth(s.Auth) },
+		set: func(s *db.Site, value string) error {
+			if err := validateAuth(value); err != nil {
+				return err
+			}
+			s.Auth = parseAuth(value)
+			return nil
+		},
+		options: func() []string { return authNames },
+	},
+	{
+		label: "Authentication user (or header name)",
+		get:   func(s db.Site) string { return s.AuthUser },
+		set: func(s *db.Site, value string) error {
+			s.AuthUser = value
+			return nil
+		},
+	},
+	{
+		label: "Authentication secret (" + secretEnvPrefix + "VARIABLE, " + secretCmdPrefix + "COMMAND or value)",
+		get:   func(s db.Site) string { return maskSecret(s.AuthSecret) },
+		set: func(s *db.Site, value string) error {
+			// the masked secret is kept
+			if value != secretMask {
+				s.AuthSecret = value
+			}
+			return nil
+		},
+	},
+}
+
+// siteFieldLines returns the lines of the site edit view
+func siteFieldLines(s db.Site) []interface{} {
+	lines := make([]interface{}, len(siteFields))
+	for i, f := range siteFields {
+		lines[i] = fmt.Sprintf("%v: %v", f.label, f.get(s))
+	}
+	return lines
+}
+
+// nextOption returns the option following the current value of the field
+func (f siteField) nextOption(s db.Site) string {
+	options := f.options()
+	for i, o := range options {
+		if o == f.get(s) {
+			return options[(i+1)%len(options)]
+		}
+	}
+	return options[0]
+}
+
+func parseNonNegative(value string) (int, error) {
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("'%v' is not a non negative number", value)
+	}
+	return n, nil
+}
+
+func formatBool(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
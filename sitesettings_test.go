@@ -0,0 +1,42 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/antavelos/terminews/db"
+)
+
+func TestEditHeaders(t *testing.T) {
+	var field siteField
+	for _, f := range siteFields {
+		if f.label == "Headers (Name: Value"+headersSeparator+"...)" {
+			field = f
+		}
+	}
+
+	for _, headers := range []string{
+		"",
+		"X-Api-Key: abc",
+		"X-Api-Key: abc\nAccept: application/rss+xml",
+		`X-Filter: a|b || c\d` + "\nCookie: session=1",
+	} {
+		value := field.get(db.Site{Headers: headers})
+		var site db.Site
+		if err := field.set(&site, value); err != nil {
+			t.Errorf("%q edited as %q: %v", headers, value, err)
+		} else if site.Headers != headers {
+			t.Errorf("%q edited as %q got %q", headers, value, site.Headers)
+		}
+	}
+
+	var site db.Site
+	if err := field.set(&site, `X-A: 1 | X-B: 2\|3 | `); err != nil || site.Headers != "X-A: 1\nX-B: 2|3" {
+		t.Errorf("got headers %q (%v)", site.Headers, err)
+	}
+	if err := field.set(&site, `X-A: 1 \| X-B`); err != nil || site.Headers != "X-A: 1 | X-B" {
+		t.Errorf("got headers %q (%v)", site.Headers, err)
+	}
+	if err := field.set(&site, "X-A: 1 | invalid"); err == nil {
+		t.Errorf("got no error for an invalid header")
+	}
+}
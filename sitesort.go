@@ -0,0 +1,168 @@
+/*
+   Terminews is a terminal based (TUI) RSS feed manager.
+   Copyright (C) 2017  Alexandros Ntavelos, a[dot]ntavelos[at]gmail[dot]com
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/antavelos/terminews/db"
+	c "github.com/jroimartin/gocui"
+)
+
+// the orders of the sites list
+const (
+	sortManual  = "manual"
+	sortName    = "name"
+	sortUnread  = "unread"
+	sortUpdated = "updated"
+	sortFailing = "failing"
+)
+
+var siteSortModes = []string{sortManual, sortName, sortUnread, sortUpdated, sortFailing}
+
+// sitesSortState stores the order of the sites list across sessions
+const sitesSortState = "state.sites_sort"
+
+func validateSiteSort(value string) error {
+	if containsString(siteSortModes, value) {
+		return nil
+	}
+	return fmt.Errorf("'%v' is not one of %v", value, strings.Join(siteSortModes, ", "))
+}
+
+// loadSitesSort returns the stored order of the sites list
+func loadSitesSort() string {
+	mode, err := App.tdb.GetSetting(sitesSortState)
+	if err != nil || validateSiteSort(mode) != nil {
+		return sortManual
+	}
+	return mode
+}
+
+// nextSiteSort returns the order following the given one
+func nextSiteSort(mode string) string {
+	for i, m := range siteSortModes {
+		if m == mode {
+			return siteSortModes[(i+1)%len(siteSortModes)]
+		}
+	}
+	return sortManual
+}
+
+// sortSites sorts the sites, which are in their manual order, according to
+// the given mode
+func sortSites(sites []db.Site, mode string, health map[int]db.SiteHealth, unread map[int]int) {
+	var less func(a, b db.Site) bool
+	switch mode {
+	case sortName:
+		less = func(a, b db.Site) bool {
+			return strings.ToLower(a.Name) < strings.ToLower(b.Name)
+		}
+	case sortUnread:
+		less = func(a, b db.Site) bool {
+			return unread[a.Id] > unread[b.Id]
+		}
+	case sortUpdated:
+		less = func(a, b db.Site) bool {
+			return health[a.Id].LastSuccess.After(health[b.Id].LastSuccess)
+		}
+	case sortFailing:
+		less = func(a, b db.Site) bool {
+			return health[a.Id].Failing() && !health[b.Id].Failing()
+		}
+	default:
+		return
+	}
+	sort.SliceStable(sites, func(i, j int) bool {
+		return less(sites[i], sites[j])
+	})
+}
+
+// sitesTitle returns the title of the sites list mentioning its order
+func sitesTitle() string {
+	if App.SitesSort == sortManual {
+		return "Sites"
+	}
+	return fmt.Sprintf("Sites (by %v)", App.SitesSort)
+}
+
+// selectSite selects the site with the given id in the sites list
+func selectSite(id int) error {
+	for i, item := range App.SitesList.items {
+		if item.(db.Site).Id == id {
+			return App.SitesList.Select(i)
+		}
+	}
+	return nil
+}
+
+func SortSites(g *c.Gui, v *c.View) error {
+	App.SitesSort = nextSiteSort(App.SitesSort)
+	if err := App.tdb.SetSetting(sitesSortState, App.SitesSort); err != nil {
+		return err
+	}
+
+	var selected int
+	if currItem := App.SitesList.CurrentItem(); currItem != nil {
+		selected = currItem.(db.Site).Id
+	}
+	if err := LoadSites(); err != nil {
+		return err
+	}
+	showInfo(g, "Sites sorted by %v", App.SitesSort)
+
+	return selectSite(selected)
+}
+
+func MoveSiteUp(g *c.Gui, v *c.View) error {
+	return moveSite(g, -1)
+}
+
+func MoveSiteDown(g *c.Gui, v *c.View) error {
+	return moveSite(g, 1)
+}
+
+// moveSite moves the selected site by the given offset in the manual order of
+// the sites list
+func moveSite(g *c.Gui, offset int) error {
+	if App.SitesSort != sortManual {
+		showWarning(g, "The sites are sorted by %v, Ctrl+t switches to the manual order", App.SitesSort)
+		return nil
+	}
+	idx := App.SitesList.CurrentIdx()
+	to := idx + offset
+	if idx < 0 || to < 0 || to >= len(App.SitesList.items) {
+		return nil
+	}
+
+	ids := make([]int, len(App.SitesList.items))
+	for i, item := range App.SitesList.items {
+		ids[i] = item.(db.Site).Id
+	}
+	ids[idx], ids[to] = ids[to], ids[idx]
+	if err := App.tdb.SetSitePositions(ids); err != nil {
+		return err
+	}
+	if err := LoadSites(); err != nil {
+		return err
+	}
+
+	return App.SitesList.Select(to)
+}
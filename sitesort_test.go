@@ -0,0 +1,57 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/antavelos/terminews/db"
+)
+
+func TestSortSites(t *testing.T) {
+	now := time.Now()
+	health := map[int]db.SiteHealth{
+		1: {LastSuccess: now.Add(-time.Hour)},
+		2: {LastSuccess: now.Add(-2 * time.Hour), ConsecutiveFailures: 2},
+		3: {LastSuccess: now},
+	}
+	unread := map[int]int{2: 5, 3: 1}
+
+	tests := []struct {
+		mode string
+		ids  []int
+	}{
+		{sortManual, []int{1, 2, 3, 4}},
+		{sortName, []int{4, 3, 1, 2}},
+		{sortUnread, []int{2, 3, 1, 4}},
+		{sortUpdated, []int{3, 1, 2, 4}},
+		{sortFailing, []int{2, 1, 3, 4}},
+	}
+	for _, test := range tests {
+		sites := []db.Site{{Id: 1, Name: "golang"}, {Id: 2, Name: "Hacker News"}, {Id: 3, Name: "BBC"}, {Id: 4, Name: "ars"}}
+		sortSites(sites, test.mode, health, unread)
+		var ids []int
+		for _, s := range sites {
+			ids = append(ids, s.Id)
+		}
+		if !reflect.DeepEqual(ids, test.ids) {
+			t.Errorf("%v: got %v, want %v", test.mode, ids, test.ids)
+		}
+	}
+}
+
+func TestNextSiteSort(t *testing.T) {
+	mode := sortManual
+	for range siteSortModes {
+		if err := validateSiteSort(mode); err != nil {
+			t.Errorf("got invalid mode %q: %v", mode, err)
+		}
+		mode = nextSiteSort(mode)
+	}
+	if mode != sortManual {
+		t.Errorf("got %q after cycling through the modes, want %q", mode, sortManual)
+	}
+	if err := validateSiteSort("random"); err == nil {
+		t.Errorf("got no error for an unknown mode")
+	}
+}
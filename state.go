@@ -0,0 +1,160 @@
+/*
+   Terminews is a terminal based (TUI) RSS feed manager.
+   Copyright (C) 2017  Alexandros Ntavelos, a[dot]ntavelos[at]gmail[dot]com
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+package main
+
+import (
+	"fmt"
+
+	c "github.com/jroimartin/gocui"
+)
+
+// newsSource is what the news list displays
+type newsSource string
+
+const (
+	newsNone      newsSource = ""
+	newsSite      newsSource = "site"
+	newsBookmarks newsSource = "bookmarks"
+	newsSearch    newsSource = "search"
+)
+
+// promptKind is the purpose of a prompt
+type promptKind int
+
+const (
+	promptNewSite promptKind = iota
+	promptSearch
+	promptEditSite
+	promptRule
+	promptMovedFeed
+	promptExport
+	promptDelete
+)
+
+// prompt is an input requested from the user
+type prompt struct {
+	kind promptKind
+	// from is the list focused once the prompt is closed
+	from *List
+	// submit is called with the input on Enter. It either closes the prompt
+	// or rejects the input with retryPrompt.
+	submit func(g *c.Gui, input string) error
+	// rejected indicates that the last input was invalid
+	rejected bool
+}
+
+// uiState is the state of the UI the handlers rely on instead of the titles
+// of the views
+type uiState struct {
+	// News is the source of the news list
+	News newsSource
+	// Prompt is the open prompt if any
+	Prompt *prompt
+}
+
+func (s *uiState) showNews(source newsSource) {
+	s.News = source
+}
+
+func (s *uiState) openPrompt(p *prompt) {
+	s.Prompt = p
+}
+
+// closePrompt returns the closed prompt if any
+func (s *uiState) closePrompt() *prompt {
+	p := s.Prompt
+	s.Prompt = nil
+	return p
+}
+
+func (s *uiState) rejectInput() {
+	if s.Prompt != nil {
+		s.Prompt.rejected = true
+	}
+}
+
+// focusColor returns the color of the frame of the focused view: the alert
+// one for a rejected input or a deletion to confirm, the bookmarks one for
+// the listed bookmarks
+func (s *uiState) focusColor(t theme, view string) c.Attribute {
+	switch view {
+	case PROMPT_VIEW:
+		if s.Prompt != nil && (s.Prompt.rejected || s.Prompt.kind == promptDelete) {
+			return t.Alert
+		}
+	case NEWS_VIEW:
+		if s.News == newsBookmarks {
+			return t.Bookmarks
+		}
+	}
+	return t.Focus
+}
+
+func isBookmarksNews() bool {
+	return App.UI.News == newsBookmarks
+}
+
+// refreshFocus colors the frame of the focused view according to the state
+func refreshFocus(g *c.Gui) {
+	name := ""
+	if v := g.CurrentView(); v != nil {
+		name = v.Name()
+	}
+	g.SelFgColor = App.UI.focusColor(App.Theme, name)
+}
+
+// openPrompt displays a prompt with the given title and initial input
+func openPrompt(g *c.Gui, p *prompt, title, input string) error {
+	if err := createPromptView(g, title); err != nil {
+		return err
+	}
+	App.UI.openPrompt(p)
+	pv, _ := g.View(PROMPT_VIEW)
+	fmt.Fprint(pv, input)
+	pv.SetCursor(len(input), 0)
+	refreshFocus(g)
+
+	return nil
+}
+
+// submitPrompt passes the input of the open prompt to its submit function
+func submitPrompt(g *c.Gui, input string) error {
+	if App.UI.Prompt == nil {
+		return closePrompt(g)
+	}
+	return App.UI.Prompt.submit(g, input)
+}
+
+// retryPrompt keeps the prompt open retitled after an invalid input
+func retryPrompt(g *c.Gui, title string) {
+	App.UI.rejectInput()
+	setTopWindowTitle(g, PROMPT_VIEW, title)
+	refreshFocus(g)
+}
+
+// closePrompt deletes the prompt view and focuses the list it was opened from
+func closePrompt(g *c.Gui) error {
+	p := App.UI.closePrompt()
+	if err := deletePromptView(g); err != nil && err != c.ErrUnknownView {
+		return err
+	}
+	if p != nil && p.from != nil {
+		return p.from.Focus(g)
+	}
+	return App.SitesList.Focus(g)
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/antavelos/terminews/db"
+	c "github.com/jroimartin/gocui"
+)
+
+func TestFocusColor(t *testing.T) {
+	var s uiState
+	th := themes[0]
+
+	if got := s.focusColor(th, NEWS_VIEW); got != th.Focus {
+		t.Errorf("got %v for the news, want the focus color", got)
+	}
+	s.showNews(newsBookmarks)
+	if got := s.focusColor(th, NEWS_VIEW); got != th.Bookmarks {
+		t.Errorf("got %v for the bookmarks, want the bookmarks color", got)
+	}
+	if got := s.focusColor(th, SITES_VIEW); got != th.Focus {
+		t.Errorf("got %v for the sites, want the focus color", got)
+	}
+
+	s.openPrompt(&prompt{kind: promptSearch})
+	if got := s.focusColor(th, PROMPT_VIEW); got != th.Focus {
+		t.Errorf("got %v for a new prompt, want the focus color", got)
+	}
+	s.rejectInput()
+	if got := s.focusColor(th, PROMPT_VIEW); got != th.Alert {
+		t.Errorf("got %v for a rejected input, want the alert color", got)
+	}
+	s.openPrompt(&prompt{kind: promptDelete})
+	if got := s.focusColor(th, PROMPT_VIEW); got != th.Alert {
+		t.Errorf("got %v for a deletion, want the alert color", got)
+	}
+}
+
+func TestPromptTransitions(t *testing.T) {
+	newTestApp(t)
+
+	var inputs []string
+	from := &List{}
+	p := &prompt{kind: promptExport, from: from, submit: func(g *c.Gui, input string) error {
+		inputs = append(inputs, input)
+		if !strings.HasSuffix(input, ".md") {
+			App.UI.rejectInput()
+			return nil
+		}
+		App.UI.closePrompt()
+		return nil
+	}}
+
+	App.UI.openPrompt(p)
+	if err := submitPrompt(nil, "news.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if App.UI.Prompt != p || !p.rejected {
+		t.Errorf("got prompt %+v, want the rejected export prompt", App.UI.Prompt)
+	}
+	if err := submitPrompt(nil, "news.md"); err != nil {
+		t.Fatal(err)
+	}
+	if App.UI.Prompt != nil {
+		t.Errorf("got prompt %+v, want it closed", App.UI.Prompt)
+	}
+	if len(inputs) != 2 || inputs[1] != "news.md" {
+		t.Errorf("got inputs %v, want both submitted", inputs)
+	}
+	if closed := App.UI.closePrompt(); closed != nil {
+		t.Errorf("closed prompt %+v twice", closed)
+	}
+}
+
+func TestFormatEventMarksBookmarks(t *testing.T) {
+	newTestApp(t)
+
+	e := db.Event{Title: "Go 1.16 is released", Url: "https://blog.golang.org/go1.16"}
+	if got := formatEvent(e); got != e.Title {
+		t.Errorf("got %q, want the plain title", got)
+	}
+	App.CurrentBookmarks = []db.Event{{Title: e.Title, Url: e.Url + "?utm_source=rss"}}
+	if got := formatEvent(e); got != bookmarkMarker+e.Title {
+		t.Errorf("got %q, want the marked title", got)
+	}
+}
@@ -0,0 +1,200 @@
+/*
+   Terminews is a terminal based (TUI) RSS feed manager.
+   Copyright (C) 2017  Alexandros Ntavelos, a[dot]ntavelos[at]gmail[dot]com
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+package main
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/fatih/color"
+	c "github.com/jroimartin/gocui"
+)
+
+// statusTimeout is the time a message is displayed in the status bar
+const statusTimeout = 10 * time.Second
+
+// maxMessages is the number of messages kept in the messages history
+const maxMessages = 200
+
+// statusHint is displayed in the status bar when there is no recent message
+const statusHint = "Ctrl+h: help  Ctrl+l: messages"
+
+type severity int
+
+const (
+	severityInfo severity = iota
+	severityWarning
+	severityError
+)
+
+func (s severity) String() string {
+	switch s {
+	case severityWarning:
+		return "warning"
+	case severityError:
+		return "error"
+	default:
+		return "info"
+	}
+}
+
+// statusMessage is a message displayed in the status bar and kept in the
+// messages history
+type statusMessage struct {
+	Time     time.Time
+	Severity severity
+	Text     string
+}
+
+var (
+	warningColor = color.New(color.FgYellow, color.Bold)
+	errorColor   = color.New(color.FgRed, color.Bold)
+)
+
+// colored returns the text colored according to the severity of the message
+func (m statusMessage) colored(text string) string {
+	switch m.Severity {
+	case severityWarning:
+		return warningColor.Sprint(text)
+	case severityError:
+		return errorColor.Sprint(text)
+	default:
+		return text
+	}
+}
+
+// formatMessage is the format function of the messages list
+func formatMessage(item interface{}) string {
+	m := item.(statusMessage)
+	return fmt.Sprintf("%v %v", m.Time.Format("15:04:05"), m.colored(fmt.Sprintf("%-7v %v", m.Severity, m.Text)))
+}
+
+// addMessage appends a message to the messages history dropping the oldest
+// ones beyond maxMessages
+func addMessage(sev severity, text string) statusMessage {
+	m := statusMessage{time.Now(), sev, text}
+	log.Printf("[%v] %v", sev, text)
+	App.Messages = append(App.Messages, m)
+	if len(App.Messages) > maxMessages {
+		App.Messages = append([]statusMessage(nil), App.Messages[len(App.Messages)-maxMessages:]...)
+	}
+	return m
+}
+
+// showMessage displays a message in the status bar for statusTimeout and
+// keeps it in the messages history. It must be called from the main loop.
+func showMessage(g *c.Gui, sev severity, format string, args ...interface{}) {
+	addMessage(sev, fmt.Sprintf(format, args...))
+	if err := drawStatus(g); err != nil {
+		log.Println("Error on drawStatus", err)
+	}
+	if _, err := g.View(MESSAGES_VIEW); err == nil {
+		if err := loadMessagesList(); err != nil {
+			log.Println("Error on loadMessagesList", err)
+		}
+	}
+	time.AfterFunc(statusTimeout, func() {
+		App.Driver.update(g, drawStatus)
+	})
+}
+
+func showInfo(g *c.Gui, format string, args ...interface{}) {
+	showMessage(g, severityInfo, format, args...)
+}
+
+func showWarning(g *c.Gui, format string, args ...interface{}) {
+	showMessage(g, severityWarning, format, args...)
+}
+
+func showError(g *c.Gui, err error) {
+	showMessage(g, severityError, "%v", err)
+}
+
+// drawStatus displays the last message in the status bar unless it has
+// expired, in which case the keys hint is displayed
+func drawStatus(g *c.Gui) error {
+	v, err := g.View(STATUS_VIEW)
+	if err != nil {
+		return nil
+	}
+	v.Clear()
+	if len(App.Messages) > 0 {
+		if m := App.Messages[len(App.Messages)-1]; time.Since(m.Time) < statusTimeout {
+			_, err = fmt.Fprint(v, " "+m.colored(m.Text))
+			return err
+		}
+	}
+	_, err = fmt.Fprint(v, " "+App.Dim.Sprint(statusHint))
+	return err
+}
+
+// reportError displays the error returned by a handler in the status bar so
+// that it does not terminate the main loop
+func reportError(g *c.Gui, err error) error {
+	if err == nil || err == c.ErrQuit {
+		return err
+	}
+	showError(g, err)
+	return nil
+}
+
+// guard wraps a keybinding handler so that its errors are reported in the
+// status bar
+func guard(handler func(*c.Gui, *c.View) error) func(*c.Gui, *c.View) error {
+	return func(g *c.Gui, v *c.View) error {
+		return reportError(g, handler(g, v))
+	}
+}
+
+// update schedules a function to be run by the main loop reporting its error
+// in the status bar
+func update(g *c.Gui, f func(*c.Gui) error) {
+	App.Driver.update(g, func(g *c.Gui) error {
+		return reportError(g, f(g))
+	})
+}
+
+// createMessagesView creates a view listing the messages history, the most
+// recent first
+func createMessagesView(g *c.Gui) error {
+	tw, th := App.Driver.size(g)
+	v, err := g.SetView(MESSAGES_VIEW, tw/8, th/8, (tw*7)/8, (th*7)/8)
+	if err != nil && err != c.ErrUnknownView {
+		return err
+	}
+	App.MessagesList = CreateList(v, false)
+	App.MessagesList.format = formatMessage
+	if err = loadMessagesList(); err != nil {
+		return err
+	}
+
+	return App.MessagesList.Focus(g)
+}
+
+// loadMessagesList displays the messages history in the messages view
+func loadMessagesList() error {
+	App.MessagesList.Reset()
+	App.MessagesList.SetTitle(fmt.Sprintf("%v message(s) (Ctrl-q to close)", len(App.Messages)))
+	data := make([]interface{}, len(App.Messages))
+	for i, m := range App.Messages {
+		data[len(App.Messages)-1-i] = m
+	}
+
+	return App.MessagesList.SetItems(data)
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	c "github.com/jroimartin/gocui"
+)
+
+func TestAddMessage(t *testing.T) {
+	newTestApp(t)
+
+	for i := 0; i < maxMessages+10; i++ {
+		addMessage(severityInfo, fmt.Sprintf("message %v", i))
+	}
+	m := addMessage(severityError, "failed")
+
+	if len(App.Messages) != maxMessages {
+		t.Fatalf("got %v messages, want %v", len(App.Messages), maxMessages)
+	}
+	if App.Messages[0].Text != "message 11" {
+		t.Errorf("got oldest message %q, want %q", App.Messages[0].Text, "message 11")
+	}
+	if last := App.Messages[len(App.Messages)-1]; last != m || last.Severity != severityError {
+		t.Errorf("got last message %+v, want %+v", last, m)
+	}
+}
+
+func TestFormatMessage(t *testing.T) {
+	newTestApp(t)
+	m := addMessage(severityWarning, "Failed to refresh")
+
+	line := formatMessage(m)
+	if !strings.HasPrefix(line, m.Time.Format("15:04:05")) {
+		t.Errorf("got %q, want it prefixed by the time", line)
+	}
+	if !strings.Contains(ansiEscape.ReplaceAllString(line, ""), "warning Failed to refresh") {
+		t.Errorf("got %q, want the severity and the text", line)
+	}
+}
+
+func TestReportError(t *testing.T) {
+	if err := reportError(nil, nil); err != nil {
+		t.Errorf("got %v, want nil", err)
+	}
+	if err := reportError(nil, c.ErrQuit); err != c.ErrQuit {
+		t.Errorf("got %v, want %v", err, c.ErrQuit)
+	}
+}
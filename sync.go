@@ -0,0 +1,413 @@
+/*
+   Terminews is a terminal based (TUI) RSS feed manager.
+   Copyright (C) 2017  Alexandros Ntavelos, a[dot]ntavelos[at]gmail[dot]com
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/antavelos/terminews/db"
+)
+
+// lastSyncState stores the time of the last synchronization
+const lastSyncState = "state.last_sync"
+
+// syncOverlap is subtracted from the time of the last synchronization when
+// requesting the new news, so that the ones crawled meanwhile are not missed
+const syncOverlap = time.Hour
+
+// the policies resolving the state of the news never synchronized which
+// differs locally and remotely
+const (
+	// syncMerge keeps a news read or starred if it is on either side
+	syncMerge  = "merge"
+	syncLocal  = "local"
+	syncRemote = "remote"
+)
+
+func validateSyncPolicy(value string) error {
+	switch value {
+	case syncMerge, syncLocal, syncRemote:
+		return nil
+	}
+	return fmt.Errorf("'%v' is not one of %v, %v or %v", value, syncMerge, syncLocal, syncRemote)
+}
+
+// syncReport counts the changes made by a synchronization
+type syncReport struct {
+	SitesAdded, SitesPushed  int
+	Stored                   int
+	Read, Unread             int
+	PushedRead, PushedUnread int
+	Bookmarked, Unbookmarked int
+	Starred, Unstarred       int
+}
+
+func (r syncReport) String() string {
+	return fmt.Sprintf("Sites: %v added, %v subscribed on the server\n"+
+		"News: %v stored\n"+
+		"Read: %v marked read and %v unread locally, %v and %v on the server\n"+
+		"Bookmarks: %v added and %v removed locally, %v starred and %v unstarred on the server\n",
+		r.SitesAdded, r.SitesPushed, r.Stored,
+		r.Read, r.Unread, r.PushedRead, r.PushedUnread,
+		r.Bookmarked, r.Unbookmarked, r.Starred, r.Unstarred)
+}
+
+// newReaderClient returns a client of the configured sync server
+func newReaderClient() (*readerClient, error) {
+	c := &readerClient{
+		url:    getSetting("sync.url"),
+		user:   getSetting("sync.user"),
+		client: App.Web.Client(),
+	}
+	if len(c.url) == 0 {
+		return nil, errors.New("no sync server configured, set sync.url")
+	}
+	password, err := resolveSecret(getSetting("sync.password"))
+	if err != nil {
+		return nil, fmt.Errorf("no password of the sync server: %v", err)
+	}
+	c.password = password
+	return c, nil
+}
+
+// mergeState returns the state of a news which changed on at most one side
+// since the last synchronization, both changing it means they agree
+func mergeState(base, local, remote bool) bool {
+	if local != base {
+		return local
+	}
+	return remote
+}
+
+// resolveState returns the state of a news never synchronized according to
+// the policy
+func resolveState(policy string, local, remote bool) bool {
+	switch policy {
+	case syncLocal:
+		return local
+	case syncRemote:
+		return remote
+	}
+	return local || remote
+}
+
+// syncReader synchronizes the sites, the news, their read state and the
+// bookmarks with the starred news of a Google Reader API server. The changes
+// made on one side since the last synchronization are applied to the other
+// one, the policy resolves the differences of the news never synchronized.
+func syncReader(c *readerClient, policy string) (syncReport, error) {
+	var report syncReport
+	if err := c.login(); err != nil {
+		return report, err
+	}
+
+	// the subscriptions missing on either side are added, none is removed
+	subs, err := c.subscriptions()
+	if err != nil {
+		return report, err
+	}
+	sites, err := App.tdb.GetSites()
+	if err != nil {
+		return report, err
+	}
+	siteByUrl := map[string]db.Site{}
+	for _, site := range sites {
+		siteByUrl[site.Url] = site
+	}
+	remoteSites := map[string]bool{}
+	for _, s := range subs {
+		u := s.feedUrl()
+		remoteSites[u] = true
+		if _, ok := siteByUrl[u]; ok {
+			continue
+		}
+		name := s.Title
+		if len(name) == 0 {
+			name = u
+		}
+		if err := App.tdb.AddSite(db.Site{Name: name, Url: u}); err != nil {
+			return report, err
+		}
+		if siteByUrl[u], err = App.tdb.GetSiteByUrl(u); err != nil {
+			return report, err
+		}
+		report.SitesAdded++
+	}
+	for _, site := range sites {
+		if !remoteSites[site.Url] {
+			if err := c.subscribe(site.Url, site.Name); err != nil {
+				return report, err
+			}
+			report.SitesPushed++
+		}
+	}
+
+	// the news crawled since the last synchronization
+	var since time.Time
+	if last, err := App.tdb.GetSetting(lastSyncState); err == nil {
+		if t, err := time.Parse(time.RFC3339, last); err == nil {
+			since = t.Add(-syncOverlap)
+		}
+	}
+	items, err := c.streamContents(readerReadingList, since)
+	if err != nil {
+		return report, err
+	}
+	unread, err := c.itemIds(readerReadingList, readerRead)
+	if err != nil {
+		return report, err
+	}
+	starred, err := c.itemIds(readerStarred, "")
+	if err != nil {
+		return report, err
+	}
+	base, err := App.tdb.GetSyncItems()
+	if err != nil {
+		return report, err
+	}
+	bookmarks, err := App.tdb.GetEvents()
+	if err != nil {
+		return report, err
+	}
+	bookmarkByUrl := map[string]db.Event{}
+	for _, e := range bookmarks {
+		bookmarkByUrl[e.Url] = e
+	}
+
+	remoteItems := map[string]readerItem{}
+	for _, it := range items {
+		remoteItems[readerItemId(it.Id)] = it
+	}
+	// the starred news not bookmarked yet are needed to bookmark them
+	var missing []string
+	for id := range starred {
+		if _, ok := remoteItems[id]; ok {
+			continue
+		}
+		if si, ok := base[id]; ok {
+			if _, ok := bookmarkByUrl[si.Url]; ok {
+				continue
+			}
+		}
+		missing = append(missing, id)
+	}
+	more, err := c.itemContents(missing)
+	if err != nil {
+		return report, err
+	}
+	for _, it := range more {
+		remoteItems[readerItemId(it.Id)] = it
+	}
+
+	// the news stored by the synchronization have no local state
+	fresh := map[string]bool{}
+	for _, it := range remoteItems {
+		site, ok := siteByUrl[it.feedUrl()]
+		if !ok {
+			continue
+		}
+		added, err := App.tdb.SaveItems(site.Id, []db.Event{it.event()})
+		if err != nil {
+			return report, err
+		}
+		for _, e := range added {
+			fresh[e.Url] = true
+		}
+		report.Stored += len(added)
+	}
+
+	states, err := App.tdb.GetItemStates()
+	if err != nil {
+		return report, err
+	}
+	ids := map[string]bool{}
+	for id := range base {
+		ids[id] = true
+	}
+	for id := range remoteItems {
+		ids[id] = true
+	}
+
+	var pushRead, pushUnread, pushStar, pushUnstar []string
+	// the synchronized states are stored once pushed
+	synced := map[string]db.SyncItem{}
+	for id := range ids {
+		si, known := base[id]
+		it, fetched := remoteItems[id]
+		url := si.Url
+		if !known {
+			url = it.url()
+		}
+		if len(url) == 0 {
+			continue
+		}
+
+		remoteRead, remoteStarred := !unread[id], starred[id]
+		storedRead, stored := states[url]
+		bookmark, localStarred := bookmarkByUrl[url]
+		localRead := storedRead
+		var read, star bool
+		switch {
+		case known:
+			if !stored || fresh[url] {
+				// the news was pruned, its state is unchanged
+				localRead = si.Read
+			}
+			read = mergeState(si.Read, localRead, remoteRead)
+			star = mergeState(si.Starred, localStarred, remoteStarred)
+		default:
+			if !stored || fresh[url] {
+				localRead = remoteRead
+			}
+			read = resolveState(policy, localRead, remoteRead)
+			star = resolveState(policy, localStarred, remoteStarred)
+		}
+
+		if stored && read != storedRead {
+			if err := App.tdb.SetItemsRead(url, read); err != nil {
+				return report, err
+			}
+			if read {
+				report.Read++
+			} else {
+				report.Unread++
+			}
+		}
+		if read != remoteRead {
+			if read {
+				pushRead = append(pushRead, id)
+			} else {
+				pushUnread = append(pushUnread, id)
+			}
+		}
+
+		if star && !localStarred {
+			e, ok := storedEvent(url)
+			if !ok && fetched {
+				e, ok = it.event(), true
+			}
+			if !ok {
+				// the news was not received, it is left unsynchronized
+				// so that it is bookmarked by a next synchronization
+				continue
+			}
+			if err := App.tdb.AddEvent(e); err != nil {
+				return report, err
+			}
+			report.Bookmarked++
+		}
+		if !star && localStarred {
+			if err := App.tdb.DeleteEvent(bookmark.Id); err != nil {
+				return report, err
+			}
+			report.Unbookmarked++
+		}
+		if star != remoteStarred {
+			if star {
+				pushStar = append(pushStar, id)
+			} else {
+				pushUnstar = append(pushUnstar, id)
+			}
+		}
+
+		synced[id] = db.SyncItem{RemoteId: id, Url: url, Read: read, Starred: star}
+	}
+
+	var pushErr error
+	for _, push := range []struct {
+		ids    []string
+		state  string
+		remove bool
+		count  *int
+	}{
+		{pushRead, readerRead, false, &report.PushedRead},
+		{pushUnread, readerRead, true, &report.PushedUnread},
+		{pushStar, readerStarred, false, &report.Starred},
+		{pushUnstar, readerStarred, true, &report.Unstarred},
+	} {
+		if err := c.editTag(push.ids, push.state, push.remove); err != nil {
+			// the remote state is stored instead so that the local one is
+			// pushed again by the next synchronization
+			for _, id := range push.ids {
+				si := synced[id]
+				if push.state == readerRead {
+					si.Read = push.remove
+				} else {
+					si.Starred = push.remove
+				}
+				synced[id] = si
+			}
+			if pushErr == nil {
+				pushErr = err
+			}
+			continue
+		}
+		*push.count = len(push.ids)
+	}
+	for _, si := range synced {
+		if err := App.tdb.SaveSyncItem(si); err != nil {
+			return report, err
+		}
+	}
+	if pushErr != nil {
+		return report, pushErr
+	}
+
+	return report, App.tdb.SetSetting(lastSyncState, time.Now().Format(time.RFC3339))
+}
+
+// storedEvent returns a stored news with the given URL if any
+func storedEvent(url string) (db.Event, bool) {
+	items, _, err := App.tdb.FindItems(db.ItemFilter{Url: url, Limit: 1})
+	if err != nil || len(items) == 0 {
+		return db.Event{}, false
+	}
+	return items[0], true
+}
+
+func syncCommand(args []string) error {
+	fs := flag.NewFlagSet("sync", flag.ContinueOnError)
+	reset := fs.Bool("reset", false, "forget the state of the last synchronization, the news are then resolved according to sync.conflict")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		fs.Usage()
+		return errors.New("unexpected arguments")
+	}
+
+	c, err := newReaderClient()
+	if err != nil {
+		return err
+	}
+	if *reset {
+		if err := App.tdb.DeleteSyncItems(); err != nil {
+			return err
+		}
+		if err := App.tdb.DeleteSetting(lastSyncState); err != nil {
+			return err
+		}
+	}
+
+	report, err := syncReader(c, getSetting("sync.conflict"))
+	fmt.Print(report)
+	return err
+}
@@ -0,0 +1,299 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/antavelos/terminews/db"
+)
+
+// fakeReader is an in-process Google Reader API server
+type fakeReader struct {
+	sync.Mutex
+	subs  []readerSubscription
+	items []*fakeReaderItem
+	// failEdits fails the changes of the states of the news
+	failEdits bool
+}
+
+type fakeReaderItem struct {
+	readerItem
+	read, starred bool
+}
+
+func (f *fakeReader) addItem(feedUrl, url string, read, starred bool) *fakeReaderItem {
+	it := &fakeReaderItem{read: read, starred: starred}
+	it.Id = fmt.Sprintf("tag:google.com,2005:reader/item/%016x", len(f.items)+1)
+	it.Title = "Title of " + url
+	it.Alternate = []readerLink{{url}}
+	it.Summary.Content = "<p>Summary</p>"
+	it.Published = 1613476800
+	it.Origin.StreamId = readerFeedPrefix + feedUrl
+	f.items = append(f.items, it)
+	return it
+}
+
+func (f *fakeReader) item(id string) *fakeReaderItem {
+	for _, it := range f.items {
+		if readerItemId(it.Id) == readerItemId(id) {
+			return it
+		}
+	}
+	return nil
+}
+
+func (f *fakeReader) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.Lock()
+	defer f.Unlock()
+	r.ParseForm()
+
+	if r.URL.Path == "/accounts/ClientLogin" {
+		if r.Form.Get("Email") != "user" || r.Form.Get("Passwd") != "secret" {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprint(w, "SID=sid\nLSID=lsid\nAuth=auth-token\n")
+		return
+	}
+	if r.Header.Get("Authorization") != "GoogleLogin auth=auth-token" {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if r.Method == http.MethodPost && r.Form.Get("T") != "edit-token" && r.URL.Path != "/reader/api/0/stream/items/contents" {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	enc := json.NewEncoder(w)
+	switch r.URL.Path {
+	case "/reader/api/0/token":
+		fmt.Fprint(w, "edit-token\n")
+	case "/reader/api/0/subscription/list":
+		enc.Encode(map[string]interface{}{"subscriptions": f.subs})
+	case "/reader/api/0/subscription/edit":
+		f.subs = append(f.subs, readerSubscription{Id: r.Form.Get("s"), Title: r.Form.Get("t")})
+	case "/reader/api/0/stream/contents/" + readerReadingList:
+		// paginated by one to exercise the continuation
+		start, _ := strconv.Atoi(r.Form.Get("c"))
+		page := map[string]interface{}{"items": []readerItem{}}
+		if start < len(f.items) {
+			page["items"] = []readerItem{f.items[start].readerItem}
+			page["continuation"] = strconv.Itoa(start + 1)
+		}
+		enc.Encode(page)
+	case "/reader/api/0/stream/items/ids":
+		var refs []map[string]string
+		for _, it := range f.items {
+			if (r.Form.Get("s") == readerStarred && !it.starred) || (r.Form.Get("xt") == readerRead && it.read) {
+				continue
+			}
+			refs = append(refs, map[string]string{"id": readerItemId(it.Id)})
+		}
+		enc.Encode(map[string]interface{}{"itemRefs": refs})
+	case "/reader/api/0/stream/items/contents":
+		var items []readerItem
+		for _, id := range r.Form["i"] {
+			if it := f.item(id); it != nil {
+				items = append(items, it.readerItem)
+			}
+		}
+		enc.Encode(map[string]interface{}{"items": items})
+	case "/reader/api/0/edit-tag":
+		if f.failEdits {
+			http.Error(w, "Unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		for _, id := range r.Form["i"] {
+			it := f.item(id)
+			if it == nil {
+				continue
+			}
+			for _, state := range []string{r.Form.Get("a"), r.Form.Get("r")} {
+				set := state == r.Form.Get("a")
+				switch state {
+				case readerRead:
+					it.read = set
+				case readerStarred:
+					it.starred = set
+				}
+			}
+		}
+		fmt.Fprint(w, "OK")
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func TestSyncReader(t *testing.T) {
+	newTestDB(t)
+
+	const feedA, feedB, feedC = "http://a.example.org/feed", "http://b.example.org/feed", "http://c.example.org/feed"
+	f := &fakeReader{subs: []readerSubscription{
+		{Id: readerFeedPrefix + feedB, Title: "B"},
+		{Id: readerFeedPrefix + feedC, Title: "C", Url: feedC},
+	}}
+	b1 := f.addItem(feedB, "http://b.example.org/1", false, false)
+	b2 := f.addItem(feedB, "http://b.example.org/2", true, false)
+	c1 := f.addItem(feedC, "http://c.example.org/1", false, true)
+	ts := httptest.NewServer(f)
+	defer ts.Close()
+
+	App.tdb.AddSite(db.Site{Name: "A", Url: feedA})
+	App.tdb.AddSite(db.Site{Name: "B", Url: feedB})
+	siteB, _ := App.tdb.GetSiteByUrl(feedB)
+	App.tdb.SaveItems(siteB.Id, []db.Event{{Title: "1", Url: "http://b.example.org/1"}})
+	App.tdb.MarkItemRead("http://b.example.org/1")
+
+	App.tdb.SetSetting("sync.url", ts.URL)
+	App.tdb.SetSetting("sync.user", "user")
+	App.tdb.SetSetting("sync.password", "env:TERMINEWS_TEST_SYNC_PASSWORD")
+	defer setenv(map[string]string{"TERMINEWS_TEST_SYNC_PASSWORD": "secret"})()
+	c, err := newReaderClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := syncReader(c, syncMerge)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := syncReport{SitesAdded: 1, SitesPushed: 1, Stored: 2, Read: 1, PushedRead: 1, Bookmarked: 1}
+	if report != want {
+		t.Errorf("First sync got %+v, want %+v", report, want)
+	}
+	if len(f.subs) != 3 || f.subs[2].Id != readerFeedPrefix+feedA || f.subs[2].Title != "A" {
+		t.Errorf("Server got subscriptions %+v, want A subscribed", f.subs)
+	}
+	if _, err := App.tdb.GetSiteByUrl(feedC); err != nil {
+		t.Errorf("Site C was not added: %v", err)
+	}
+	if !b1.read {
+		t.Errorf("The news read locally was not marked read on the server")
+	}
+	if states, _ := App.tdb.GetItemStates(); !states["http://b.example.org/2"] || states["http://c.example.org/1"] {
+		t.Errorf("Got local states %v, want the news read on the server read", states)
+	}
+	if e, err := App.tdb.GetEventByUrl("http://c.example.org/1"); err != nil || e.Title != "Title of http://c.example.org/1" || e.Summary != "Summary" {
+		t.Errorf("The starred news was not bookmarked: %+v, %v", e, err)
+	}
+
+	// changes on both sides
+	b1.read = false
+	c1.starred = false
+	f.addItem(feedC, "http://c.example.org/2", true, false)
+	App.tdb.SetItemsRead("http://b.example.org/2", false)
+	if e, ok := storedEvent("http://b.example.org/2"); ok {
+		App.tdb.AddEvent(e)
+	}
+
+	if report, err = syncReader(c, syncLocal); err != nil {
+		t.Fatal(err)
+	}
+	want = syncReport{Stored: 1, Read: 1, Unread: 1, PushedUnread: 1, Unbookmarked: 1, Starred: 1}
+	if report != want {
+		t.Errorf("Second sync got %+v, want %+v", report, want)
+	}
+	if b2.read || !b2.starred || c1.starred {
+		t.Errorf("Server got %+v and %+v, want the local changes", b2, c1)
+	}
+	states, _ := App.tdb.GetItemStates()
+	if states["http://b.example.org/1"] || !states["http://c.example.org/2"] {
+		t.Errorf("Got local states %v, want the remote changes", states)
+	}
+	if _, err := App.tdb.GetEventByUrl("http://c.example.org/1"); err == nil {
+		t.Errorf("The unstarred news is still bookmarked")
+	}
+
+	// nothing changed
+	if report, err = syncReader(c, syncRemote); err != nil || report != (syncReport{}) {
+		t.Errorf("Third sync got %+v (%v), want no change", report, err)
+	}
+
+	// the local changes which failed to be pushed are pushed by the next sync
+	App.tdb.SetItemsRead("http://b.example.org/1", true)
+	f.failEdits = true
+	if _, err = syncReader(c, syncMerge); err == nil {
+		t.Errorf("Expected error for a failed push")
+	}
+	f.failEdits = false
+	if report, err = syncReader(c, syncMerge); err != nil || report != (syncReport{PushedRead: 1}) {
+		t.Errorf("Sync after a failed push got %+v (%v), want the read news pushed", report, err)
+	}
+	if states, _ := App.tdb.GetItemStates(); !b1.read || !states["http://b.example.org/1"] {
+		t.Errorf("Server got %+v, local states %v, want the news read on both sides", b1, states)
+	}
+
+	c.password = "wrong"
+	if _, err = syncReader(c, syncMerge); err == nil {
+		t.Errorf("Expected error for a wrong password")
+	}
+}
+
+func TestResolveState(t *testing.T) {
+	for _, test := range []struct {
+		policy        string
+		local, remote bool
+		want          bool
+	}{
+		{syncMerge, true, false, true},
+		{syncMerge, false, true, true},
+		{syncMerge, false, false, false},
+		{syncLocal, false, true, false},
+		{syncLocal, true, false, true},
+		{syncRemote, true, false, false},
+		{syncRemote, false, true, true},
+	} {
+		if got := resolveState(test.policy, test.local, test.remote); got != test.want {
+			t.Errorf("resolveState(%v, %v, %v) = %v, want %v", test.policy, test.local, test.remote, got, test.want)
+		}
+	}
+	for _, test := range []struct {
+		base, local, remote bool
+		want                bool
+	}{
+		{false, true, false, true},
+		{false, false, true, true},
+		{true, false, true, false},
+		{true, true, false, false},
+		{true, false, false, false},
+		{true, true, true, true},
+	} {
+		if got := mergeState(test.base, test.local, test.remote); got != test.want {
+			t.Errorf("mergeState(%v, %v, %v) = %v, want %v", test.base, test.local, test.remote, got, test.want)
+		}
+	}
+}
+
+func TestReaderItemId(t *testing.T) {
+	for id, want := range map[string]string{
+		"tag:google.com,2005:reader/item/000000000000001f": "31",
+		"tag:google.com,2005:reader/item/ffffffffffffffff": "-1",
+		"31": "31",
+	} {
+		if got := readerItemId(id); got != want {
+			t.Errorf("readerItemId(%v) = %v, want %v", id, got, want)
+		}
+	}
+}
+
+func TestReaderItemEvent(t *testing.T) {
+	var it readerItem
+	it.Title = "Go 1.16"
+	it.Canonical = []readerLink{{"https://blog.golang.org/go1.16"}}
+	it.Alternate = []readerLink{{"https://blog.golang.org/go1.16?utm_source=rss"}}
+	it.Content.Content = "<p>Modules on</p>"
+	it.Categories = []string{readerRead, readerLabelPrefix + "Go"}
+	it.Published = 1613476800
+
+	e := it.event()
+	if e.Url != "https://blog.golang.org/go1.16" || e.Summary != "Modules on" ||
+		e.Published != "Tue, 16 Feb 2021 12:00:00 +0000" || strings.Join(e.Categories, ",") != "Go" {
+		t.Errorf("got %+v", e)
+	}
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/antavelos/terminews/db"
+)
+
+// newTestDB replaces the DB of the app with a new one in a temporary directory,
+// which is returned. The DB is closed, the directory removed and the previous
+// DB restored once the test completes.
+func newTestDB(t *testing.T) string {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "terminews")
+	if err != nil {
+		t.Fatal(err)
+	}
+	saved := App.tdb
+	test, err := db.InitDB(dir)
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	App.tdb = test
+	t.Cleanup(func() {
+		test.Close()
+		App.tdb = saved
+		os.RemoveAll(dir)
+	})
+
+	return dir
+}
@@ -0,0 +1,275 @@
+/*
+   Terminews is a terminal based (TUI) RSS feed manager.
+   Copyright (C) 2017  Alexandros Ntavelos, a[dot]ntavelos[at]gmail[dot]com
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/fatih/color"
+	c "github.com/jroimartin/gocui"
+)
+
+const (
+	themeAuto  = "auto"
+	colorsAuto = "auto"
+	colors8    = "8"
+	colors256  = "256"
+)
+
+// theme holds the colors of every element of the UI
+type theme struct {
+	name string
+	// needs256 indicates that the theme uses the 256 colors palette
+	needs256 bool
+	// Foreground and Background are the colors of the text and the frames of
+	// the views
+	Foreground, Background c.Attribute
+	// Focus, Bookmarks and Alert are the colors of the frame of the focused
+	// view, when it displays a site's news, the bookmarks or a failed input
+	Focus, Bookmarks, Alert c.Attribute
+	// SelectedFg and SelectedBg are the colors of the selected list item
+	SelectedFg, SelectedBg c.Attribute
+	// Bold, Dim, Warning and Error are the escape sequences of the text
+	// written in the views, e.g. the labels of the summary or the read news
+	Bold, Dim, Warning, Error []color.Attribute
+}
+
+// palette returns the attribute of a color of the 256 colors palette
+func palette(n int) c.Attribute {
+	return c.Attribute(n + 1)
+}
+
+// fg256 returns the escape sequence of a foreground color of the 256 colors
+// palette. The extended color must come first for gocui to parse it.
+func fg256(n int, attrs ...color.Attribute) []color.Attribute {
+	return append([]color.Attribute{38, 5, color.Attribute(n)}, attrs...)
+}
+
+var themes = []theme{
+	{
+		name:       "dark",
+		Foreground: c.ColorDefault,
+		Background: c.ColorDefault,
+		Focus:      c.ColorGreen | c.AttrBold,
+		Bookmarks:  c.ColorMagenta | c.AttrBold,
+		Alert:      c.ColorRed | c.AttrBold,
+		SelectedFg: c.ColorWhite | c.AttrBold,
+		SelectedBg: c.ColorBlack,
+		Bold:       []color.Attribute{color.Bold},
+		Dim:        []color.Attribute{color.FgBlack, color.Bold},
+		Warning:    []color.Attribute{color.FgYellow, color.Bold},
+		Error:      []color.Attribute{color.FgRed, color.Bold},
+	},
+	{
+		name:       "light",
+		Foreground: c.ColorDefault,
+		Background: c.ColorDefault,
+		Focus:      c.ColorBlue | c.AttrBold,
+		Bookmarks:  c.ColorMagenta | c.AttrBold,
+		Alert:      c.ColorRed | c.AttrBold,
+		SelectedFg: c.ColorBlack | c.AttrBold,
+		SelectedBg: c.ColorCyan,
+		Bold:       []color.Attribute{color.Bold},
+		Dim:        []color.Attribute{color.FgCyan},
+		Warning:    []color.Attribute{color.FgMagenta, color.Bold},
+		Error:      []color.Attribute{color.FgRed, color.Bold},
+	},
+	{
+		name:       "high-contrast",
+		Foreground: c.ColorWhite | c.AttrBold,
+		Background: c.ColorBlack,
+		Focus:      c.ColorYellow | c.AttrBold,
+		Bookmarks:  c.ColorCyan | c.AttrBold,
+		Alert:      c.ColorRed | c.AttrBold,
+		SelectedFg: c.ColorBlack | c.AttrBold,
+		SelectedBg: c.ColorYellow,
+		Bold:       []color.Attribute{color.FgYellow, color.Bold},
+		Dim:        []color.Attribute{color.FgWhite},
+		Warning:    []color.Attribute{color.FgYellow, color.Bold, color.Underline},
+		Error:      []color.Attribute{color.FgRed, color.Bold, color.Underline},
+	},
+	{
+		// mono relies on the attributes only, see https://no-color.org
+		name:       "mono",
+		Foreground: c.ColorDefault,
+		Background: c.ColorDefault,
+		Focus:      c.AttrBold,
+		Bookmarks:  c.AttrBold | c.AttrUnderline,
+		Alert:      c.AttrReverse,
+		SelectedFg: c.AttrReverse,
+		SelectedBg: c.ColorDefault,
+		Bold:       []color.Attribute{color.Bold},
+		Dim:        []color.Attribute{color.Reset},
+		Warning:    []color.Attribute{color.Bold},
+		Error:      []color.Attribute{color.Bold, color.Underline},
+	},
+	{
+		name:       "dark-256",
+		needs256:   true,
+		Foreground: palette(252),
+		Background: palette(234),
+		Focus:      palette(114) | c.AttrBold,
+		Bookmarks:  palette(176) | c.AttrBold,
+		Alert:      palette(203) | c.AttrBold,
+		SelectedFg: palette(231) | c.AttrBold,
+		SelectedBg: palette(239),
+		Bold:       fg256(255, color.Bold),
+		Dim:        fg256(243),
+		Warning:    fg256(214, color.Bold),
+		Error:      fg256(203, color.Bold),
+	},
+}
+
+// findTheme returns the built-in theme with the given name if any
+func findTheme(name string) (theme, bool) {
+	for _, t := range themes {
+		if t.name == name {
+			return t, true
+		}
+	}
+	return theme{}, false
+}
+
+func themeNames() []string {
+	var names []string
+	for _, t := range themes {
+		names = append(names, t.name)
+	}
+	return names
+}
+
+func validateTheme(value string) error {
+	if _, ok := findTheme(value); !ok && value != themeAuto {
+		return fmt.Errorf("unknown theme '%v', expected %v or one of %v", value, themeAuto, strings.Join(themeNames(), ", "))
+	}
+	return nil
+}
+
+func validateColors(value string) error {
+	switch value {
+	case colorsAuto, colors8, colors256:
+		return nil
+	}
+	return fmt.Errorf("'%v' is neither %v, %v nor %v", value, colorsAuto, colors8, colors256)
+}
+
+// outputMode returns the output mode of the GUI according to the ui.colors
+// setting, which is guessed by the terminal if set to auto
+func outputMode() c.OutputMode {
+	switch getSetting("ui.colors") {
+	case colors256:
+		return c.Output256
+	case colors8:
+		return c.OutputNormal
+	}
+	if strings.Contains(os.Getenv("TERM"), "256color") {
+		return c.Output256
+	}
+	switch os.Getenv("COLORTERM") {
+	case "truecolor", "24bit":
+		return c.Output256
+	}
+	return c.OutputNormal
+}
+
+// loadTheme returns the configured theme falling back to the default one if
+// it cannot be displayed. The auto theme respects the NO_COLOR convention.
+func loadTheme() theme {
+	name := getSetting("ui.theme")
+	if name == themeAuto {
+		name = themes[0].name
+		if len(os.Getenv("NO_COLOR")) > 0 {
+			name = "mono"
+		}
+	}
+	t, ok := findTheme(name)
+	if !ok {
+		log.Printf("Unknown theme '%v'", name)
+		return themes[0]
+	}
+	if t.needs256 && !App.Colors256 {
+		log.Printf("Theme '%v' needs 256 colors", name)
+		return themes[0]
+	}
+	return t
+}
+
+// nextTheme returns the theme following the current one which can be
+// displayed
+func nextTheme(current string, colors256 bool) theme {
+	idx := 0
+	for i, t := range themes {
+		if t.name == current {
+			idx = i
+		}
+	}
+	for i := 1; i < len(themes); i++ {
+		t := themes[(idx+i)%len(themes)]
+		if !t.needs256 || colors256 {
+			return t
+		}
+	}
+	return themes[idx]
+}
+
+// setTextColors sets the colors of the text written in the views
+func setTextColors(t theme) {
+	App.Bold = color.New(t.Bold...)
+	App.Dim = color.New(t.Dim...)
+	warningColor = color.New(t.Warning...)
+	errorColor = color.New(t.Error...)
+}
+
+// applyTheme sets the theme to the GUI and its existing views translating the
+// color of the focused frame
+func applyTheme(g *c.Gui, t theme) {
+	switch g.SelFgColor {
+	case App.Theme.Bookmarks:
+		g.SelFgColor = t.Bookmarks
+	case App.Theme.Alert:
+		g.SelFgColor = t.Alert
+	default:
+		g.SelFgColor = t.Focus
+	}
+	g.FgColor, g.BgColor, g.SelBgColor = t.Foreground, t.Background, t.Background
+	for _, v := range g.Views() {
+		v.FgColor, v.BgColor = t.Foreground, t.Background
+		v.SelFgColor, v.SelBgColor = t.SelectedFg, t.SelectedBg
+	}
+	App.Theme = t
+	setTextColors(t)
+}
+
+// CycleTheme switches to the next theme and stores it as the ui.theme setting
+func CycleTheme(g *c.Gui, v *c.View) error {
+	t := nextTheme(App.Theme.name, App.Colors256)
+	applyTheme(g, t)
+	if err := App.tdb.SetSetting("ui.theme", t.name); err != nil {
+		return err
+	}
+	// redraw the text colored by the previous theme
+	App.relayout = true
+	if err := UpdateSummary(); err != nil {
+		return err
+	}
+	showInfo(g, "Theme: %v", t.name)
+	return nil
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"os"
+	"testing"
+
+	c "github.com/jroimartin/gocui"
+)
+
+func TestValidateTheme(t *testing.T) {
+	for _, name := range append(themeNames(), themeAuto) {
+		if err := validateTheme(name); err != nil {
+			t.Errorf("got invalid theme %q: %v", name, err)
+		}
+	}
+	if err := validateTheme("solarized"); err == nil {
+		t.Errorf("got no error for an unknown theme")
+	}
+}
+
+func TestNextTheme(t *testing.T) {
+	name := themes[0].name
+	for range themes {
+		next := nextTheme(name, false)
+		if next.needs256 {
+			t.Errorf("got theme %q needing 256 colors", next.name)
+		}
+		name = next.name
+	}
+	if next := nextTheme("mono", true); next.name != "dark-256" {
+		t.Errorf("got theme %q after mono, want dark-256", next.name)
+	}
+	if next := nextTheme("dark-256", true); next.name != themes[0].name {
+		t.Errorf("got theme %q after the last one, want %q", next.name, themes[0].name)
+	}
+}
+
+func TestLoadTheme(t *testing.T) {
+	newTestApp(t)
+	newTestDB(t)
+	defer os.Setenv("NO_COLOR", os.Getenv("NO_COLOR"))
+
+	os.Setenv("NO_COLOR", "")
+	if th := loadTheme(); th.name != "dark" {
+		t.Errorf("got theme %q by default, want dark", th.name)
+	}
+	os.Setenv("NO_COLOR", "1")
+	if th := loadTheme(); th.name != "mono" {
+		t.Errorf("got theme %q with NO_COLOR, want mono", th.name)
+	}
+
+	App.tdb.SetSetting("ui.theme", "dark-256")
+	if th := loadTheme(); th.name != "dark" {
+		t.Errorf("got theme %q without 256 colors, want dark", th.name)
+	}
+	App.Colors256 = true
+	if th := loadTheme(); th.name != "dark-256" {
+		t.Errorf("got theme %q, want dark-256", th.name)
+	}
+}
+
+func TestApplyTheme(t *testing.T) {
+	defer setTextColors(themes[0])
+	newTestApp(t)
+
+	g := &c.Gui{}
+	light, _ := findTheme("light")
+	g.SelFgColor = App.Theme.Bookmarks
+	applyTheme(g, light)
+	if g.SelFgColor != light.Bookmarks || g.BgColor != light.Background {
+		t.Errorf("got focus color %v, want the bookmarks one %v", g.SelFgColor, light.Bookmarks)
+	}
+	if App.Theme.name != "light" {
+		t.Errorf("got theme %q, want light", App.Theme.name)
+	}
+
+	mono, _ := findTheme("mono")
+	g.SelFgColor = App.Theme.Alert
+	applyTheme(g, mono)
+	if g.SelFgColor != mono.Alert {
+		t.Errorf("got focus color %v, want the alert one %v", g.SelFgColor, mono.Alert)
+	}
+}
@@ -20,6 +20,7 @@ package main
 import (
 	"bytes"
 	"fmt"
+	"regexp"
 
 	c "github.com/jroimartin/gocui"
 )
@@ -37,6 +38,8 @@ type List struct {
 	pages       []Page
 	currPageIdx int
 	ordered     bool
+	// format, if set, returns the text of an item instead of fmt.Sprint
+	format func(item interface{}) string
 }
 
 // CreateList initializes a List object with an existing View by applying some
@@ -44,8 +47,8 @@ type List struct {
 func CreateList(v *c.View, ordered bool) *List {
 	list := &List{}
 	list.View = v
-	list.SelBgColor = c.ColorBlack
-	list.SelFgColor = c.ColorWhite | c.AttrBold
+	list.SelBgColor = App.Theme.SelectedBg
+	list.SelFgColor = App.Theme.SelectedFg
 	list.Autoscroll = true
 	list.ordered = ordered
 
@@ -61,6 +64,7 @@ func (l *List) IsEmpty() bool {
 func (l *List) Focus(g *c.Gui) error {
 	l.Highlight = true
 	_, err := g.SetCurrentView(l.Name())
+	refreshFocus(g)
 
 	return err
 }
@@ -112,6 +116,21 @@ func (l *List) UpdateCurrentItem(item interface{}) {
 	data[l.currentCursorY()] = item
 }
 
+// Item returns the item at the given index of the list if any
+func (l *List) Item(idx int) interface{} {
+	if idx < 0 || idx >= len(l.items) {
+		return nil
+	}
+	return l.items[idx]
+}
+
+// UpdateItem replaces the item at the given index of the list
+func (l *List) UpdateItem(idx int, item interface{}) {
+	if idx >= 0 && idx < len(l.items) {
+		l.items[idx] = item
+	}
+}
+
 // Draw calculates the pages and draws the first one
 func (l *List) Draw() error {
 	if l.IsEmpty() {
@@ -120,6 +139,17 @@ func (l *List) Draw() error {
 	return l.displayPage(0)
 }
 
+// Redraw recalculates the pages of the list, e.g. once its View is resized,
+// keeping the current item selected
+func (l *List) Redraw() error {
+	idx := l.CurrentIdx()
+	l.ResetPages()
+	if err := l.Draw(); err != nil {
+		return err
+	}
+	return l.Select(idx)
+}
+
 // Draw calculates the pages and draws the first one
 func (l *List) DrawCurrentPage() error {
 	if l.IsEmpty() {
@@ -191,6 +221,32 @@ func (l *List) CurrentItem() interface{} {
 	return data[l.currentCursorY()]
 }
 
+// CurrentIdx returns the index of the currently selected item of the list or
+// -1 if the list is empty
+func (l *List) CurrentIdx() int {
+	if l.IsEmpty() {
+		return -1
+	}
+	return l.currPage().offset + l.currentCursorY()
+}
+
+// Select displays the page of the item with the given index and moves the
+// cursor on it
+func (l *List) Select(idx int) error {
+	if l.IsEmpty() || idx < 0 || idx >= l.length() {
+		return nil
+	}
+	for p, page := range l.pages {
+		if idx >= page.offset && idx < page.offset+page.limit {
+			if err := l.displayPage(p); err != nil {
+				return err
+			}
+			return l.SetCursor(0, idx-page.offset)
+		}
+	}
+	return nil
+}
+
 // ResetCursor puts the cirson back at the beginning of the View
 func (l *List) ResetCursor() {
 	l.SetCursor(0, 0)
@@ -271,7 +327,10 @@ func (l *List) prevPageIdx() int {
 // the remaining space until the border of the View
 func (l *List) displayItem(i int) string {
 	item := fmt.Sprint(l.items[i])
-	sp := spaces(l.width() - len(item) - 3)
+	if l.format != nil {
+		item = l.format(l.items[i])
+	}
+	sp := spaces(l.width() - len(ansiEscape.ReplaceAllString(item, "")) - 3)
 	if l.ordered {
 		return fmt.Sprintf("%2d. %v%v", i+1, item, sp)
 	} else {
@@ -309,6 +368,9 @@ func (l *List) hasMultiplePages() bool {
 	return l.pagesNum() > 1
 }
 
+// ansiEscape matches the color escape sequences which take no space on screen
+var ansiEscape = regexp.MustCompile("\x1b\\[[0-9;]*m")
+
 func spaces(n int) string {
 	var s bytes.Buffer
 	for i := 0; i < n; i++ {
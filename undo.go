@@ -0,0 +1,155 @@
+/*
+   Terminews is a terminal based (TUI) RSS feed manager.
+   Copyright (C) 2017  Alexandros Ntavelos, a[dot]ntavelos[at]gmail[dot]com
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/antavelos/terminews/db"
+	c "github.com/jroimartin/gocui"
+)
+
+// maxUndo is the number of the last deletions which can be undone
+const maxUndo = 20
+
+// undoEntry is a deletion which can be undone
+type undoEntry struct {
+	what      string
+	deletedAt time.Time
+	restore   func(g *c.Gui) error
+}
+
+// undoKeep returns the time the soft deleted sites and bookmarks are kept
+// before being purged
+func undoKeep() time.Duration {
+	return time.Duration(getIntSetting("undo.keep_minutes")) * time.Minute
+}
+
+// pushUndo remembers a deletion dropping the oldest one beyond maxUndo
+func pushUndo(what string, restore func(g *c.Gui) error) {
+	App.undoStack = append(App.undoStack, undoEntry{what, time.Now(), restore})
+	if len(App.undoStack) > maxUndo {
+		App.undoStack = append([]undoEntry(nil), App.undoStack[len(App.undoStack)-maxUndo:]...)
+	}
+}
+
+// popUndo removes and returns the last deletion unless it may have been
+// purged meanwhile
+func popUndo(now time.Time, keep time.Duration) (undoEntry, bool) {
+	if len(App.undoStack) == 0 {
+		return undoEntry{}, false
+	}
+	e := App.undoStack[len(App.undoStack)-1]
+	if now.Sub(e.deletedAt) >= keep {
+		// the older deletions have expired as well
+		App.undoStack = nil
+		return undoEntry{}, false
+	}
+	App.undoStack = App.undoStack[:len(App.undoStack)-1]
+	return e, true
+}
+
+// confirmDeletion runs the given deletion at once or, if configured, once the
+// user confirms it
+func confirmDeletion(g *c.Gui, what string, from *List, deletion func(g *c.Gui) error) error {
+	if !getBoolSetting("ui.confirm_delete") {
+		return deletion(g)
+	}
+	return openPrompt(g, deletePrompt(from, deletion), fmt.Sprintf("Delete %v? (y to confirm):", what), "")
+}
+
+// deletePrompt runs the given deletion once confirmed
+func deletePrompt(from *List, deletion func(g *c.Gui) error) *prompt {
+	submit := func(g *c.Gui, input string) error {
+		if err := closePrompt(g); err != nil {
+			return err
+		}
+		answer := strings.ToLower(strings.TrimSpace(input))
+		if answer != "y" && answer != "yes" {
+			return nil
+		}
+		return deletion(g)
+	}
+	return &prompt{kind: promptDelete, from: from, submit: submit}
+}
+
+// deleteSite soft deletes a site so that it can be restored
+func deleteSite(g *c.Gui, site db.Site) error {
+	if err := App.tdb.SoftDeleteSite(site.Id); err != nil {
+		return err
+	}
+	what := fmt.Sprintf("site '%v'", site.Name)
+	pushUndo(what, func(g *c.Gui) error {
+		if err := App.tdb.RestoreSite(site.Id); err != nil {
+			return err
+		}
+		return LoadSites()
+	})
+	showInfo(g, "Deleted %v, Ctrl+z to undo", what)
+
+	return LoadSites()
+}
+
+// deleteBookmark soft deletes a bookmark so that it can be restored
+func deleteBookmark(g *c.Gui, bookmark db.Event) error {
+	if err := App.tdb.SoftDeleteEvent(bookmark.Id); err != nil {
+		return err
+	}
+	what := fmt.Sprintf("bookmark '%v'", bookmark.Title)
+	pushUndo(what, func(g *c.Gui) error {
+		if err := App.tdb.RestoreEvent(bookmark.Id); err != nil {
+			return err
+		}
+		return reloadBookmarks(g)
+	})
+	showInfo(g, "Deleted %v, Ctrl+z to undo", what)
+
+	return nil
+}
+
+// reloadBookmarks reloads the bookmarks and redraws the news list marking the
+// bookmarked news
+func reloadBookmarks(g *c.Gui) error {
+	if isBookmarksNews() {
+		return LoadBookmarks(g, App.NewsList.View)
+	}
+	var err error
+	if App.CurrentBookmarks, err = App.tdb.GetEvents(); err != nil {
+		return err
+	}
+	return App.NewsList.DrawCurrentPage()
+}
+
+func Undo(g *c.Gui, v *c.View) error {
+	if v.Name() == PROMPT_VIEW {
+		return nil
+	}
+	e, ok := popUndo(time.Now(), undoKeep())
+	if !ok {
+		showInfo(g, "Nothing to undo")
+		return nil
+	}
+	if err := e.restore(g); err != nil {
+		return fmt.Errorf("Failed to restore %v: %v", e.what, err)
+	}
+	showInfo(g, "Restored %v", e.what)
+
+	return nil
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestUndoStack(t *testing.T) {
+	newTestApp(t)
+
+	now := time.Now()
+	for i := 0; i < maxUndo+5; i++ {
+		pushUndo(fmt.Sprintf("site %v", i), nil)
+	}
+	if len(App.undoStack) != maxUndo || App.undoStack[0].what != "site 5" {
+		t.Fatalf("got %v entries starting with %q, want %v starting with %q",
+			len(App.undoStack), App.undoStack[0].what, maxUndo, "site 5")
+	}
+
+	e, ok := popUndo(now, time.Hour)
+	if !ok || e.what != fmt.Sprintf("site %v", maxUndo+4) {
+		t.Errorf("got %q (%v), want the last deletion", e.what, ok)
+	}
+	if len(App.undoStack) != maxUndo-1 {
+		t.Errorf("got %v entries, want %v", len(App.undoStack), maxUndo-1)
+	}
+
+	// the deletions older than the kept time may have been purged
+	if e, ok = popUndo(now.Add(2*time.Hour), time.Hour); ok {
+		t.Errorf("got expired deletion %q", e.what)
+	}
+	if len(App.undoStack) != 0 {
+		t.Errorf("got %v entries, want the expired ones dropped", len(App.undoStack))
+	}
+	if _, ok = popUndo(now, time.Hour); ok {
+		t.Errorf("got a deletion from an empty stack")
+	}
+}
@@ -19,9 +19,26 @@ package main
 
 import (
 	_ "fmt"
+	"os/user"
+	"path/filepath"
 	"strings"
+	"time"
 )
 
+// dateLayouts are the layouts tried in order when parsing the published date
+// of an event
+var dateLayouts = []string{
+	time.RFC1123Z,
+	time.RFC1123,
+	time.RFC3339,
+	time.RFC822Z,
+	time.RFC822,
+	"Mon, 2 Jan 2006 15:04:05 -0700",
+	"Mon, 2 Jan 2006 15:04:05 MST",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+}
+
 func JustifiedLines(text string, w int) (lines []string) {
 	tokens := strings.Split(text, " ")
 
@@ -45,3 +62,27 @@ func JustifiedLines(text string, w int) (lines []string) {
 
 	return
 }
+
+// parseDate tries to parse the given date with any of the known layouts
+func parseDate(date string) (time.Time, bool) {
+	date = strings.TrimSpace(date)
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, date); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// expandPath replaces a leading "~" of the given path with the home directory
+// of the current user
+func expandPath(p string) string {
+	if p != "~" && !strings.HasPrefix(p, "~/") {
+		return p
+	}
+	usr, err := user.Current()
+	if err != nil {
+		return p
+	}
+	return filepath.Join(usr.HomeDir, p[1:])
+}